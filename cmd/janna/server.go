@@ -27,6 +27,9 @@ import (
 	"github.com/vterdunov/janna-api/internal/version"
 )
 
+// httpShutdownTimeout is a time to wait for active HTTP connections on shutdown
+const httpShutdownTimeout = 10 * time.Second
+
 func main() {
 	// Load ENV configuration
 	cfg, err := config.Load()
@@ -61,10 +64,31 @@ func main() {
 		Help:      "Total duration of requests in seconds.",
 	}, []string{"method", "success"})
 
-	inMemoryStorage := status.NewStorage()
-	statusStorage := service.Statuser(inMemoryStorage)
+	cacheRequests := prometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Namespace: "janna",
+		Subsystem: "ova_cache",
+		Name:      "requests_total",
+		Help:      "Total number of OVA cache lookups by result: hit, miss, bypass.",
+	}, []string{"result"})
+
+	var statusStorage service.Statuser
+	switch cfg.Tasks.Storage {
+	case "bolt":
+		boltStorage, err := status.NewBoltStorage(cfg.Tasks.Path, log.With(logger, "component", "status"))
+		if err != nil {
+			logger.Log("err", errors.Wrap(err, "Could not create tasks storage"))
+			os.Exit(1)
+		}
+		defer boltStorage.Close()
+		statusStorage = boltStorage
+	default:
+		statusStorage = status.NewStorage()
+	}
 
-	svc := service.New(logger, cfg, client.Client, duration, statusStorage)
+	svc := service.New(logger, cfg, client.Client, duration, cacheRequests, statusStorage)
+	if err := svc.ResumeTasks(ctx); err != nil {
+		logger.Log("err", errors.Wrap(err, "Could not resume interrupted tasks"))
+	}
 
 	endpoints := endpoint.New(svc, logger)
 	httpHandler := transport.NewHTTPHandler(endpoints, logger, cfg.DebugHTTP)
@@ -88,7 +112,7 @@ func main() {
 				"msg", "Starting HTTP server",
 				"address", httpServer.Addr,
 			)
-			if err := httpServer.ListenAndServe(); err != nil {
+			if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 				logger.Log("msg", "Startup failed", "err", err)
 				os.Exit(1)
 			}
@@ -106,13 +130,23 @@ func main() {
 	}
 
 	logger.Log("msg", "The service is going shutting down")
-	client.Logout(ctx)
+
+	// Keep serving HTTP while draining, so clients can watch their tasks
+	graceCtx, cancel := context.WithTimeout(ctx, cfg.ShutdownGracePeriod)
+	if err := svc.Shutdown(graceCtx); err != nil {
+		logger.Log("msg", "Something went wrong while stopping background tasks", "err", err)
+	}
+	cancel()
 
 	if cfg.Protocols.HTTP.Port != "" {
-		if err := httpServer.Shutdown(ctx); err != nil {
+		httpCtx, cancel := context.WithTimeout(ctx, httpShutdownTimeout)
+		if err := httpServer.Shutdown(httpCtx); err != nil {
 			logger.Log("msg", "Somethig went wrong while HTTP server stopping", "err", err)
 		}
+		cancel()
 	}
+
+	client.Logout(ctx)
 	logger.Log("msg", "Stopped")
 }
 
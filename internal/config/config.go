@@ -2,8 +2,10 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -14,6 +16,14 @@ type Config struct {
 	DebugHTTP bool
 	VMWare    resources
 	TaskTTL   time.Duration
+	Tasks     tasks
+	Deploy    deploy
+	Uploads   uploads
+	OVACache  ovaCache
+	// IdempotencyKeysTTL is a time the responses of requests with Idempotency-Key header are kept
+	IdempotencyKeysTTL time.Duration
+	// ShutdownGracePeriod is a time to wait for running tasks on shutdown
+	ShutdownGracePeriod time.Duration
 }
 
 type resources struct {
@@ -21,6 +31,40 @@ type resources struct {
 	Insecure bool
 	DC       string
 	Folder   string
+	// Network is a network the OVF networks are connected to when vSphere does not have a network with the same name
+	Network string
+}
+
+type tasks struct {
+	// Storage is a type of tasks statuses storage. Possible values are 'memory', 'bolt'
+	Storage string
+	// Path is a path to the database file. Used by file-backed storages only
+	Path string
+}
+
+type deploy struct {
+	// Workers is a maximum number of deploys running at the same time
+	Workers int
+	// QueueSize is a maximum number of deploys waiting for a worker. Zero means unlimited
+	QueueSize int
+	// DatacenterLimits is a maximum number of deploys running at the same time in a datacenter
+	DatacenterLimits map[string]int
+}
+
+type uploads struct {
+	// Path is a directory where uploaded OVA files are staged
+	Path string
+	// MaxSize is a maximum size of an uploaded OVA in bytes
+	MaxSize int64
+	// TTL is a time an uploaded OVA is kept after the upload
+	TTL time.Duration
+}
+
+type ovaCache struct {
+	// Path is a directory of the cache. Empty path disables the cache
+	Path string
+	// MaxSize is a maximum total size of cached OVA files in bytes
+	MaxSize int64
 }
 
 type protocols struct {
@@ -77,6 +121,12 @@ func Load() (*Config, error) {
 		config.VMWare.Folder = vmwareFolder
 	}
 
+	// VMWare default network
+	vmwareNetwork, exist := os.LookupEnv("VMWARE_NETWORK")
+	if exist {
+		config.VMWare.Network = vmwareNetwork
+	}
+
 	// Background jobs time to live
 	defaultTTL := time.Minute * 30
 	taskTTL, exist := os.LookupEnv("TASKS_TTL")
@@ -87,5 +137,129 @@ func Load() (*Config, error) {
 		config.TaskTTL = time.Minute * time.Duration(minutes)
 	}
 
+	// Background jobs statuses storage
+	config.Tasks.Storage = "memory"
+	tasksStorage, exist := os.LookupEnv("TASKS_STORAGE")
+	if exist && tasksStorage != "" {
+		config.Tasks.Storage = tasksStorage
+	}
+
+	switch config.Tasks.Storage {
+	case "memory", "bolt":
+	default:
+		return nil, errors.New("could not recognize 'TASKS_STORAGE'. Possible values are 'memory', 'bolt'")
+	}
+
+	config.Tasks.Path = "janna-tasks.db"
+	tasksPath, exist := os.LookupEnv("TASKS_STORAGE_PATH")
+	if exist && tasksPath != "" {
+		config.Tasks.Path = tasksPath
+	}
+
+	// Deploy workers pool
+	config.Deploy.Workers = 4
+	if v, exist := os.LookupEnv("DEPLOY_WORKERS"); exist && v != "" {
+		workers, err := strconv.Atoi(v)
+		if err != nil || workers < 1 {
+			return nil, errors.New("'DEPLOY_WORKERS' must be a positive number")
+		}
+		config.Deploy.Workers = workers
+	}
+
+	config.Deploy.QueueSize = 100
+	if v, exist := os.LookupEnv("DEPLOY_QUEUE_SIZE"); exist && v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil || size < 0 {
+			return nil, errors.New("'DEPLOY_QUEUE_SIZE' must be a non-negative number")
+		}
+		config.Deploy.QueueSize = size
+	}
+
+	limits, err := parseDatacenterLimits(os.Getenv("DEPLOY_DATACENTER_LIMITS"))
+	if err != nil {
+		return nil, err
+	}
+	config.Deploy.DatacenterLimits = limits
+
+	// Uploaded OVA staging area
+	config.Uploads.Path = "janna-uploads"
+	if v, exist := os.LookupEnv("UPLOADS_PATH"); exist && v != "" {
+		config.Uploads.Path = v
+	}
+
+	config.Uploads.MaxSize = 10 << 30
+	if v, exist := os.LookupEnv("UPLOADS_MAX_SIZE"); exist && v != "" {
+		mb, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || mb < 1 {
+			return nil, errors.New("'UPLOADS_MAX_SIZE' must be a positive number of megabytes")
+		}
+		config.Uploads.MaxSize = mb << 20
+	}
+
+	config.Uploads.TTL = time.Hour * 24
+	if v, exist := os.LookupEnv("UPLOADS_TTL"); exist && v != "" {
+		minutes, err := strconv.Atoi(v)
+		if err != nil || minutes < 1 {
+			return nil, errors.New("'UPLOADS_TTL' must be a positive number of minutes")
+		}
+		config.Uploads.TTL = time.Minute * time.Duration(minutes)
+	}
+
+	// Remote OVA cache
+	config.OVACache.Path = os.Getenv("OVA_CACHE_PATH")
+
+	config.OVACache.MaxSize = 20 << 30
+	if v, exist := os.LookupEnv("OVA_CACHE_MAX_SIZE"); exist && v != "" {
+		mb, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || mb < 1 {
+			return nil, errors.New("'OVA_CACHE_MAX_SIZE' must be a positive number of megabytes")
+		}
+		config.OVACache.MaxSize = mb << 20
+	}
+
+	// Idempotent requests
+	config.IdempotencyKeysTTL = time.Hour * 24
+	if v, exist := os.LookupEnv("IDEMPOTENCY_KEYS_TTL"); exist && v != "" {
+		minutes, err := strconv.Atoi(v)
+		if err != nil || minutes < 1 {
+			return nil, errors.New("'IDEMPOTENCY_KEYS_TTL' must be a positive number of minutes")
+		}
+		config.IdempotencyKeysTTL = time.Minute * time.Duration(minutes)
+	}
+
+	// Graceful shutdown
+	config.ShutdownGracePeriod = time.Second * 30
+	if v, exist := os.LookupEnv("SHUTDOWN_GRACE_PERIOD"); exist && v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil || seconds < 0 {
+			return nil, errors.New("'SHUTDOWN_GRACE_PERIOD' must be a non-negative number of seconds")
+		}
+		config.ShutdownGracePeriod = time.Second * time.Duration(seconds)
+	}
+
 	return config, nil
 }
+
+// parseDatacenterLimits parses limits in the 'DC1=2,DC2=1' format
+func parseDatacenterLimits(s string) (map[string]int, error) {
+	limits := make(map[string]int)
+	if s == "" {
+		return limits, nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("could not parse 'DEPLOY_DATACENTER_LIMITS' item '%s'. Use 'datacenter=limit' format", pair)
+		}
+
+		dc := strings.TrimSpace(kv[0])
+		limit, err := strconv.Atoi(strings.TrimSpace(kv[1]))
+		if dc == "" || err != nil || limit < 1 {
+			return nil, fmt.Errorf("could not parse 'DEPLOY_DATACENTER_LIMITS' item '%s'. Limit must be a positive number", pair)
+		}
+		limits[dc] = limit
+	}
+
+	return limits, nil
+}
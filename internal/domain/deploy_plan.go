@@ -0,0 +1,30 @@
+package domain
+
+// DeploySource is where the Virtual Machine of a deploy comes from
+type DeploySource string
+
+// Deploy sources
+const (
+	DeploySourceOVA     DeploySource = "ova"
+	DeploySourceClone   DeploySource = "clone"
+	DeploySourceLibrary DeploySource = "library"
+)
+
+// DeployPlan describes where a Virtual Machine would be deployed and how it would be created
+type DeployPlan struct {
+	Name         string
+	Source       DeploySource
+	Datacenter   string
+	Folder       string
+	ResourcePool string
+	// Host is empty when vCenter chooses the host
+	Host      string
+	Datastore string
+	// StorageDRS is set when the datastore was recommended by Storage DRS
+	StorageDRS bool
+	// DiskProvisioning is a format of the imported disks. Empty for clones
+	DiskProvisioning string
+	Networks         []NetworkMapping
+	// Warnings are the import spec warnings reported by vSphere
+	Warnings []string
+}
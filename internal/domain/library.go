@@ -0,0 +1,18 @@
+package domain
+
+// Library is a vSphere Content Library
+type Library struct {
+	ID          string
+	Name        string
+	Type        string
+	Description string
+}
+
+// LibraryItem is an item of a Content Library. OVF templates have 'ovf' type
+type LibraryItem struct {
+	ID          string
+	Name        string
+	Type        string
+	Size        int64
+	Description string
+}
@@ -0,0 +1,21 @@
+package domain
+
+// NetworkKind is a kind of a vSphere network a Virtual Machine is connected to
+type NetworkKind string
+
+// Network kinds
+const (
+	NetworkKindStandard             NetworkKind = "standard"
+	NetworkKindDistributedPortGroup NetworkKind = "distributed_port_group"
+	// NetworkKindNSXSegment is an NSX segment presented to vSphere as an opaque network
+	NetworkKindNSXSegment NetworkKind = "nsx_segment"
+)
+
+// NetworkMapping maps a network of the OVF to a vSphere network
+type NetworkMapping struct {
+	// Name is the OVF network name
+	Name string
+	// Network is the vSphere network name or inventory path
+	Network string
+	Kind    NetworkKind
+}
@@ -0,0 +1,68 @@
+package domain
+
+import "time"
+
+// OVAInfo describes the content of an OVA package
+type OVAInfo struct {
+	Name              string
+	Annotation        string
+	Networks          []OVANetwork
+	Disks             []OVADisk
+	Properties        []OVAProperty
+	DeploymentOptions []OVADeploymentOption
+	Hardware          OVAHardware
+}
+
+// OVANetwork is a network the OVA expects to be connected to
+type OVANetwork struct {
+	Name        string
+	Description string
+}
+
+// OVADisk is a virtual disk declared in the OVF DiskSection
+type OVADisk struct {
+	ID   string
+	File string
+	// CapacityBytes is zero when the capacity is defined by a vApp property
+	CapacityBytes      int64
+	PopulatedSizeBytes int64
+	// FileSizeBytes is a size of the disk file inside the package
+	FileSizeBytes int64
+}
+
+// OVAProperty is a vApp property declared in the OVF ProductSection
+type OVAProperty struct {
+	// Key is in OVF environment form: [class.]key[.instance]
+	Key              string
+	Type             string
+	Label            string
+	Description      string
+	Default          string
+	UserConfigurable bool
+	Password         bool
+}
+
+// OVADeploymentOption is a configuration declared in the OVF DeploymentOptionSection
+type OVADeploymentOption struct {
+	ID          string
+	Label       string
+	Description string
+	Default     bool
+}
+
+// OVAHardware keeps the hardware requirements of the default configuration
+type OVAHardware struct {
+	VirtualSystemType string
+	NumCPU            int64
+	MemoryMB          int64
+}
+
+// OVAUpload is an OVA file uploaded to Janna staging area
+type OVAUpload struct {
+	ID string
+	// Name is the original file name. Can be empty
+	Name      string
+	Size      int64
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
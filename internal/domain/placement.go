@@ -0,0 +1,29 @@
+package domain
+
+// DatastoreStrategy defines how a datastore is chosen from the requested ones
+type DatastoreStrategy string
+
+// Datastore placement strategies
+const (
+	DatastoreStrategyMostFreeSpace DatastoreStrategy = "most_free_space"
+	DatastoreStrategyLeastVMs      DatastoreStrategy = "least_vms"
+	// DatastoreStrategyWeightedRandom picks a datastore randomly with the chance proportional to its free space
+	DatastoreStrategyWeightedRandom DatastoreStrategy = "weighted_random"
+)
+
+// DatastoreStrategies lists all known datastore placement strategies
+var DatastoreStrategies = []DatastoreStrategy{
+	DatastoreStrategyMostFreeSpace,
+	DatastoreStrategyLeastVMs,
+	DatastoreStrategyWeightedRandom,
+}
+
+// IsValid reports whether the strategy is a known one
+func (s DatastoreStrategy) IsValid() bool {
+	for _, strategy := range DatastoreStrategies {
+		if s == strategy {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,289 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// TaskKind is a kind of a background task
+type TaskKind string
+
+// TaskKindVMDeploy is a Virtual Machine deploy task
+const TaskKindVMDeploy TaskKind = "vm_deploy"
+
+// TaskStage is a stage of a background task
+type TaskStage string
+
+// Background task stages
+const (
+	TaskStageQueued      TaskStage = "queued"
+	TaskStageStart       TaskStage = "start"
+	TaskStageImport      TaskStage = "import"
+	TaskStageClone       TaskStage = "clone"
+	TaskStageLibrary     TaskStage = "library"
+	TaskStageReconfigure TaskStage = "reconfigure"
+	TaskStageCreate      TaskStage = "create"
+	TaskStageError       TaskStage = "error"
+	TaskStageComplete    TaskStage = "complete"
+	TaskStageCancelled   TaskStage = "cancelled"
+)
+
+// TaskStages lists all known task stages
+var TaskStages = []TaskStage{
+	TaskStageQueued,
+	TaskStageStart,
+	TaskStageImport,
+	TaskStageClone,
+	TaskStageLibrary,
+	TaskStageReconfigure,
+	TaskStageCreate,
+	TaskStageError,
+	TaskStageComplete,
+	TaskStageCancelled,
+}
+
+// IsValid reports whether the stage is a known one
+func (s TaskStage) IsValid() bool {
+	for _, stage := range TaskStages {
+		if s == stage {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFinal reports whether the task can not move to another stage anymore
+func (s TaskStage) IsFinal() bool {
+	switch s {
+	case TaskStageError, TaskStageComplete, TaskStageCancelled:
+		return true
+	}
+	return false
+}
+
+// OVACacheStatus shows whether the OVA was read from the local cache
+type OVACacheStatus string
+
+// OVA cache statuses
+const (
+	OVACacheHit  OVACacheStatus = "hit"
+	OVACacheMiss OVACacheStatus = "miss"
+	// OVACacheBypass means the OVA can not be cached and is read from its URL
+	OVACacheBypass OVACacheStatus = "bypass"
+)
+
+// CleanupPolicy defines what happens to the Virtual Machine of a failed deploy
+type CleanupPolicy string
+
+// Cleanup policies
+const (
+	CleanupPolicyDestroy CleanupPolicy = "destroy"
+	// CleanupPolicyPowerOff keeps the Virtual Machine, but powers it off
+	CleanupPolicyPowerOff CleanupPolicy = "power_off"
+	CleanupPolicyKeep     CleanupPolicy = "keep"
+)
+
+// CleanupPolicies lists all known cleanup policies
+var CleanupPolicies = []CleanupPolicy{
+	CleanupPolicyDestroy,
+	CleanupPolicyPowerOff,
+	CleanupPolicyKeep,
+}
+
+// IsValid reports whether the policy is a known one
+func (p CleanupPolicy) IsValid() bool {
+	for _, policy := range CleanupPolicies {
+		if p == policy {
+			return true
+		}
+	}
+	return false
+}
+
+// CleanupAction is what was done to the Virtual Machine of a failed deploy
+type CleanupAction string
+
+// Cleanup actions
+const (
+	// CleanupActionNone means there was no Virtual Machine to clean up
+	CleanupActionNone       CleanupAction = "none"
+	CleanupActionDestroyed  CleanupAction = "destroyed"
+	CleanupActionPoweredOff CleanupAction = "powered_off"
+	CleanupActionKept       CleanupAction = "kept"
+	// CleanupActionFailed means the policy could not be applied. See TaskCleanup.Error
+	CleanupActionFailed CleanupAction = "failed"
+)
+
+// TaskCleanup keeps the cleanup policy of a deploy and the cleanup performed when the deploy has failed
+type TaskCleanup struct {
+	Policy CleanupPolicy
+	// Action is empty until the deploy has failed
+	Action CleanupAction
+	Error  string
+}
+
+// Task represents a background task
+type Task struct {
+	ID        string
+	Kind      TaskKind
+	Stage     TaskStage
+	Message   string
+	VMName    string
+	RequestID string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	// StageTimes keeps the time when the task entered every passed stage
+	StageTimes map[TaskStage]time.Time
+	// Progress is a task completion percentage
+	Progress int
+	// QueuePosition is a 1-based position in the deploy queue. Zero when the task is not queued
+	QueuePosition int
+	// Interrupted is set when Janna was stopped before the task had finished.
+	// Such task keeps its stage and is resumed after restart.
+	Interrupted bool
+	// OVACache shows whether the OVA was read from the local cache.
+	// Empty when the cache is disabled or the OVA is not remote
+	OVACache OVACacheStatus
+	// Upload keeps disks upload progress during the import stage
+	Upload *UploadProgress
+	Error  *TaskError
+	Result TaskResult
+	// Cleanup is set for deploy tasks. Can be nil for tasks created by older Janna versions
+	Cleanup *TaskCleanup
+	// Webhook keeps attempts to deliver the task result to the caller. Can be nil
+	Webhook *WebhookDelivery
+}
+
+// UploadProgress keeps disks upload progress aggregated over all files
+type UploadProgress struct {
+	Files            []FileProgress
+	TotalBytes       int64
+	TransferredBytes int64
+	Percentage       float32
+	// ETA is an estimated time left to finish the upload
+	ETA time.Duration
+}
+
+// FileProgress keeps upload progress of a single file
+type FileProgress struct {
+	Path             string
+	TotalBytes       int64
+	TransferredBytes int64
+	Percentage       float32
+}
+
+// TaskErrorKind classifies task errors that clients may handle separately
+type TaskErrorKind string
+
+// TaskErrorKindIntegrity means the deployed package does not match its checksums
+const TaskErrorKindIntegrity TaskErrorKind = "integrity"
+
+// TaskError describes why a task has failed
+type TaskError struct {
+	// Stage is the stage the task has failed on
+	Stage TaskStage
+	// Kind is empty for errors without a special meaning
+	Kind    TaskErrorKind
+	Message string
+}
+
+// IntegrityError is returned when a package does not match its manifest or the checksum passed by a user
+type IntegrityError struct {
+	Message string
+}
+
+func (e *IntegrityError) Error() string {
+	return "integrity check failed: " + e.Message
+}
+
+// TaskResult keeps the outcome of a task
+type TaskResult struct {
+	VMUUID string
+	IPs    []string
+	// DiskProvisioning is a format of the imported disks. Empty for clones
+	DiskProvisioning string
+	// Networks is the final mapping of the OVF networks. Empty for clones
+	Networks []NetworkMapping
+}
+
+// WebhookDelivery keeps attempts to deliver the task result to a callback URL
+type WebhookDelivery struct {
+	URL       string
+	Delivered bool
+	Attempts  []WebhookAttempt
+}
+
+// WebhookAttempt is a single try to deliver the task result
+type WebhookAttempt struct {
+	Time time.Time
+	// StatusCode is a HTTP status code of the callback response. Zero if the request has failed
+	StatusCode int
+	Error      string
+}
+
+// SetStage moves the task to the stage and remembers when it happened
+func (t *Task) SetStage(stage TaskStage) {
+	if t.StageTimes == nil {
+		t.StageTimes = make(map[TaskStage]time.Time)
+	}
+
+	t.Stage = stage
+	t.StageTimes[stage] = time.Now()
+}
+
+// Fail moves the task to the error stage
+func (t *Task) Fail(err error) {
+	t.Error = &TaskError{
+		Stage:   t.Stage,
+		Message: err.Error(),
+	}
+	if _, ok := errors.Cause(err).(*IntegrityError); ok {
+		t.Error.Kind = TaskErrorKindIntegrity
+	}
+	t.SetStage(TaskStageError)
+}
+
+// Copy returns a deep copy of the task
+func (t Task) Copy() Task {
+	c := t
+
+	if t.StageTimes != nil {
+		c.StageTimes = make(map[TaskStage]time.Time, len(t.StageTimes))
+		for k, v := range t.StageTimes {
+			c.StageTimes[k] = v
+		}
+	}
+
+	if t.Error != nil {
+		e := *t.Error
+		c.Error = &e
+	}
+
+	if t.Upload != nil {
+		u := *t.Upload
+		u.Files = append([]FileProgress{}, t.Upload.Files...)
+		c.Upload = &u
+	}
+
+	if t.Result.IPs != nil {
+		c.Result.IPs = append([]string{}, t.Result.IPs...)
+	}
+
+	if t.Result.Networks != nil {
+		c.Result.Networks = append([]NetworkMapping{}, t.Result.Networks...)
+	}
+
+	if t.Cleanup != nil {
+		cl := *t.Cleanup
+		c.Cleanup = &cl
+	}
+
+	if t.Webhook != nil {
+		w := *t.Webhook
+		w.Attempts = append([]WebhookAttempt{}, t.Webhook.Attempts...)
+		c.Webhook = &w
+	}
+
+	return c
+}
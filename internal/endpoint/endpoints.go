@@ -21,6 +21,13 @@ type Endpoints struct {
 
 	VMDeployEndpoint endpoint.Endpoint
 
+	OVAInspectEndpoint      endpoint.Endpoint
+	OVAUploadEndpoint       endpoint.Endpoint
+	OVAUploadDeleteEndpoint endpoint.Endpoint
+
+	LibrariesListEndpoint    endpoint.Endpoint
+	LibraryItemsListEndpoint endpoint.Endpoint
+
 	VMSnapshotsListEndpoint       endpoint.Endpoint
 	VMSnapshotCreateEndpoint      endpoint.Endpoint
 	VMSnapshotDeleteEndpoint      endpoint.Endpoint
@@ -37,14 +44,20 @@ type Endpoints struct {
 
 	RoleListEndpoint endpoint.Endpoint
 
-	TaskInfoEndpoint endpoint.Endpoint
+	TasksListEndpoint  endpoint.Endpoint
+	TaskInfoEndpoint   endpoint.Endpoint
+	TaskCancelEndpoint endpoint.Endpoint
+	TaskEventsEndpoint endpoint.Endpoint
 
 	OpenAPIEndpoint endpoint.Endpoint
 }
 
 // New returns an Endpoints struct where each endpoint invokes
 // the corresponding method on the provided service.
+// Mutating endpoints replay the response of a request repeated with the same Idempotency-Key.
 func New(s service.Service, logger log.Logger) Endpoints {
+	idempotencyKeys := newIdempotencyKeys(s.GetConfig().IdempotencyKeysTTL)
+
 	infoEndpoint := MakeInfoEndpoint(s)
 	infoEndpoint = LoggingMiddleware(log.With(logger, "endpoint", "Info"))(infoEndpoint)
 
@@ -59,47 +72,82 @@ func New(s service.Service, logger log.Logger) Endpoints {
 	vmInfoEndpoint = LoggingMiddleware(log.With(logger, "endpoint", "VMInfo"))(vmInfoEndpoint)
 
 	vmDeleteEndpoint := MakeVMDeleteEndpoint(s)
+	vmDeleteEndpoint = idempotencyMiddleware(idempotencyKeys, "VMDelete", log.With(logger, "endpoint", "VMDelete"))(vmDeleteEndpoint)
 	vmDeleteEndpoint = LoggingMiddleware(log.With(logger, "endpoint", "VMDelete"))(vmDeleteEndpoint)
 
 	vmFindEndpoint := MakeVMFindEndpoint(s)
 	vmFindEndpoint = LoggingMiddleware(log.With(logger, "endpoint", "VMFind"))(vmFindEndpoint)
 
 	vmDeployEndpoint := MakeVMDeployEndpoint(s, logger)
+	vmDeployEndpoint = idempotencyMiddleware(idempotencyKeys, "VMDeploy", log.With(logger, "endpoint", "VMDeploy"))(vmDeployEndpoint)
 	vmDeployEndpoint = LoggingMiddleware(log.With(logger, "endpoint", "VMDeploy"))(vmDeployEndpoint)
 
+	ovaInspectEndpoint := MakeOVAInspectEndpoint(s)
+	ovaInspectEndpoint = LoggingMiddleware(log.With(logger, "endpoint", "OVAInspect"))(ovaInspectEndpoint)
+
+	ovaUploadEndpoint := MakeOVAUploadEndpoint(s)
+	ovaUploadEndpoint = idempotencyMiddleware(idempotencyKeys, "OVAUpload", log.With(logger, "endpoint", "OVAUpload"))(ovaUploadEndpoint)
+	ovaUploadEndpoint = LoggingMiddleware(log.With(logger, "endpoint", "OVAUpload"))(ovaUploadEndpoint)
+
+	ovaUploadDeleteEndpoint := MakeOVAUploadDeleteEndpoint(s)
+	ovaUploadDeleteEndpoint = idempotencyMiddleware(idempotencyKeys, "OVAUploadDelete", log.With(logger, "endpoint", "OVAUploadDelete"))(ovaUploadDeleteEndpoint)
+	ovaUploadDeleteEndpoint = LoggingMiddleware(log.With(logger, "endpoint", "OVAUploadDelete"))(ovaUploadDeleteEndpoint)
+
+	librariesListEndpoint := MakeLibrariesListEndpoint(s)
+	librariesListEndpoint = LoggingMiddleware(log.With(logger, "endpoint", "LibrariesList"))(librariesListEndpoint)
+
+	libraryItemsListEndpoint := MakeLibraryItemsListEndpoint(s)
+	libraryItemsListEndpoint = LoggingMiddleware(log.With(logger, "endpoint", "LibraryItemsList"))(libraryItemsListEndpoint)
+
 	vmSnapshotsListEndpoint := MakeVMSnapshotsListEndpoint(s)
 	vmSnapshotsListEndpoint = LoggingMiddleware(log.With(logger, "endpoint", "VMSnapshotsList"))(vmSnapshotsListEndpoint)
 
 	vmSnapshotCreateEndpoint := MakeVMSnapshotCreateEndpoint(s)
+	vmSnapshotCreateEndpoint = idempotencyMiddleware(idempotencyKeys, "VMSnapshotCreate", log.With(logger, "endpoint", "VMSnapshotCreate"))(vmSnapshotCreateEndpoint)
 	vmSnapshotCreateEndpoint = LoggingMiddleware(log.With(logger, "endpoint", "VMSnapshotCreate"))(vmSnapshotCreateEndpoint)
 
 	vmRestoreFromSnapshotEndpoint := MakeVMRestoreFromSnapshotEndpoint(s)
+	vmRestoreFromSnapshotEndpoint = idempotencyMiddleware(idempotencyKeys, "VMRestoreFromSnapshot", log.With(logger, "endpoint", "VMRestoreFromSnapshot"))(vmRestoreFromSnapshotEndpoint)
 	vmRestoreFromSnapshotEndpoint = LoggingMiddleware(log.With(logger, "endpoint", "VMRestoreFromSnapshot"))(vmRestoreFromSnapshotEndpoint)
 
 	vmSnapshotDeleteEndpoint := MakeVMSnapshotDeleteEndpoint(s)
+	vmSnapshotDeleteEndpoint = idempotencyMiddleware(idempotencyKeys, "VMSnapshotDelete", log.With(logger, "endpoint", "VMSnapshotDelete"))(vmSnapshotDeleteEndpoint)
 	vmSnapshotDeleteEndpoint = LoggingMiddleware(log.With(logger, "endpoint", "VMSnapshotDelete"))(vmSnapshotDeleteEndpoint)
 
 	vmPowerEndpoint := MakeVMPowerEndpoint(s)
+	vmPowerEndpoint = idempotencyMiddleware(idempotencyKeys, "VMPower", log.With(logger, "endpoint", "VMPower"))(vmPowerEndpoint)
 	vmPowerEndpoint = LoggingMiddleware(log.With(logger, "endpoint", "VMPower"))(vmPowerEndpoint)
 
 	vmRolesListEndpoint := MakeVMRolesListEndpoint(s)
 	vmRolesListEndpoint = LoggingMiddleware(log.With(logger, "endpoint", "VMRolesListEndpoint"))(vmRolesListEndpoint)
 
 	vmAddROleEndpoint := MakeVMAddRoleEndpoint(s)
+	vmAddROleEndpoint = idempotencyMiddleware(idempotencyKeys, "VMAddRoleEndpoint", log.With(logger, "endpoint", "VMAddRoleEndpoint"))(vmAddROleEndpoint)
 	vmAddROleEndpoint = LoggingMiddleware(log.With(logger, "endpoint", "VMAddRoleEndpoint"))(vmAddROleEndpoint)
 
 	vmScreenshotEndpoint := MakeVMScreenshotEndpoint(s)
 	vmScreenshotEndpoint = LoggingMiddleware(log.With(logger, "endpoint", "VMScreenshotEndpoint"))(vmScreenshotEndpoint)
 
 	vmRenameEndpoint := MakeVMRenameEndpoint(s)
+	vmRenameEndpoint = idempotencyMiddleware(idempotencyKeys, "VMRenameEndpoint", log.With(logger, "endpoint", "VMRenameEndpoint"))(vmRenameEndpoint)
 	vmRenameEndpoint = LoggingMiddleware(log.With(logger, "endpoint", "VMRenameEndpoint"))(vmRenameEndpoint)
 
 	roleListEndpoint := MakeRolesListEndpoint(s)
 	roleListEndpoint = LoggingMiddleware(log.With(logger, "endpoint", "RoleListEndpoint"))(roleListEndpoint)
 
+	tasksListEndpoint := MakeTasksListEndpoint(s)
+	tasksListEndpoint = LoggingMiddleware(log.With(logger, "endpoint", "TasksListEndpoint"))(tasksListEndpoint)
+
 	taskInfoEndpoint := MakeTaskInfoEndpoint(s)
 	taskInfoEndpoint = LoggingMiddleware(log.With(logger, "endpoint", "TaskInfoEndpoint"))(taskInfoEndpoint)
 
+	taskCancelEndpoint := MakeTaskCancelEndpoint(s)
+	taskCancelEndpoint = idempotencyMiddleware(idempotencyKeys, "TaskCancelEndpoint", log.With(logger, "endpoint", "TaskCancelEndpoint"))(taskCancelEndpoint)
+	taskCancelEndpoint = LoggingMiddleware(log.With(logger, "endpoint", "TaskCancelEndpoint"))(taskCancelEndpoint)
+
+	taskEventsEndpoint := MakeTaskEventsEndpoint(s)
+	taskEventsEndpoint = LoggingMiddleware(log.With(logger, "endpoint", "TaskEventsEndpoint"))(taskEventsEndpoint)
+
 	openAPIEndpoint := MakeOpenAPIEndpoint(s)
 	openAPIEndpoint = LoggingMiddleware(log.With(logger, "endpoint", "OpenAPIEndpoint"))(openAPIEndpoint)
 
@@ -116,6 +164,13 @@ func New(s service.Service, logger log.Logger) Endpoints {
 
 		VMDeployEndpoint: vmDeployEndpoint,
 
+		OVAInspectEndpoint:      ovaInspectEndpoint,
+		OVAUploadEndpoint:       ovaUploadEndpoint,
+		OVAUploadDeleteEndpoint: ovaUploadDeleteEndpoint,
+
+		LibrariesListEndpoint:    librariesListEndpoint,
+		LibraryItemsListEndpoint: libraryItemsListEndpoint,
+
 		VMSnapshotsListEndpoint:       vmSnapshotsListEndpoint,
 		VMSnapshotCreateEndpoint:      vmSnapshotCreateEndpoint,
 		VMSnapshotDeleteEndpoint:      vmSnapshotDeleteEndpoint,
@@ -132,7 +187,10 @@ func New(s service.Service, logger log.Logger) Endpoints {
 
 		RoleListEndpoint: roleListEndpoint,
 
-		TaskInfoEndpoint: taskInfoEndpoint,
+		TasksListEndpoint:  tasksListEndpoint,
+		TaskInfoEndpoint:   taskInfoEndpoint,
+		TaskCancelEndpoint: taskCancelEndpoint,
+		TaskEventsEndpoint: taskEventsEndpoint,
 
 		OpenAPIEndpoint: openAPIEndpoint,
 	}
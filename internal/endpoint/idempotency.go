@@ -0,0 +1,198 @@
+package endpoint
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+)
+
+type contextKey int
+
+// ContextKeyIdempotencyKey keeps the Idempotency-Key header of the request
+const ContextKeyIdempotencyKey contextKey = iota
+
+// idempotencyError is returned as a transport error. The transport encodes it with its status code
+type idempotencyError struct {
+	code int
+	msg  string
+}
+
+func (e idempotencyError) Error() string {
+	return e.msg
+}
+
+func (e idempotencyError) StatusCode() int {
+	return e.code
+}
+
+var (
+	errIdempotencyKeyReused = idempotencyError{
+		code: http.StatusUnprocessableEntity,
+		msg:  "Idempotency-Key was already used with a different request",
+	}
+	errIdempotencyKeyInProgress = idempotencyError{
+		code: http.StatusConflict,
+		msg:  "request with the same Idempotency-Key is in progress. Retry later",
+	}
+)
+
+// idempotencyKeys keeps the responses of requests by their Idempotency-Key
+type idempotencyKeys struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*idempotencyEntry
+}
+
+type idempotencyEntry struct {
+	// fingerprint identifies the endpoint and the request body
+	fingerprint string
+	// digest is a hash of the streamed body. It is known when the first request is finished
+	digest string
+	// response is nil while the first request is in progress
+	response interface{}
+	// expires bounds the time of a stuck request too
+	expires time.Time
+}
+
+func newIdempotencyKeys(ttl time.Duration) *idempotencyKeys {
+	return &idempotencyKeys{
+		ttl:     ttl,
+		entries: make(map[string]*idempotencyEntry),
+	}
+}
+
+// begin reserves the key for the request. It returns the stored response and the digest of the streamed body
+// if the same request was already served
+func (k *idempotencyKeys) begin(key, fingerprint string) (interface{}, string, bool, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	now := time.Now()
+	for key, e := range k.entries {
+		if now.After(e.expires) {
+			delete(k.entries, key)
+		}
+	}
+
+	e, ok := k.entries[key]
+	if !ok {
+		k.entries[key] = &idempotencyEntry{
+			fingerprint: fingerprint,
+			expires:     now.Add(k.ttl),
+		}
+		return nil, "", false, nil
+	}
+
+	if e.fingerprint != fingerprint {
+		return nil, "", false, errIdempotencyKeyReused
+	}
+
+	if e.response == nil {
+		return nil, "", false, errIdempotencyKeyInProgress
+	}
+
+	return e.response, e.digest, true, nil
+}
+
+// finish stores the response of a successful request. The key of a failed request is released,
+// so the request can be retried with the same key.
+func (k *idempotencyKeys) finish(key string, response interface{}, digest string, err error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if !succeeded(response, err) {
+		delete(k.entries, key)
+		return
+	}
+
+	e := k.entries[key]
+	e.response = response
+	e.digest = digest
+	e.expires = time.Now().Add(k.ttl)
+}
+
+func succeeded(response interface{}, err error) bool {
+	if f, ok := response.(Failer); ok && f.Failed() != nil {
+		return false
+	}
+	return err == nil && response != nil
+}
+
+// streamedRequest is a request with a body that is streamed to the service. The body is not buffered,
+// so it is hashed while the service reads it, and the body of a repeated request is hashed before the replay.
+type streamedRequest interface {
+	body() io.Reader
+	withBody(r io.Reader) interface{}
+}
+
+// idempotencyMiddleware returns an endpoint middleware that replays the response of a request
+// repeated with the same Idempotency-Key and rejects a different request with the key.
+// Requests without the key are passed through.
+func idempotencyMiddleware(keys *idempotencyKeys, name string, logger log.Logger) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			key, _ := ctx.Value(ContextKeyIdempotencyKey).(string)
+			if key == "" {
+				return next(ctx, request)
+			}
+
+			body, err := json.Marshal(request)
+			if err != nil {
+				return nil, err
+			}
+			sum := sha256.Sum256(append([]byte(name+"\n"), body...))
+			fingerprint := hex.EncodeToString(sum[:])
+
+			stream, isStreamed := request.(streamedRequest)
+			isStreamed = isStreamed && stream.body() != nil
+
+			response, digest, found, err := keys.begin(key, fingerprint)
+			if err == nil && found && isStreamed {
+				h := sha256.New()
+				if _, err := io.Copy(h, stream.body()); err != nil {
+					return nil, err
+				}
+				if hex.EncodeToString(h.Sum(nil)) != digest {
+					err = errIdempotencyKeyReused
+				}
+			}
+			if err != nil {
+				logger.Log("msg", "Reject idempotent request", "idempotency_key", key, "err", err)
+				return nil, err
+			}
+
+			if found {
+				logger.Log("msg", "Replay response of idempotent request", "idempotency_key", key)
+				return response, nil
+			}
+
+			if !isStreamed {
+				response, err = next(ctx, request)
+				keys.finish(key, response, "", err)
+				return response, err
+			}
+
+			h := sha256.New()
+			response, err = next(ctx, stream.withBody(io.TeeReader(stream.body(), h)))
+			if succeeded(response, err) {
+				// the service may stop reading before the end of a valid body
+				if _, cErr := io.Copy(ioutil.Discard, io.TeeReader(stream.body(), h)); cErr != nil {
+					err = cErr
+				}
+			}
+			keys.finish(key, response, hex.EncodeToString(h.Sum(nil)), err)
+
+			return response, err
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package endpoint
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+)
+
+func withIdempotencyKey(key string) context.Context {
+	return context.WithValue(context.Background(), ContextKeyIdempotencyKey, key)
+}
+
+// countingEndpoint returns a task ID per call. Requests named 'fail' get a failed response
+func countingEndpoint(calls *int) func(ctx context.Context, request interface{}) (interface{}, error) {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		*calls++
+		req := request.(VMDeployRequest)
+		switch req.Name {
+		case "fail":
+			return VMDeployResponse{Err: errors.New("Virtual Machine 'fail' already exist")}, nil
+		case "transport":
+			return nil, errors.New("could not parse request")
+		}
+		return VMDeployResponse{JID: strings.Repeat("t", *calls)}, nil
+	}
+}
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	tests := []struct {
+		name      string
+		first     VMDeployRequest
+		second    VMDeployRequest
+		wantCalls int
+		wantErr   error
+	}{
+		{
+			name:      "replay",
+			first:     VMDeployRequest{Name: "vm", Networks: map[string]string{"a": "1", "b": "2"}},
+			second:    VMDeployRequest{Name: "vm", Networks: map[string]string{"b": "2", "a": "1"}},
+			wantCalls: 1,
+		},
+		{
+			name:      "different body",
+			first:     VMDeployRequest{Name: "vm"},
+			second:    VMDeployRequest{Name: "other"},
+			wantCalls: 1,
+			wantErr:   errIdempotencyKeyReused,
+		},
+		{
+			name:      "failed response releases key",
+			first:     VMDeployRequest{Name: "fail"},
+			second:    VMDeployRequest{Name: "vm"},
+			wantCalls: 2,
+		},
+		{
+			name:      "transport error releases key",
+			first:     VMDeployRequest{Name: "transport"},
+			second:    VMDeployRequest{Name: "vm"},
+			wantCalls: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			keys := newIdempotencyKeys(time.Hour)
+			e := idempotencyMiddleware(keys, "VMDeploy", log.NewNopLogger())(countingEndpoint(&calls))
+			ctx := withIdempotencyKey("key")
+
+			first, _ := e(ctx, tt.first)
+			second, err := e(ctx, tt.second)
+			if err != tt.wantErr {
+				t.Fatalf("idempotencyMiddleware() error = %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("idempotencyMiddleware() endpoint calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if tt.wantErr == nil && tt.wantCalls == 1 && !reflect.DeepEqual(first, second) {
+				t.Errorf("idempotencyMiddleware() replayed %+v, want %+v", second, first)
+			}
+		})
+	}
+}
+
+func TestIdempotencyMiddleware_WithoutKey(t *testing.T) {
+	calls := 0
+	keys := newIdempotencyKeys(time.Hour)
+	e := idempotencyMiddleware(keys, "VMDeploy", log.NewNopLogger())(countingEndpoint(&calls))
+
+	e(context.Background(), VMDeployRequest{Name: "vm"}) //nolint: errcheck
+	e(context.Background(), VMDeployRequest{Name: "vm"}) //nolint: errcheck
+
+	if calls != 2 {
+		t.Errorf("idempotencyMiddleware() endpoint calls = %d, want 2", calls)
+	}
+}
+
+func TestIdempotencyMiddleware_OtherEndpoint(t *testing.T) {
+	calls := 0
+	keys := newIdempotencyKeys(time.Hour)
+	deploy := idempotencyMiddleware(keys, "VMDeploy", log.NewNopLogger())(countingEndpoint(&calls))
+	other := idempotencyMiddleware(keys, "VMClone", log.NewNopLogger())(countingEndpoint(&calls))
+	ctx := withIdempotencyKey("key")
+
+	deploy(ctx, VMDeployRequest{Name: "vm"}) //nolint: errcheck
+	if _, err := other(ctx, VMDeployRequest{Name: "vm"}); err != errIdempotencyKeyReused {
+		t.Errorf("idempotencyMiddleware() error = %v, want %v", err, errIdempotencyKeyReused)
+	}
+}
+
+func TestIdempotencyMiddleware_InProgress(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	slow := func(ctx context.Context, request interface{}) (interface{}, error) {
+		close(started)
+		<-release
+		return VMDeployResponse{JID: "task"}, nil
+	}
+
+	keys := newIdempotencyKeys(time.Hour)
+	e := idempotencyMiddleware(keys, "VMDeploy", log.NewNopLogger())(slow)
+	ctx := withIdempotencyKey("key")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		e(ctx, VMDeployRequest{Name: "vm"}) //nolint: errcheck
+	}()
+	<-started
+
+	_, err := e(ctx, VMDeployRequest{Name: "vm"})
+	if err != errIdempotencyKeyInProgress {
+		t.Errorf("idempotencyMiddleware() error = %v, want %v", err, errIdempotencyKeyInProgress)
+	}
+	if code := err.(idempotencyError).StatusCode(); code != http.StatusConflict {
+		t.Errorf("idempotencyMiddleware() status code = %d, want %d", code, http.StatusConflict)
+	}
+
+	close(release)
+	<-done
+
+	res, err := e(ctx, VMDeployRequest{Name: "vm"})
+	if err != nil || res.(VMDeployResponse).JID != "task" {
+		t.Errorf("idempotencyMiddleware() = %+v, %v after the first request finished", res, err)
+	}
+}
+
+func TestIdempotencyKeys_Expire(t *testing.T) {
+	keys := newIdempotencyKeys(time.Millisecond)
+
+	keys.begin("key", "a") //nolint: errcheck
+	keys.finish("key", VMDeployResponse{JID: "task"}, "", nil)
+	time.Sleep(5 * time.Millisecond)
+
+	if _, _, found, err := keys.begin("key", "b"); found || err != nil {
+		t.Errorf("idempotencyKeys.begin() found = %v, err = %v after the key expired", found, err)
+	}
+}
+
+func TestIdempotencyMiddleware_Upload(t *testing.T) {
+	tests := []struct {
+		name      string
+		first     string
+		second    string
+		wantCalls int
+		wantErr   error
+	}{
+		{"same file", "ova-content", "ova-content", 1, nil},
+		{"different file", "ova-content", "other-content", 1, errIdempotencyKeyReused},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			upload := func(ctx context.Context, request interface{}) (interface{}, error) {
+				calls++
+				// the service reads only a part of the body
+				buf := make([]byte, 3)
+				request.(OVAUploadRequest).Body.Read(buf) //nolint: errcheck
+				return OVAUploadResponse{ID: "upload"}, nil
+			}
+
+			keys := newIdempotencyKeys(time.Hour)
+			e := idempotencyMiddleware(keys, "OVAUpload", log.NewNopLogger())(upload)
+			ctx := withIdempotencyKey("key")
+
+			e(ctx, OVAUploadRequest{Name: "a.ova", Body: strings.NewReader(tt.first)}) //nolint: errcheck
+
+			second := strings.NewReader(tt.second)
+			res, err := e(ctx, OVAUploadRequest{Name: "a.ova", Body: second})
+			if err != tt.wantErr {
+				t.Fatalf("idempotencyMiddleware() error = %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("idempotencyMiddleware() endpoint calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if rest, _ := ioutil.ReadAll(second); len(rest) != 0 {
+				t.Errorf("idempotencyMiddleware() left %d bytes of the repeated body unread", len(rest))
+			}
+			if tt.wantErr == nil && res.(OVAUploadResponse).ID != "upload" {
+				t.Errorf("idempotencyMiddleware() replayed %+v", res)
+			}
+		})
+	}
+}
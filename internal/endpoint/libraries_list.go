@@ -0,0 +1,54 @@
+package endpoint
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/endpoint"
+
+	"github.com/vterdunov/janna-api/internal/service"
+)
+
+// MakeLibrariesListEndpoint returns an endpoint via the passed service
+func MakeLibrariesListEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		libraries, err := s.LibrariesList(ctx)
+		if err != nil {
+			return LibrariesListResponse{Err: err}, nil
+		}
+
+		res := LibrariesListResponse{
+			Libraries: make([]Library, 0, len(libraries)),
+		}
+		for _, l := range libraries {
+			res.Libraries = append(res.Libraries, Library{
+				ID:          l.ID,
+				Name:        l.Name,
+				Type:        l.Type,
+				Description: l.Description,
+			})
+		}
+
+		return res, nil
+	}
+}
+
+// LibrariesListRequest collects the request parameters for the LibrariesList method
+type LibrariesListRequest struct{}
+
+// LibrariesListResponse collects the response values for the LibrariesList method
+type LibrariesListResponse struct {
+	Libraries []Library `json:"libraries"`
+	Err       error     `json:"error,omitempty"`
+}
+
+type Library struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+}
+
+// Failed implements Failer
+func (r LibrariesListResponse) Failed() error {
+	return r.Err
+}
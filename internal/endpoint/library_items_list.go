@@ -0,0 +1,69 @@
+package endpoint
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-kit/kit/endpoint"
+
+	"github.com/vterdunov/janna-api/internal/service"
+	"github.com/vterdunov/janna-api/internal/types"
+)
+
+// MakeLibraryItemsListEndpoint returns an endpoint via the passed service
+func MakeLibraryItemsListEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(LibraryItemsListRequest)
+		if !ok {
+			return nil, errors.New("could not parse request")
+		}
+
+		params := &types.LibraryItemsListParams{
+			Library: req.Library,
+		}
+
+		items, err := s.LibraryItemsList(ctx, params)
+		if err != nil {
+			return LibraryItemsListResponse{Err: err}, nil
+		}
+
+		res := LibraryItemsListResponse{
+			Items: make([]LibraryItem, 0, len(items)),
+		}
+		for _, i := range items {
+			res.Items = append(res.Items, LibraryItem{
+				ID:          i.ID,
+				Name:        i.Name,
+				Type:        i.Type,
+				Size:        i.Size,
+				Description: i.Description,
+			})
+		}
+
+		return res, nil
+	}
+}
+
+// LibraryItemsListRequest collects the request parameters for the LibraryItemsList method
+type LibraryItemsListRequest struct {
+	Library string
+}
+
+// LibraryItemsListResponse collects the response values for the LibraryItemsList method
+type LibraryItemsListResponse struct {
+	Items []LibraryItem `json:"items"`
+	Err   error         `json:"error,omitempty"`
+}
+
+type LibraryItem struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Size        int64  `json:"size"`
+	Description string `json:"description,omitempty"`
+}
+
+// Failed implements Failer
+func (r LibraryItemsListResponse) Failed() error {
+	return r.Err
+}
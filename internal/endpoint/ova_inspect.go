@@ -0,0 +1,146 @@
+package endpoint
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-kit/kit/endpoint"
+
+	"github.com/vterdunov/janna-api/internal/service"
+	"github.com/vterdunov/janna-api/internal/types"
+)
+
+// MakeOVAInspectEndpoint returns an endpoint via the passed service
+func MakeOVAInspectEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(OVAInspectRequest)
+		if !ok {
+			return nil, errors.New("could not parse request")
+		}
+
+		if req.OVAURL == "" {
+			return OVAInspectResponse{Err: errors.New("invalid arguments. Pass 'ova_url'")}, nil
+		}
+
+		params := &types.OVAInspectParams{
+			OVAURL: req.OVAURL,
+		}
+
+		info, err := s.OVAInspect(ctx, params)
+		if err != nil {
+			return OVAInspectResponse{Err: err}, nil
+		}
+
+		res := OVAInspectResponse{
+			Name:              info.Name,
+			Annotation:        info.Annotation,
+			Networks:          make([]OVANetwork, 0, len(info.Networks)),
+			Disks:             make([]OVADisk, 0, len(info.Disks)),
+			Properties:        make([]OVAProperty, 0, len(info.Properties)),
+			DeploymentOptions: make([]OVADeploymentOption, 0, len(info.DeploymentOptions)),
+			Hardware: OVAHardware{
+				VirtualSystemType: info.Hardware.VirtualSystemType,
+				NumCPU:            info.Hardware.NumCPU,
+				MemoryMB:          info.Hardware.MemoryMB,
+			},
+		}
+
+		for _, n := range info.Networks {
+			res.Networks = append(res.Networks, OVANetwork{
+				Name:        n.Name,
+				Description: n.Description,
+			})
+		}
+
+		for _, d := range info.Disks {
+			res.Disks = append(res.Disks, OVADisk{
+				ID:                 d.ID,
+				File:               d.File,
+				CapacityBytes:      d.CapacityBytes,
+				PopulatedSizeBytes: d.PopulatedSizeBytes,
+				FileSizeBytes:      d.FileSizeBytes,
+			})
+		}
+
+		for _, p := range info.Properties {
+			res.Properties = append(res.Properties, OVAProperty{
+				Key:              p.Key,
+				Type:             p.Type,
+				Label:            p.Label,
+				Description:      p.Description,
+				Default:          p.Default,
+				UserConfigurable: p.UserConfigurable,
+				Password:         p.Password,
+			})
+		}
+
+		for _, o := range info.DeploymentOptions {
+			res.DeploymentOptions = append(res.DeploymentOptions, OVADeploymentOption{
+				ID:          o.ID,
+				Label:       o.Label,
+				Description: o.Description,
+				Default:     o.Default,
+			})
+		}
+
+		return res, nil
+	}
+}
+
+// OVAInspectRequest collects the request parameters for the OVAInspect method
+type OVAInspectRequest struct {
+	OVAURL string `json:"ova_url"`
+}
+
+// OVAInspectResponse collects the response values for the OVAInspect method
+type OVAInspectResponse struct {
+	Name              string                `json:"name,omitempty"`
+	Annotation        string                `json:"annotation,omitempty"`
+	Networks          []OVANetwork          `json:"networks,omitempty"`
+	Disks             []OVADisk             `json:"disks,omitempty"`
+	Properties        []OVAProperty         `json:"properties,omitempty"`
+	DeploymentOptions []OVADeploymentOption `json:"deployment_options,omitempty"`
+	Hardware          OVAHardware           `json:"hardware"`
+	Err               error                 `json:"error,omitempty"`
+}
+
+type OVANetwork struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+type OVADisk struct {
+	ID                 string `json:"id"`
+	File               string `json:"file,omitempty"`
+	CapacityBytes      int64  `json:"capacity_bytes"`
+	PopulatedSizeBytes int64  `json:"populated_size_bytes,omitempty"`
+	FileSizeBytes      int64  `json:"file_size_bytes,omitempty"`
+}
+
+type OVAProperty struct {
+	Key              string `json:"key"`
+	Type             string `json:"type"`
+	Label            string `json:"label,omitempty"`
+	Description      string `json:"description,omitempty"`
+	Default          string `json:"default,omitempty"`
+	UserConfigurable bool   `json:"user_configurable"`
+	Password         bool   `json:"password"`
+}
+
+type OVADeploymentOption struct {
+	ID          string `json:"id"`
+	Label       string `json:"label,omitempty"`
+	Description string `json:"description,omitempty"`
+	Default     bool   `json:"default"`
+}
+
+type OVAHardware struct {
+	VirtualSystemType string `json:"virtual_system_type,omitempty"`
+	NumCPU            int64  `json:"num_cpu"`
+	MemoryMB          int64  `json:"memory_mb"`
+}
+
+// Failed implements Failer
+func (r OVAInspectResponse) Failed() error {
+	return r.Err
+}
@@ -0,0 +1,79 @@
+package endpoint
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+
+	"github.com/vterdunov/janna-api/internal/service"
+	"github.com/vterdunov/janna-api/internal/types"
+)
+
+// MakeOVAUploadEndpoint returns an endpoint via the passed service
+func MakeOVAUploadEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(OVAUploadRequest)
+		if !ok {
+			return nil, errors.New("could not parse request")
+		}
+
+		if req.Body == nil {
+			return OVAUploadResponse{Err: errors.New("invalid arguments. Pass OVA file in request body or in 'file' form field")}, nil
+		}
+
+		params := &types.OVAUploadParams{
+			Name: req.Name,
+			Body: req.Body,
+		}
+
+		u, err := s.OVAUpload(ctx, params)
+		if err != nil {
+			return OVAUploadResponse{Err: err}, nil
+		}
+
+		return OVAUploadResponse{
+			ID:        u.ID,
+			OVAURL:    service.UploadScheme + u.ID,
+			Name:      u.Name,
+			Size:      u.Size,
+			CreatedAt: u.CreatedAt,
+			ExpiresAt: u.ExpiresAt,
+		}, nil
+	}
+}
+
+// OVAUploadRequest collects the request parameters for the OVAUpload method
+type OVAUploadRequest struct {
+	Name string
+	// Body is read by the service. It is nil if the request has no file
+	Body io.Reader `json:"-"`
+}
+
+func (r OVAUploadRequest) body() io.Reader {
+	return r.Body
+}
+
+func (r OVAUploadRequest) withBody(body io.Reader) interface{} {
+	r.Body = body
+	return r
+}
+
+// OVAUploadResponse collects the response values for the OVAUpload method
+type OVAUploadResponse struct {
+	ID string `json:"id,omitempty"`
+	// OVAURL is a reference to pass to 'ova_url' of the deploy request
+	OVAURL    string    `json:"ova_url,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	Size      int64     `json:"size,omitempty"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+	Err       error     `json:"error,omitempty"`
+}
+
+// Failed implements Failer
+func (r OVAUploadResponse) Failed() error {
+	return r.Err
+}
@@ -0,0 +1,43 @@
+package endpoint
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-kit/kit/endpoint"
+
+	"github.com/vterdunov/janna-api/internal/service"
+	"github.com/vterdunov/janna-api/internal/types"
+)
+
+// MakeOVAUploadDeleteEndpoint returns an endpoint via the passed service
+func MakeOVAUploadDeleteEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(OVAUploadDeleteRequest)
+		if !ok {
+			return nil, errors.New("could not parse request")
+		}
+
+		params := &types.OVAUploadDeleteParams{
+			ID: req.ID,
+		}
+
+		err := s.OVAUploadDelete(ctx, params)
+		return OVAUploadDeleteResponse{Err: err}, nil
+	}
+}
+
+// OVAUploadDeleteRequest collects the request parameters for the OVAUploadDelete method
+type OVAUploadDeleteRequest struct {
+	ID string
+}
+
+// OVAUploadDeleteResponse collects the response values for the OVAUploadDelete method
+type OVAUploadDeleteResponse struct {
+	Err error `json:"error,omitempty"`
+}
+
+// Failed implements Failer
+func (r OVAUploadDeleteResponse) Failed() error {
+	return r.Err
+}
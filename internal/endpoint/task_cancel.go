@@ -0,0 +1,45 @@
+package endpoint
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-kit/kit/endpoint"
+
+	"github.com/vterdunov/janna-api/internal/service"
+	"github.com/vterdunov/janna-api/internal/types"
+)
+
+// MakeTaskCancelEndpoint returns an endpoint via the passed service
+func MakeTaskCancelEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req, ok := request.(TaskCancelRequest)
+		if !ok {
+			return nil, errors.New("could not parse request")
+		}
+
+		params := &types.TaskCancelParams{
+			TaskID:    req.TaskID,
+			DestroyVM: req.DestroyVM,
+		}
+
+		err = s.TaskCancel(ctx, params)
+		return TaskCancelResponse{Err: err}, nil
+	}
+}
+
+// TaskCancelRequest collects the request parameters for the TaskCancel method
+type TaskCancelRequest struct {
+	TaskID    string
+	DestroyVM bool `json:"destroy_vm"`
+}
+
+// TaskCancelResponse collects the response values for the TaskCancel method
+type TaskCancelResponse struct {
+	Err error `json:"error,omitempty"`
+}
+
+// Failed implements Failer
+func (r TaskCancelResponse) Failed() error {
+	return r.Err
+}
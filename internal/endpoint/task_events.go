@@ -0,0 +1,82 @@
+package endpoint
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-kit/kit/endpoint"
+
+	"github.com/vterdunov/janna-api/internal/domain"
+	"github.com/vterdunov/janna-api/internal/service"
+	"github.com/vterdunov/janna-api/internal/types"
+)
+
+// Task event names
+const (
+	taskEventStage    = "stage"
+	taskEventProgress = "progress"
+	taskEventResult   = "result"
+)
+
+// MakeTaskEventsEndpoint returns an endpoint via the passed service
+func MakeTaskEventsEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req, ok := request.(TaskEventsRequest)
+		if !ok {
+			return nil, errors.New("could not parse request")
+		}
+
+		tasks, err := s.TaskEvents(ctx, req.TaskID)
+		if err != nil {
+			return TaskEventsResponse{Err: err}, nil
+		}
+
+		events := make(chan TaskEvent)
+		go func() {
+			defer close(events)
+
+			var prev domain.TaskStage
+			for task := range tasks {
+				name := taskEventProgress
+				switch {
+				case task.Stage.IsFinal():
+					name = taskEventResult
+				case task.Stage != prev:
+					name = taskEventStage
+				}
+				prev = task.Stage
+
+				select {
+				case events <- TaskEvent{Name: name, Task: types.NewTask(&task)}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+
+		return TaskEventsResponse{Events: events}, nil
+	}
+}
+
+// TaskEventsRequest collects the request parameters for the TaskEvents method
+type TaskEventsRequest struct {
+	TaskID string
+}
+
+// TaskEventsResponse collects the response values for the TaskEvents method
+type TaskEventsResponse struct {
+	Events <-chan TaskEvent `json:"-"`
+	Err    error            `json:"error,omitempty"`
+}
+
+// Failed implements Failer
+func (r TaskEventsResponse) Failed() error {
+	return r.Err
+}
+
+// TaskEvent is a single change of a background task.
+// Name is one of 'stage', 'progress' or 'result'
+type TaskEvent struct {
+	Name string
+	Task types.Task
+}
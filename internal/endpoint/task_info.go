@@ -3,10 +3,19 @@ package endpoint
 import (
 	"context"
 	"errors"
+	"fmt"
 
 	"github.com/go-kit/kit/endpoint"
 
+	"github.com/vterdunov/janna-api/internal/domain"
 	"github.com/vterdunov/janna-api/internal/service"
+	"github.com/vterdunov/janna-api/internal/types"
+)
+
+// Task info views
+const (
+	taskViewFull   = "full"
+	taskViewLegacy = "legacy"
 )
 
 // MakeTaskInfoEndpoint returns an endpoint via the passed service
@@ -17,23 +26,37 @@ func MakeTaskInfoEndpoint(s service.Service) endpoint.Endpoint {
 			return nil, errors.New("could not parse request")
 		}
 
-		status, err := s.TaskInfo(ctx, req.TaskID)
+		switch req.View {
+		case "", taskViewFull, taskViewLegacy:
+		default:
+			return TaskInfoResponse{Err: fmt.Errorf("invalid view '%s'. Possible values are '%s', '%s'", req.View, taskViewFull, taskViewLegacy)}, nil
+		}
+
+		task, err := s.TaskInfo(ctx, req.TaskID)
 		if err != nil {
-			return TaskInfoResponse{Status: nil, Err: err}, nil
+			return TaskInfoResponse{Err: err}, nil
 		}
 
-		return TaskInfoResponse{Status: status, Err: err}, nil
+		if req.View == taskViewLegacy {
+			return TaskInfoResponse{Legacy: legacyTaskStatus(task)}, nil
+		}
+
+		t := types.NewTask(task)
+		return TaskInfoResponse{Task: &t}, nil
 	}
 }
 
 // TaskInfoRequest collects the request parameters for the TaskInfo method
 type TaskInfoRequest struct {
 	TaskID string
+	View   string
 }
 
-// TaskInfoResponse collects the response values for the TaskInfo method
+// TaskInfoResponse collects the response values for the TaskInfo method.
+// Only one of Task or Legacy is set, according to the requested view.
 type TaskInfoResponse struct {
-	Status map[string]interface{}
+	Task   *types.Task
+	Legacy map[string]interface{}
 	Err    error `json:"error,omitempty"`
 }
 
@@ -41,3 +64,27 @@ type TaskInfoResponse struct {
 func (r TaskInfoResponse) Failed() error {
 	return r.Err
 }
+
+// legacyTaskStatus returns the task in the free-form format
+// that was used before the typed task record was introduced
+func legacyTaskStatus(t *domain.Task) map[string]interface{} {
+	status := make(map[string]interface{})
+
+	if t.Stage != "" {
+		status["stage"] = string(t.Stage)
+	}
+
+	if t.Message != "" {
+		status["message"] = t.Message
+	}
+
+	if t.Error != nil {
+		status["error"] = t.Error.Message
+	}
+
+	if len(t.Result.IPs) != 0 {
+		status["ip"] = t.Result.IPs
+	}
+
+	return status
+}
@@ -0,0 +1,97 @@
+package endpoint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+
+	"github.com/vterdunov/janna-api/internal/domain"
+	"github.com/vterdunov/janna-api/internal/service"
+	"github.com/vterdunov/janna-api/internal/types"
+)
+
+const (
+	defaultTasksListLimit = 100
+	maxTasksListLimit     = 1000
+)
+
+// MakeTasksListEndpoint returns an endpoint via the passed service
+func MakeTasksListEndpoint(s service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req, ok := request.(TasksListRequest)
+		if !ok {
+			return nil, errors.New("could not parse request")
+		}
+
+		if req.Stage != "" && !domain.TaskStage(req.Stage).IsValid() {
+			return TasksListResponse{Err: fmt.Errorf("invalid stage '%s'. Possible values are %v", req.Stage, domain.TaskStages)}, nil
+		}
+
+		if req.Offset < 0 || req.Limit < 0 {
+			return TasksListResponse{Err: errors.New("offset and limit must not be negative")}, nil
+		}
+
+		limit := req.Limit
+		if limit == 0 {
+			limit = defaultTasksListLimit
+		}
+		if limit > maxTasksListLimit {
+			limit = maxTasksListLimit
+		}
+
+		params := &types.TasksListParams{
+			Stage:         req.Stage,
+			VMName:        req.VMName,
+			RequestID:     req.RequestID,
+			CreatedAfter:  req.CreatedAfter,
+			CreatedBefore: req.CreatedBefore,
+			Offset:        req.Offset,
+			Limit:         limit,
+		}
+
+		tasks, total, err := s.TasksList(ctx, params)
+		if err != nil {
+			return TasksListResponse{Err: err}, nil
+		}
+
+		tt := make([]types.Task, 0, len(tasks))
+		for i := range tasks {
+			tt = append(tt, types.NewTask(&tasks[i]))
+		}
+
+		return TasksListResponse{
+			Tasks:  tt,
+			Total:  total,
+			Offset: params.Offset,
+			Limit:  params.Limit,
+		}, nil
+	}
+}
+
+// TasksListRequest collects the request parameters for the TasksList method
+type TasksListRequest struct {
+	Stage         string
+	VMName        string
+	RequestID     string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	Offset        int
+	Limit         int
+}
+
+// TasksListResponse collects the response values for the TasksList method
+type TasksListResponse struct {
+	Tasks  []types.Task `json:"tasks"`
+	Total  int          `json:"total"`
+	Offset int          `json:"offset"`
+	Limit  int          `json:"limit"`
+	Err    error        `json:"error,omitempty"`
+}
+
+// Failed implements Failer
+func (r TasksListResponse) Failed() error {
+	return r.Err
+}
@@ -4,10 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/url"
 
 	"github.com/go-kit/kit/endpoint"
 	"github.com/go-kit/kit/log"
 
+	"github.com/vterdunov/janna-api/internal/domain"
 	"github.com/vterdunov/janna-api/internal/service"
 	"github.com/vterdunov/janna-api/internal/types"
 )
@@ -23,23 +25,94 @@ func MakeVMDeployEndpoint(s service.Service, logger log.Logger) endpoint.Endpoin
 		logger.Log("msg", "incoming request params", "params", req.String())
 
 		// Minimal validating incoming params
-		if req.Name == "" || req.OVAURL == "" {
+		isClone := req.Clone.UUID != "" || req.Clone.Path != ""
+		isLibrary := req.Library.Name != "" || req.Library.Item != ""
+		if req.Name == "" || (req.OVAURL == "" && !isClone && !isLibrary) {
 			return VMDeployResponse{JID: "", Err: errors.New("invalid arguments. Pass reqired arguments")}, nil
 		}
 
+		sources := 0
+		for _, ok := range []bool{req.OVAURL != "", isClone, isLibrary} {
+			if ok {
+				sources++
+			}
+		}
+		if sources > 1 {
+			return VMDeployResponse{Err: errors.New("invalid arguments. Pass only one of 'ova_url', 'clone', 'library'")}, nil
+		}
+
+		if req.Clone.UUID != "" && req.Clone.Path != "" {
+			return VMDeployResponse{Err: errors.New("invalid clone source. Pass either 'uuid' or 'path'")}, nil
+		}
+
+		if isLibrary && (req.Library.Name == "" || req.Library.Item == "") {
+			return VMDeployResponse{Err: errors.New("invalid library source. Pass both 'name' and 'item'")}, nil
+		}
+
+		if req.DiskProvisioning != "" {
+			switch {
+			case isClone:
+				return VMDeployResponse{Err: errors.New("invalid arguments. 'disk_provisioning' is not applied to clones")}, nil
+			case isLibrary && !types.IsValidLibraryDiskProvisioning(req.DiskProvisioning):
+				return VMDeployResponse{Err: fmt.Errorf("invalid disk provisioning '%s'. Possible values for library items are %v", req.DiskProvisioning, types.LibraryDiskProvisioningTypes)}, nil
+			case !types.IsValidDiskProvisioning(req.DiskProvisioning):
+				return VMDeployResponse{Err: fmt.Errorf("invalid disk provisioning '%s'. Possible values are %v", req.DiskProvisioning, types.DiskProvisioningTypes)}, nil
+			}
+		}
+
+		if req.Checksum != "" && (isClone || isLibrary) {
+			return VMDeployResponse{Err: errors.New("invalid arguments. 'checksum' is applied only to 'ova_url'")}, nil
+		}
+
+		if len(req.Properties) != 0 && (isClone || isLibrary) {
+			return VMDeployResponse{Err: errors.New("invalid arguments. 'properties' are applied only to 'ova_url'")}, nil
+		}
+
+		strategy := domain.DatastoreStrategy(req.Datastores.Strategy)
+		if strategy != "" && !strategy.IsValid() {
+			return VMDeployResponse{Err: fmt.Errorf("invalid datastore strategy '%s'. Possible values are %v", strategy, domain.DatastoreStrategies)}, nil
+		}
+
+		policy := domain.CleanupPolicy(req.CleanupPolicy)
+		if policy != "" && !policy.IsValid() {
+			return VMDeployResponse{Err: fmt.Errorf("invalid cleanup policy '%s'. Possible values are %v", policy, domain.CleanupPolicies)}, nil
+		}
+
+		customization := req.Customization.params()
+		if err := customization.Validate(); err != nil {
+			return VMDeployResponse{Err: err}, nil
+		}
+
+		hardware := req.Hardware.params()
+		if err := hardware.Validate(); err != nil {
+			return VMDeployResponse{Err: err}, nil
+		}
+
+		if req.Callback.URL != "" {
+			u, err := url.Parse(req.Callback.URL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return VMDeployResponse{Err: errors.New("invalid callback url. Pass absolute http or https url")}, nil
+			}
+		}
+
 		params := &types.VMDeployParams{
-			Name:       req.Name,
-			OVAURL:     req.OVAURL,
-			Datacenter: req.Datacenter,
-			Folder:     req.Folder,
-			Annotation: req.Annotation,
-			Networks:   req.Networks,
+			Name:             req.Name,
+			OVAURL:           req.OVAURL,
+			Datacenter:       req.Datacenter,
+			Folder:           req.Folder,
+			Annotation:       req.Annotation,
+			Networks:         req.Networks,
+			DiskProvisioning: req.DiskProvisioning,
+			Properties:       req.Properties,
+			Checksum:         req.Checksum,
 			Datastores: struct {
-				Type  string
-				Names []string
+				Type     string
+				Names    []string
+				Strategy domain.DatastoreStrategy
 			}{
-				Type:  req.Datastores.Type,
-				Names: req.Datastores.Names,
+				Type:     req.Datastores.Type,
+				Names:    req.Datastores.Names,
+				Strategy: strategy,
 			},
 			ComputerResources: struct {
 				Path string
@@ -48,10 +121,42 @@ func MakeVMDeployEndpoint(s service.Service, logger log.Logger) endpoint.Endpoin
 				Path: req.ComputerResources.Path,
 				Type: req.ComputerResources.Type,
 			},
+			Clone: struct {
+				UUID string
+				Path string
+			}{
+				UUID: req.Clone.UUID,
+				Path: req.Clone.Path,
+			},
+			Library: struct {
+				Name string
+				Item string
+			}{
+				Name: req.Library.Name,
+				Item: req.Library.Item,
+			},
+			Callback: struct {
+				URL    string
+				Secret string
+			}{
+				URL:    req.Callback.URL,
+				Secret: req.Callback.Secret,
+			},
+			CleanupPolicy: policy,
+			Customization: customization,
+			Hardware:      hardware,
 		}
 
 		params.FillEmptyFields(s.GetConfig())
 
+		if req.DryRun {
+			plan, err := s.VMDeployPlan(ctx, params)
+			if err != nil {
+				return VMDeployResponse{Err: err}, nil
+			}
+			return VMDeployResponse{Plan: newDeployPlan(plan)}, nil
+		}
+
 		jid, err := s.VMDeploy(ctx, params)
 
 		return VMDeployResponse{JID: jid, Err: err}, nil
@@ -62,17 +167,28 @@ func MakeVMDeployEndpoint(s service.Service, logger log.Logger) endpoint.Endpoin
 type VMDeployRequest struct {
 	Name              string            `json:"name"`
 	OVAURL            string            `json:"ova_url"`
+	Checksum          string            `json:"checksum,omitempty"`
 	Datacenter        string            `json:"datacenter,omitempty"`
 	Folder            string            `json:"folder,omitempty"`
 	Annotation        string            `json:"annotation"`
 	Networks          map[string]string `json:"networks,omitempty"`
+	DiskProvisioning  string            `json:"disk_provisioning,omitempty"`
+	Properties        map[string]string `json:"properties,omitempty"`
+	CleanupPolicy     string            `json:"cleanup_policy,omitempty"`
+	DryRun            bool              `json:"dry_run,omitempty"`
 	Datastores        `json:"datastores"`
 	ComputerResources `json:"computer_resources"`
+	Clone             `json:"clone"`
+	Library           LibrarySource `json:"library"`
+	Callback          `json:"callback"`
+	Customization     Customization `json:"customization"`
+	Hardware          Hardware      `json:"hardware"`
 }
 
 type Datastores struct {
-	Type  string
-	Names []string
+	Type     string
+	Names    []string
+	Strategy string
 }
 
 type ComputerResources struct {
@@ -80,15 +196,156 @@ type ComputerResources struct {
 	Type string `json:"type"`
 }
 
+// Clone is a template or a Virtual Machine to clone instead of OVA import
+type Clone struct {
+	UUID string `json:"uuid"`
+	Path string `json:"path"`
+}
+
+// LibrarySource is a Content Library OVF template to deploy instead of OVA import
+type LibrarySource struct {
+	Name string `json:"name"`
+	Item string `json:"item"`
+}
+
+// Callback is a webhook that receives the task record when the deploy is finished
+type Callback struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret"`
+}
+
+// Customization is a guest OS customization applied before the first power on
+type Customization struct {
+	SpecName    string             `json:"spec_name"`
+	Hostname    string             `json:"hostname"`
+	Domain      string             `json:"domain"`
+	DNSServers  []string           `json:"dns_servers"`
+	DNSSuffixes []string           `json:"dns_suffixes"`
+	NICs        []CustomizationNIC `json:"nics"`
+}
+
+// CustomizationNIC keeps network settings of a single network adapter
+type CustomizationNIC struct {
+	IP         string   `json:"ip"`
+	Netmask    string   `json:"netmask"`
+	Gateways   []string `json:"gateways"`
+	DNSServers []string `json:"dns_servers"`
+}
+
+func (c *Customization) params() types.VMCustomization {
+	nics := make([]types.VMCustomizationNIC, 0, len(c.NICs))
+	for _, nic := range c.NICs {
+		nics = append(nics, types.VMCustomizationNIC{
+			IP:         nic.IP,
+			Netmask:    nic.Netmask,
+			Gateways:   nic.Gateways,
+			DNSServers: nic.DNSServers,
+		})
+	}
+
+	return types.VMCustomization{
+		SpecName:    c.SpecName,
+		Hostname:    c.Hostname,
+		Domain:      c.Domain,
+		DNSServers:  c.DNSServers,
+		DNSSuffixes: c.DNSSuffixes,
+		NICs:        nics,
+	}
+}
+
+// Hardware overrides the hardware of the Virtual Machine before the first power on
+type Hardware struct {
+	NumCPU         int32          `json:"num_cpu"`
+	CoresPerSocket int32          `json:"cores_per_socket"`
+	MemoryMB       int64          `json:"memory_mb"`
+	Disks          []HardwareDisk `json:"disks"`
+}
+
+// HardwareDisk resizes the existing disk with the index or adds a new disk if the index is omitted
+type HardwareDisk struct {
+	Index  *int  `json:"index"`
+	SizeGB int64 `json:"size_gb"`
+}
+
+func (h *Hardware) params() types.VMHardware {
+	disks := make([]types.VMHardwareDisk, 0, len(h.Disks))
+	for _, d := range h.Disks {
+		index := -1
+		if d.Index != nil {
+			index = *d.Index
+		}
+		disks = append(disks, types.VMHardwareDisk{
+			Index:  index,
+			SizeGB: d.SizeGB,
+		})
+	}
+
+	return types.VMHardware{
+		NumCPU:         h.NumCPU,
+		CoresPerSocket: h.CoresPerSocket,
+		MemoryMB:       h.MemoryMB,
+		Disks:          disks,
+	}
+}
+
 func (r *VMDeployRequest) String() string {
-	return fmt.Sprintf("name: %s, ova_url: %s, datastores: %s, networks: %s, datacenter: %s, computer_resources: %s, folder: %s, annotation: %s",
-		r.Name, r.OVAURL, r.Datastores, r.Networks, r.Datacenter, r.ComputerResources, r.Folder, r.Annotation)
+	return fmt.Sprintf("name: %s, ova_url: %s, checksum: %s, datastores: %s, networks: %s, disk_provisioning: %s, datacenter: %s, computer_resources: %s, folder: %s, annotation: %s, clone: %s, library: %s, cleanup_policy: %s, dry_run: %t, callback_url: %s, customization: %v, hardware: %v",
+		r.Name, r.OVAURL, r.Checksum, r.Datastores, r.Networks, r.DiskProvisioning, r.Datacenter, r.ComputerResources, r.Folder, r.Annotation, r.Clone, r.Library, r.CleanupPolicy, r.DryRun, r.Callback.URL, r.Customization, r.Hardware)
 }
 
 // VMDeployResponse fields
 type VMDeployResponse struct {
 	JID string `json:"task_id,omitempty"`
-	Err error  `json:"error,omitempty"`
+	// Plan is returned instead of the task ID for dry run requests
+	Plan *DeployPlan `json:"plan,omitempty"`
+	Err  error       `json:"error,omitempty"`
+}
+
+// DeployPlan describes where the Virtual Machine would be deployed
+type DeployPlan struct {
+	Name             string              `json:"name"`
+	Source           string              `json:"source"`
+	Datacenter       string              `json:"datacenter"`
+	Folder           string              `json:"folder"`
+	ResourcePool     string              `json:"resource_pool"`
+	Host             string              `json:"host,omitempty"`
+	Datastore        string              `json:"datastore"`
+	StorageDRS       bool                `json:"storage_drs"`
+	DiskProvisioning string              `json:"disk_provisioning,omitempty"`
+	Networks         []DeployPlanNetwork `json:"networks"`
+	Warnings         []string            `json:"warnings,omitempty"`
+}
+
+type DeployPlanNetwork struct {
+	Name    string `json:"name"`
+	Network string `json:"network"`
+	Kind    string `json:"kind"`
+}
+
+func newDeployPlan(p *domain.DeployPlan) *DeployPlan {
+	plan := &DeployPlan{
+		Name:             p.Name,
+		Source:           string(p.Source),
+		Datacenter:       p.Datacenter,
+		Folder:           p.Folder,
+		ResourcePool:     p.ResourcePool,
+		Host:             p.Host,
+		Datastore:        p.Datastore,
+		StorageDRS:       p.StorageDRS,
+		DiskProvisioning: p.DiskProvisioning,
+		Networks:         make([]DeployPlanNetwork, 0, len(p.Networks)),
+		Warnings:         p.Warnings,
+	}
+
+	for _, n := range p.Networks {
+		plan.Networks = append(plan.Networks, DeployPlanNetwork{
+			Name:    n.Name,
+			Network: n.Network,
+			Kind:    string(n.Kind),
+		})
+	}
+
+	return plan
 }
 
 // Failed implements Failer
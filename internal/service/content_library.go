@@ -0,0 +1,467 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/vapi/rest"
+	"github.com/vmware/govmomi/vim25/soap"
+	vmware_types "github.com/vmware/govmomi/vim25/types"
+
+	"github.com/vterdunov/janna-api/internal/domain"
+	"github.com/vterdunov/janna-api/internal/types"
+)
+
+// govmomi does not have a Content Library client yet, so the vSphere Automation REST API is called directly
+const (
+	libraryPath     = "/rest/com/vmware/content/library"
+	libraryItemPath = "/rest/com/vmware/content/library/item"
+	ovfDeployPath   = "/rest/vcenter/ovf/library-item"
+)
+
+// libraryClient is a Content Library client of the vSphere Automation API
+type libraryClient struct {
+	*rest.Client
+}
+
+// newLibraryClient logs in to the vSphere Automation API with Janna credentials.
+// The returned function closes the session.
+func (s *service) newLibraryClient(ctx context.Context) (*libraryClient, func(), error) {
+	u, err := soap.ParseURL(s.cfg.VMWare.URL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rc := rest.NewClient(s.Client)
+	if err := rc.Login(ctx, u.User); err != nil {
+		return nil, nil, errors.Wrap(err, "Could not login to vSphere Automation API")
+	}
+
+	logout := func() {
+		// the request context may be already cancelled
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		if err := rc.Logout(ctx); err != nil {
+			s.logger.Log("err", errors.Wrap(err, "Could not logout from vSphere Automation API"))
+		}
+	}
+
+	return &libraryClient{rc}, logout, nil
+}
+
+func (c *libraryClient) request(ctx context.Context, method, path string, query url.Values, body, res interface{}) error {
+	u := c.URL()
+	u.Path = path
+	u.RawQuery = query.Encode()
+
+	var b bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&b).Encode(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, u.String(), &b)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	// rest.Client.Do does not keep the response status, so the response is handled here
+	return c.Client.Client.Do(ctx, req, func(r *http.Response) error {
+		if r.StatusCode != http.StatusOK {
+			detail, _ := ioutil.ReadAll(io.LimitReader(r.Body, 4096))
+			return &libraryError{
+				method: method,
+				url:    u.String(),
+				status: r.Status,
+				code:   r.StatusCode,
+				detail: string(bytes.TrimSpace(detail)),
+			}
+		}
+
+		if res == nil {
+			return nil
+		}
+
+		// the API wraps every response into the 'value' field
+		val := struct {
+			Value interface{} `json:"value,omitempty"`
+		}{res}
+		return json.NewDecoder(r.Body).Decode(&val)
+	})
+}
+
+// libraryError is a failed vSphere Automation API request
+type libraryError struct {
+	method string
+	url    string
+	status string
+	code   int
+	detail string
+}
+
+func (e *libraryError) Error() string {
+	if e.detail == "" {
+		return fmt.Sprintf("%s %s: %s", e.method, e.url, e.status)
+	}
+	return fmt.Sprintf("%s %s: %s: %s", e.method, e.url, e.status, e.detail)
+}
+
+// isLibraryNotFound reports whether the API has not found the requested object
+func isLibraryNotFound(err error) bool {
+	e, ok := errors.Cause(err).(*libraryError)
+	return ok && e.code == http.StatusNotFound
+}
+
+type libraryInfo struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Description string `json:"description"`
+}
+
+type libraryItemInfo struct {
+	ID          string `json:"id"`
+	LibraryID   string `json:"library_id"`
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Size        int64  `json:"size"`
+	Description string `json:"description"`
+}
+
+func (c *libraryClient) libraries(ctx context.Context) ([]string, error) {
+	var ids []string
+	err := c.request(ctx, http.MethodGet, libraryPath, nil, nil, &ids)
+	return ids, err
+}
+
+func (c *libraryClient) library(ctx context.Context, id string) (*libraryInfo, error) {
+	var info libraryInfo
+	if err := c.request(ctx, http.MethodGet, libraryPath+"/id:"+id, nil, nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// findLibrary finds the library by name. The library ID is accepted too
+func (c *libraryClient) findLibrary(ctx context.Context, name string) (*libraryInfo, error) {
+	spec := struct {
+		Spec struct {
+			Name string `json:"name"`
+		} `json:"spec"`
+	}{}
+	spec.Spec.Name = name
+
+	var ids []string
+	query := url.Values{"~action": []string{"find"}}
+	if err := c.request(ctx, http.MethodPost, libraryPath, query, spec, &ids); err != nil {
+		return nil, err
+	}
+
+	switch len(ids) {
+	case 0:
+		info, err := c.library(ctx, name)
+		if isLibraryNotFound(err) {
+			return nil, fmt.Errorf("content library '%s' not found", name)
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "Could not get content library")
+		}
+		return info, nil
+	case 1:
+		return c.library(ctx, ids[0])
+	default:
+		return nil, fmt.Errorf("found %d content libraries with name '%s'. Pass the library ID", len(ids), name)
+	}
+}
+
+func (c *libraryClient) items(ctx context.Context, libraryID string) ([]string, error) {
+	var ids []string
+	query := url.Values{"library_id": []string{libraryID}}
+	err := c.request(ctx, http.MethodGet, libraryItemPath, query, nil, &ids)
+	return ids, err
+}
+
+func (c *libraryClient) item(ctx context.Context, id string) (*libraryItemInfo, error) {
+	var info libraryItemInfo
+	if err := c.request(ctx, http.MethodGet, libraryItemPath+"/id:"+id, nil, nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// findItem finds the item of the library by name. The item ID is accepted too
+func (c *libraryClient) findItem(ctx context.Context, libraryID, name string) (*libraryItemInfo, error) {
+	spec := struct {
+		Spec struct {
+			Name      string `json:"name"`
+			LibraryID string `json:"library_id"`
+		} `json:"spec"`
+	}{}
+	spec.Spec.Name = name
+	spec.Spec.LibraryID = libraryID
+
+	var ids []string
+	query := url.Values{"~action": []string{"find"}}
+	if err := c.request(ctx, http.MethodPost, libraryItemPath, query, spec, &ids); err != nil {
+		return nil, err
+	}
+
+	switch len(ids) {
+	case 0:
+		info, err := c.item(ctx, name)
+		if isLibraryNotFound(err) || (err == nil && info.LibraryID != libraryID) {
+			return nil, fmt.Errorf("content library item '%s' not found", name)
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "Could not get content library item")
+		}
+		return info, nil
+	case 1:
+		return c.item(ctx, ids[0])
+	default:
+		return nil, fmt.Errorf("found %d content library items with name '%s'. Pass the item ID", len(ids), name)
+	}
+}
+
+func (s *service) LibrariesList(ctx context.Context) ([]domain.Library, error) {
+	c, logout, err := s.newLibraryClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer logout()
+
+	ids, err := c.libraries(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not list content libraries")
+	}
+
+	libraries := make([]domain.Library, 0, len(ids))
+	for _, id := range ids {
+		info, err := c.library(ctx, id)
+		if err != nil {
+			return nil, errors.Wrap(err, "Could not get content library")
+		}
+
+		libraries = append(libraries, domain.Library{
+			ID:          info.ID,
+			Name:        info.Name,
+			Type:        info.Type,
+			Description: info.Description,
+		})
+	}
+
+	return libraries, nil
+}
+
+func (s *service) LibraryItemsList(ctx context.Context, params *types.LibraryItemsListParams) ([]domain.LibraryItem, error) {
+	c, logout, err := s.newLibraryClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer logout()
+
+	library, err := c.findLibrary(ctx, params.Library)
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := c.items(ctx, library.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not list content library items")
+	}
+
+	items := make([]domain.LibraryItem, 0, len(ids))
+	for _, id := range ids {
+		info, err := c.item(ctx, id)
+		if err != nil {
+			return nil, errors.Wrap(err, "Could not get content library item")
+		}
+
+		items = append(items, domain.LibraryItem{
+			ID:          info.ID,
+			Name:        info.Name,
+			Type:        info.Type,
+			Size:        info.Size,
+			Description: info.Description,
+		})
+	}
+
+	return items, nil
+}
+
+type ovfDeployTarget struct {
+	ResourcePoolID string `json:"resource_pool_id"`
+	HostID         string `json:"host_id,omitempty"`
+	FolderID       string `json:"folder_id,omitempty"`
+}
+
+// ovfSummary is the part of the library item OVF descriptor that applies to the deploy target
+type ovfSummary struct {
+	Name     string   `json:"name"`
+	Networks []string `json:"networks"`
+}
+
+// filter returns the networks and other settings the OVF template of the item declares for the target
+func (c *libraryClient) filter(ctx context.Context, itemID string, target ovfDeployTarget) (*ovfSummary, error) {
+	body := struct {
+		Target ovfDeployTarget `json:"target"`
+	}{target}
+
+	var summary ovfSummary
+	query := url.Values{"~action": []string{"filter"}}
+	if err := c.request(ctx, http.MethodPost, ovfDeployPath+"/id:"+itemID, query, body, &summary); err != nil {
+		return nil, errors.Wrap(err, "could not read OVF template of content library item")
+	}
+
+	return &summary, nil
+}
+
+type ovfDeploySpec struct {
+	Name                string        `json:"name"`
+	Annotation          string        `json:"annotation,omitempty"`
+	AcceptAllEULA       bool          `json:"accept_all_EULA"`
+	NetworkMappings     []ovfKeyValue `json:"network_mappings,omitempty"`
+	StorageProvisioning string        `json:"storage_provisioning,omitempty"`
+	DefaultDatastoreID  string        `json:"default_datastore_id,omitempty"`
+}
+
+// ovfKeyValue is a map entry. The API encodes maps as lists of entries
+type ovfKeyValue struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type ovfDeployResult struct {
+	Succeeded  bool `json:"succeeded"`
+	ResourceID *struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+	} `json:"resource_id"`
+	Error *struct {
+		Errors []struct {
+			Category string `json:"category"`
+			Message  *struct {
+				DefaultMessage string `json:"default_message"`
+			} `json:"message"`
+		} `json:"errors"`
+	} `json:"error"`
+}
+
+func (r *ovfDeployResult) err() error {
+	var msgs []string
+	if r.Error != nil {
+		for _, e := range r.Error.Errors {
+			if e.Message != nil && e.Message.DefaultMessage != "" {
+				msgs = append(msgs, e.Message.DefaultMessage)
+			} else if e.Category != "" {
+				msgs = append(msgs, e.Category)
+			}
+		}
+	}
+
+	if len(msgs) == 0 {
+		return errors.New("vCenter did not report the reason")
+	}
+
+	return errors.New(strings.Join(msgs, "; "))
+}
+
+// DeployLibraryItem deploys the OVF template from the Content Library.
+// The call returns when vCenter has finished the deploy.
+func (o *Deployment) DeployLibraryItem(ctx context.Context, c *libraryClient, libraryName, itemName, anno string) (*vmware_types.ManagedObjectReference, error) {
+	library, err := c.findLibrary(ctx, libraryName)
+	if err != nil {
+		return nil, err
+	}
+
+	item, err := c.findItem(ctx, library.ID, itemName)
+	if err != nil {
+		return nil, err
+	}
+
+	if item.Type != "ovf" {
+		return nil, fmt.Errorf("content library item '%s' is not an OVF template", itemName)
+	}
+
+	resolved, err := o.resolveLibraryNetworks(ctx, c, item.ID)
+	if err != nil {
+		return nil, err
+	}
+	o.setNetworks(resolved)
+
+	networks := make([]ovfKeyValue, 0, len(resolved))
+	for _, n := range resolved {
+		networks = append(networks, ovfKeyValue{Key: n.Name, Value: n.Ref.Value})
+	}
+
+	body := struct {
+		Target ovfDeployTarget `json:"target"`
+		Spec   ovfDeploySpec   `json:"deployment_spec"`
+	}{
+		Target: o.libraryTarget(),
+		Spec: ovfDeploySpec{
+			Name:                o.Name,
+			Annotation:          anno,
+			AcceptAllEULA:       true,
+			NetworkMappings:     networks,
+			StorageProvisioning: o.DiskProvisioning,
+			DefaultDatastoreID:  o.Datastore.Reference().Value,
+		},
+	}
+
+	o.logger.Log("msg", "Deploy Content Library item", "library", library.Name, "item", item.Name)
+	o.setMessage(fmt.Sprintf("Deploying '%s' from '%s' content library", item.Name, library.Name))
+
+	var res ovfDeployResult
+	query := url.Values{"~action": []string{"deploy"}}
+	if err := c.request(ctx, http.MethodPost, ovfDeployPath+"/id:"+item.ID, query, body, &res); err != nil {
+		return nil, err
+	}
+
+	if !res.Succeeded || res.ResourceID == nil {
+		return nil, res.err()
+	}
+
+	return &vmware_types.ManagedObjectReference{
+		Type:  res.ResourceID.Type,
+		Value: res.ResourceID.ID,
+	}, nil
+}
+
+// libraryTarget returns the chosen placement in the vSphere Automation API form
+func (o *Deployment) libraryTarget() ovfDeployTarget {
+	target := ovfDeployTarget{
+		ResourcePoolID: o.ResourcePool.Reference().Value,
+		FolderID:       o.Folder.Reference().Value,
+	}
+	if o.Host != nil {
+		target.HostID = o.Host.Reference().Value
+	}
+	return target
+}
+
+// resolveLibraryNetworks maps the networks the OVF template of the library item declares
+// with the same rules as the networks of an imported OVA
+func (o *Deployment) resolveLibraryNetworks(ctx context.Context, c *libraryClient, itemID string) ([]resolvedNetwork, error) {
+	summary, err := c.filter(ctx, itemID, o.libraryTarget())
+	if err != nil {
+		return nil, err
+	}
+
+	return o.resolveNetworks(ctx, summary.Networks)
+}
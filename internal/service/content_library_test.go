@@ -0,0 +1,269 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vapi/rest"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/soap"
+	vmware_types "github.com/vmware/govmomi/vim25/types"
+
+	"github.com/vterdunov/janna-api/internal/types"
+)
+
+var (
+	testLibraries = []libraryInfo{
+		{ID: "lib-1", Name: "Golden Images", Type: "LOCAL"},
+		{ID: "lib-2", Name: "Shared", Type: "SUBSCRIBED"},
+		{ID: "lib-3", Name: "Shared", Type: "LOCAL"},
+		// broken fails every request with an internal error
+		{ID: "broken", Name: "Broken"},
+	}
+	testLibraryItems = []libraryItemInfo{
+		{ID: "item-1", LibraryID: "lib-1", Name: "ubuntu", Type: "ovf", Size: 42},
+		{ID: "item-2", LibraryID: "lib-1", Name: "debian", Type: "ovf"},
+		{ID: "item-3", LibraryID: "lib-1", Name: "debian", Type: "ovf"},
+		{ID: "item-4", LibraryID: "lib-1", Name: "notes", Type: "file"},
+		{ID: "item-5", LibraryID: "lib-2", Name: "centos", Type: "ovf"},
+	}
+)
+
+// newLibraryServer serves the Content Library part of the vSphere Automation API.
+// Every OVF deploy fails with deployErr
+func newLibraryServer(t *testing.T, deployErr string) (*libraryClient, func()) {
+	t.Helper()
+
+	reply := func(w http.ResponseWriter, v interface{}) {
+		_ = json.NewEncoder(w).Encode(struct {
+			Value interface{} `json:"value"`
+		}{v})
+	}
+	find := func(r *http.Request) (name, libraryID string) {
+		var spec struct {
+			Spec struct {
+				Name      string `json:"name"`
+				LibraryID string `json:"library_id"`
+			} `json:"spec"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&spec)
+		return spec.Spec.Name, spec.Spec.LibraryID
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "id:broken") {
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+
+		action := r.URL.Query().Get("~action")
+		switch {
+		case r.URL.Path == libraryPath && action == "find":
+			name, _ := find(r)
+			ids := []string{}
+			for _, l := range testLibraries {
+				if l.Name == name {
+					ids = append(ids, l.ID)
+				}
+			}
+			reply(w, ids)
+		case r.URL.Path == libraryPath:
+			ids := []string{}
+			for _, l := range testLibraries[:3] {
+				ids = append(ids, l.ID)
+			}
+			reply(w, ids)
+		case strings.HasPrefix(r.URL.Path, libraryPath+"/id:"):
+			id := strings.TrimPrefix(r.URL.Path, libraryPath+"/id:")
+			for _, l := range testLibraries {
+				if l.ID == id {
+					reply(w, l)
+					return
+				}
+			}
+			http.NotFound(w, r)
+		case r.URL.Path == libraryItemPath && action == "find":
+			name, libraryID := find(r)
+			ids := []string{}
+			for _, i := range testLibraryItems {
+				if i.Name == name && i.LibraryID == libraryID {
+					ids = append(ids, i.ID)
+				}
+			}
+			reply(w, ids)
+		case r.URL.Path == libraryItemPath:
+			ids := []string{}
+			for _, i := range testLibraryItems {
+				if i.LibraryID == r.URL.Query().Get("library_id") {
+					ids = append(ids, i.ID)
+				}
+			}
+			reply(w, ids)
+		case strings.HasPrefix(r.URL.Path, libraryItemPath+"/id:"):
+			id := strings.TrimPrefix(r.URL.Path, libraryItemPath+"/id:")
+			for _, i := range testLibraryItems {
+				if i.ID == id {
+					reply(w, i)
+					return
+				}
+			}
+			http.NotFound(w, r)
+		case strings.HasPrefix(r.URL.Path, ovfDeployPath+"/id:") && action == "filter":
+			reply(w, ovfSummary{Name: "ubuntu", Networks: []string{"VM Network"}})
+		case strings.HasPrefix(r.URL.Path, ovfDeployPath+"/id:") && action == "deploy":
+			var res ovfDeployResult
+			_ = json.Unmarshal([]byte(`{"succeeded": false, "error": {"errors": [{"category": "SERVER"}, {"message": {"default_message": "`+deployErr+`"}}]}}`), &res)
+			reply(w, res)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &libraryClient{rest.NewClient(&vim25.Client{Client: soap.NewClient(u, true)})}
+
+	return c, srv.Close
+}
+
+func TestLibraryClient_Libraries(t *testing.T) {
+	c, cleanup := newLibraryServer(t, "")
+	defer cleanup()
+
+	ids, err := c.libraries(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"lib-1", "lib-2", "lib-3"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("libraryClient.libraries() = %v, want %v", ids, want)
+	}
+
+	ids, err = c.items(context.Background(), "lib-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"item-1", "item-2", "item-3", "item-4"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("libraryClient.items() = %v, want %v", ids, want)
+	}
+}
+
+func TestLibraryClient_FindLibrary(t *testing.T) {
+	tests := []struct {
+		name    string
+		library string
+		wantID  string
+		wantErr string
+	}{
+		{"name", "Golden Images", "lib-1", ""},
+		{"ID", "lib-2", "lib-2", ""},
+		{"not found", "Missing", "", "content library 'Missing' not found"},
+		{"ambiguous name", "Shared", "", "found 2 content libraries with name 'Shared'. Pass the library ID"},
+		{"lookup error", "broken", "", "500 Internal Server Error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, cleanup := newLibraryServer(t, "")
+			defer cleanup()
+
+			got, err := c.findLibrary(context.Background(), tt.library)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("libraryClient.findLibrary() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.ID != tt.wantID {
+				t.Errorf("libraryClient.findLibrary() = %v, want %v", got.ID, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestLibraryClient_FindItem(t *testing.T) {
+	tests := []struct {
+		name    string
+		item    string
+		wantID  string
+		wantErr string
+	}{
+		{"name", "ubuntu", "item-1", ""},
+		{"ID", "item-4", "item-4", ""},
+		{"not found", "missing", "", "content library item 'missing' not found"},
+		{"ID of another library", "item-5", "", "content library item 'item-5' not found"},
+		{"ambiguous name", "debian", "", "found 2 content library items with name 'debian'. Pass the item ID"},
+		{"lookup error", "broken", "", "500 Internal Server Error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, cleanup := newLibraryServer(t, "")
+			defer cleanup()
+
+			got, err := c.findItem(context.Background(), "lib-1", tt.item)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("libraryClient.findItem() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.ID != tt.wantID {
+				t.Errorf("libraryClient.findItem() = %v, want %v", got.ID, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestDeployment_DeployLibraryItem(t *testing.T) {
+	vc, cleanupVC := newTestVCenter(t)
+	defer cleanupVC()
+
+	// the simulated datastores are not accessible
+	for _, ds := range simulator.Map.All("Datastore") {
+		simulator.Map.Update(ds, []vmware_types.PropertyChange{{Name: "summary.accessible", Val: true}})
+	}
+
+	params := &types.VMDeployParams{Name: "vm", DiskProvisioning: "thick"}
+	params.ComputerResources.Type = "host"
+	params.ComputerResources.Path = "/DC0/host/DC0_H0/DC0_H0"
+	params.Datastores.Type = "datastore"
+
+	d, err := newDeployment(context.Background(), vc, params, 0, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, cleanup := newLibraryServer(t, "Datastore is full")
+	defer cleanup()
+
+	tests := []struct {
+		name    string
+		item    string
+		wantErr string
+	}{
+		{"deploy failed", "ubuntu", "SERVER; Datastore is full"},
+		{"not an OVF template", "notes", "content library item 'notes' is not an OVF template"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ref, err := d.DeployLibraryItem(context.Background(), c, "Golden Images", tt.item, "")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Deployment.DeployLibraryItem() = %v, %v, want error %q", ref, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/mo"
+	vmware_types "github.com/vmware/govmomi/vim25/types"
+
+	"github.com/vterdunov/janna-api/internal/domain"
+	"github.com/vterdunov/janna-api/internal/types"
+)
+
+// datastoreCandidate is a datastore that can take the Virtual Machine
+type datastoreCandidate struct {
+	ds        *object.Datastore
+	ref       vmware_types.ManagedObjectReference
+	name      string
+	freeSpace int64
+	vms       int
+}
+
+// chooseDatastoreWithDatastore picks one of the named datastores with the placement strategy.
+// Datastores that are inaccessible, in maintenance or do not have room for the disks are skipped.
+func (o *Deployment) chooseDatastoreWithDatastore(ctx context.Context, names []string) error {
+	var datastores []*object.Datastore
+	if len(names) == 0 {
+		ds, err := o.Finder.DefaultDatastore(ctx)
+		if err != nil {
+			return err
+		}
+		datastores = append(datastores, ds)
+	}
+
+	for _, name := range names {
+		list, err := o.Finder.DatastoreList(ctx, name)
+		if err != nil {
+			return err
+		}
+		datastores = append(datastores, list...)
+	}
+
+	candidates, skipped, err := datastoreCandidates(ctx, o.Client, datastores, o.RequiredSpace)
+	if err != nil {
+		return err
+	}
+
+	for _, reason := range skipped {
+		o.logger.Log("msg", "Skip datastore", "reason", reason)
+	}
+
+	if len(candidates) == 0 {
+		return fmt.Errorf("no suitable datastore, %s of free space is needed: %s", formatBytes(o.RequiredSpace), strings.Join(skipped, "; "))
+	}
+
+	c := pickDatastore(candidates, o.DatastoreStrategy)
+	o.logger.Log("msg", "Chose datastore", "datastore", c.name, "strategy", o.DatastoreStrategy, "free_space", formatBytes(c.freeSpace), "vms", c.vms)
+
+	o.Datastore = c.ds
+	return nil
+}
+
+// datastoreCandidates returns the datastores that can take requiredSpace bytes
+// and the reasons why the rest were skipped
+func datastoreCandidates(ctx context.Context, c *vim25.Client, datastores []*object.Datastore, requiredSpace int64) ([]datastoreCandidate, []string, error) {
+	refs := make([]vmware_types.ManagedObjectReference, 0, len(datastores))
+	byRef := make(map[vmware_types.ManagedObjectReference]*object.Datastore, len(datastores))
+	for _, ds := range datastores {
+		if _, ok := byRef[ds.Reference()]; ok {
+			continue
+		}
+		refs = append(refs, ds.Reference())
+		byRef[ds.Reference()] = ds
+	}
+
+	var props []mo.Datastore
+	if err := property.DefaultCollector(c).Retrieve(ctx, refs, []string{"summary", "vm"}, &props); err != nil {
+		return nil, nil, errors.Wrap(err, "could not get datastores properties")
+	}
+
+	candidates, skipped := filterDatastores(props, requiredSpace)
+	for i := range candidates {
+		candidates[i].ds = byRef[candidates[i].ref]
+	}
+
+	return candidates, skipped, nil
+}
+
+// filterDatastores skips inaccessible datastores, datastores in maintenance
+// and datastores that can not take requiredSpace bytes
+func filterDatastores(props []mo.Datastore, requiredSpace int64) ([]datastoreCandidate, []string) {
+	var candidates []datastoreCandidate
+	var skipped []string
+	for _, p := range props {
+		summary := p.Summary
+		switch {
+		case !summary.Accessible:
+			skipped = append(skipped, fmt.Sprintf("'%s' is not accessible", summary.Name))
+		case summary.MaintenanceMode != "" && summary.MaintenanceMode != string(vmware_types.DatastoreSummaryMaintenanceModeStateNormal):
+			skipped = append(skipped, fmt.Sprintf("'%s' is in maintenance", summary.Name))
+		case summary.FreeSpace <= requiredSpace:
+			skipped = append(skipped, fmt.Sprintf("'%s' has %s free", summary.Name, formatBytes(summary.FreeSpace)))
+		default:
+			candidates = append(candidates, datastoreCandidate{
+				ref:       p.Reference(),
+				name:      summary.Name,
+				freeSpace: summary.FreeSpace,
+				vms:       len(p.Vm),
+			})
+		}
+	}
+
+	return candidates, skipped
+}
+
+func pickDatastore(candidates []datastoreCandidate, strategy domain.DatastoreStrategy) datastoreCandidate {
+	best := candidates[0]
+
+	switch strategy {
+	case domain.DatastoreStrategyLeastVMs:
+		for _, c := range candidates[1:] {
+			if c.vms < best.vms || (c.vms == best.vms && c.freeSpace > best.freeSpace) {
+				best = c
+			}
+		}
+
+	case domain.DatastoreStrategyWeightedRandom:
+		// the chance to be chosen is proportional to the free space
+		var total int64
+		for _, c := range candidates {
+			total += c.freeSpace
+		}
+
+		r := rand.New(rand.NewSource(time.Now().UnixNano())).Int63n(total)
+		for _, c := range candidates {
+			if r < c.freeSpace {
+				return c
+			}
+			r -= c.freeSpace
+		}
+
+	default:
+		for _, c := range candidates[1:] {
+			if c.freeSpace > best.freeSpace {
+				best = c
+			}
+		}
+	}
+
+	return best
+}
+
+// requiredSpace estimates how much datastore space the disks of the OVA take.
+// Thin disks take their populated size if the OVF declares it. It returns zero without the OVA,
+// e.g. for clones and library items, and for datastore clusters, where Storage DRS places the disks.
+func requiredSpace(params *types.VMDeployParams, ova *ovaPackage) int64 {
+	if ova == nil || params.Datastores.Type == "cluster" {
+		return 0
+	}
+
+	var size int64
+	for _, d := range ovaDisks(ova.envelope) {
+		if params.DiskProvisioning == "thin" && d.PopulatedSizeBytes > 0 {
+			size += d.PopulatedSizeBytes
+			continue
+		}
+		size += d.CapacityBytes
+	}
+
+	return size
+}
+
+func formatBytes(b int64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/vmware/govmomi/ovf"
+	"github.com/vmware/govmomi/vim25/mo"
+	vmware_types "github.com/vmware/govmomi/vim25/types"
+
+	"github.com/vterdunov/janna-api/internal/domain"
+	"github.com/vterdunov/janna-api/internal/types"
+)
+
+func testDatastore(name string, free int64, vms int, accessible bool, maintenance string) mo.Datastore {
+	ds := mo.Datastore{
+		Summary: vmware_types.DatastoreSummary{
+			Name:            name,
+			FreeSpace:       free,
+			Accessible:      accessible,
+			MaintenanceMode: maintenance,
+		},
+		Vm: make([]vmware_types.ManagedObjectReference, vms),
+	}
+	ds.Self = vmware_types.ManagedObjectReference{Type: "Datastore", Value: name}
+	return ds
+}
+
+func candidateNames(candidates []datastoreCandidate) []string {
+	var names []string
+	for _, c := range candidates {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+func TestFilterDatastores(t *testing.T) {
+	props := []mo.Datastore{
+		testDatastore("ok", 100, 1, true, ""),
+		testDatastore("normal", 200, 2, true, "normal"),
+		testDatastore("offline", 300, 0, false, ""),
+		testDatastore("maintenance", 300, 0, true, "inMaintenance"),
+		testDatastore("entering", 300, 0, true, "enteringMaintenance"),
+		testDatastore("exact", 50, 0, true, ""),
+		testDatastore("small", 10, 0, true, ""),
+	}
+
+	tests := []struct {
+		name          string
+		requiredSpace int64
+		wantNames     []string
+		wantSkipped   []string
+	}{
+		{
+			name:          "no disks",
+			requiredSpace: 0,
+			wantNames:     []string{"ok", "normal", "exact", "small"},
+			wantSkipped:   []string{"'offline' is not accessible", "'maintenance' is in maintenance", "'entering' is in maintenance"},
+		},
+		{
+			name:          "free space",
+			requiredSpace: 50,
+			wantNames:     []string{"ok", "normal"},
+			wantSkipped: []string{
+				"'offline' is not accessible", "'maintenance' is in maintenance", "'entering' is in maintenance",
+				"'exact' has 50 B free", "'small' has 10 B free",
+			},
+		},
+		{
+			name:          "nothing fits",
+			requiredSpace: 1 << 30,
+			wantSkipped: []string{
+				"'ok' has 100 B free", "'normal' has 200 B free",
+				"'offline' is not accessible", "'maintenance' is in maintenance", "'entering' is in maintenance",
+				"'exact' has 50 B free", "'small' has 10 B free",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			candidates, skipped := filterDatastores(props, tt.requiredSpace)
+			if got := candidateNames(candidates); !reflect.DeepEqual(got, tt.wantNames) {
+				t.Errorf("filterDatastores() candidates = %v, want %v", got, tt.wantNames)
+			}
+			if !reflect.DeepEqual(skipped, tt.wantSkipped) {
+				t.Errorf("filterDatastores() skipped = %v, want %v", skipped, tt.wantSkipped)
+			}
+		})
+	}
+
+	candidates, _ := filterDatastores(props[:1], 0)
+	want := datastoreCandidate{ref: props[0].Self, name: "ok", freeSpace: 100, vms: 1}
+	if !reflect.DeepEqual(candidates[0], want) {
+		t.Errorf("filterDatastores() = %+v, want %+v", candidates[0], want)
+	}
+}
+
+func TestPickDatastore(t *testing.T) {
+	candidates := []datastoreCandidate{
+		{name: "a", freeSpace: 100, vms: 5},
+		{name: "b", freeSpace: 300, vms: 2},
+		{name: "c", freeSpace: 200, vms: 2},
+		{name: "d", freeSpace: 50, vms: 7},
+	}
+
+	tests := []struct {
+		name       string
+		candidates []datastoreCandidate
+		strategy   domain.DatastoreStrategy
+		want       string
+	}{
+		{"most free space", candidates, domain.DatastoreStrategyMostFreeSpace, "b"},
+		{"default strategy", candidates, "", "b"},
+		{"least vms, ties by free space", candidates, domain.DatastoreStrategyLeastVMs, "b"},
+		{"least vms", candidates[2:], domain.DatastoreStrategyLeastVMs, "c"},
+		{"single", candidates[3:], domain.DatastoreStrategyWeightedRandom, "d"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pickDatastore(tt.candidates, tt.strategy); got.name != tt.want {
+				t.Errorf("pickDatastore() = %v, want %v", got.name, tt.want)
+			}
+		})
+	}
+}
+
+func TestPickDatastore_WeightedRandom(t *testing.T) {
+	equal := []datastoreCandidate{{name: "a", freeSpace: 100}, {name: "b", freeSpace: 100}}
+	picked := map[string]int{}
+	for i := 0; i < 200; i++ {
+		picked[pickDatastore(equal, domain.DatastoreStrategyWeightedRandom).name]++
+	}
+	if picked["a"] == 0 || picked["b"] == 0 || picked["a"]+picked["b"] != 200 {
+		t.Errorf("pickDatastore() picked %v of datastores with the same free space", picked)
+	}
+
+	// 'small' has a chance of 1 to 2^40
+	skewed := []datastoreCandidate{{name: "small", freeSpace: 1}, {name: "big", freeSpace: 1 << 40}}
+	for i := 0; i < 100; i++ {
+		if got := pickDatastore(skewed, domain.DatastoreStrategyWeightedRandom); got.name != "big" {
+			t.Fatalf("pickDatastore() = %v, want big", got.name)
+		}
+	}
+}
+
+func TestRequiredSpace(t *testing.T) {
+	populated := 10
+	ova := &ovaPackage{envelope: &ovf.Envelope{
+		Disk: &ovf.DiskSection{Disks: []ovf.VirtualDiskDesc{
+			{DiskID: "thin", Capacity: "100", PopulatedSize: &populated},
+			{DiskID: "full", Capacity: "2", CapacityAllocationUnits: stringPtr("byte * 2^10")},
+		}},
+	}}
+
+	tests := []struct {
+		name          string
+		provisioning  string
+		datastoreType string
+		ova           *ovaPackage
+		want          int64
+	}{
+		{"thin", "thin", "datastore", ova, 10 + 2048},
+		{"thick", "thick", "datastore", ova, 100 + 2048},
+		{"datastore cluster", "thin", "cluster", ova, 0},
+		{"no ova", "thin", "datastore", nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := &types.VMDeployParams{DiskProvisioning: tt.provisioning}
+			params.Datastores.Type = tt.datastoreType
+
+			if got := requiredSpace(params, tt.ova); got != tt.want {
+				t.Errorf("requiredSpace() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
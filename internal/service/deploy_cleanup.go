@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
+	vmware_types "github.com/vmware/govmomi/vim25/types"
+
+	"github.com/vterdunov/janna-api/internal/domain"
+)
+
+// cleanupFailedDeploy applies the cleanup policy of the task to the Virtual Machine of a failed deploy.
+// If vm is nil, the Virtual Machine is looked up by the UUID remembered in the task,
+// because an import may leave it behind when the lease could not be aborted.
+func (s *service) cleanupFailedDeploy(t TaskStatuser, vm *object.VirtualMachine, l log.Logger) *domain.TaskCleanup {
+	task := t.Get()
+
+	cleanup := &domain.TaskCleanup{Policy: domain.CleanupPolicyKeep}
+	if task.Cleanup != nil {
+		cleanup.Policy = task.Cleanup.Policy
+	}
+
+	// the task context may be already cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	if vm == nil && task.Result.VMUUID != "" {
+		var err error
+		vm, err = s.findVMByUUID(ctx, task.Result.VMUUID)
+		if err != nil {
+			err = errors.Wrap(err, "Could not find Virtual Machine to clean up")
+			l.Log("err", err)
+			cleanup.Action = domain.CleanupActionFailed
+			cleanup.Error = err.Error()
+			return cleanup
+		}
+	}
+
+	if vm == nil {
+		cleanup.Action = domain.CleanupActionNone
+		return cleanup
+	}
+
+	var err error
+	switch cleanup.Policy {
+	case domain.CleanupPolicyDestroy:
+		l.Log("msg", "Destroying Virtual Machine of failed deploy")
+		if err = destroyVM(ctx, vm); err == nil {
+			cleanup.Action = domain.CleanupActionDestroyed
+		}
+		err = errors.Wrap(err, "Could not destroy Virtual Machine")
+
+	case domain.CleanupPolicyPowerOff:
+		l.Log("msg", "Powering off Virtual Machine of failed deploy")
+		if err = powerOffVM(ctx, vm); err == nil {
+			cleanup.Action = domain.CleanupActionPoweredOff
+		}
+		err = errors.Wrap(err, "Could not power off Virtual Machine")
+
+	default:
+		cleanup.Action = domain.CleanupActionKept
+	}
+
+	if err != nil {
+		l.Log("err", err)
+		cleanup.Action = domain.CleanupActionFailed
+		cleanup.Error = err.Error()
+	}
+
+	return cleanup
+}
+
+// powerOffVM powers off the Virtual Machine if it is not powered off yet
+func powerOffVM(ctx context.Context, vm *object.VirtualMachine) error {
+	state, err := vm.PowerState(ctx)
+	if err != nil {
+		return errors.Wrap(err, "could not get Virtual Machine power state")
+	}
+
+	if state == vmware_types.VirtualMachinePowerStatePoweredOff {
+		return nil
+	}
+
+	task, err := vm.PowerOff(ctx)
+	if err != nil {
+		return err
+	}
+
+	return task.Wait(ctx)
+}
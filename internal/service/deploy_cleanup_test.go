@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25"
+	vmware_types "github.com/vmware/govmomi/vim25/types"
+
+	"github.com/vterdunov/janna-api/internal/domain"
+)
+
+// testVM returns a powered on Virtual Machine of the simulated vCenter
+func testVM(t *testing.T, c *vim25.Client) *object.VirtualMachine {
+	t.Helper()
+
+	vm, err := find.NewFinder(c, false).VirtualMachine(context.Background(), "/DC0/vm/DC0_H0_VM0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return vm
+}
+
+func TestService_CleanupFailedDeploy(t *testing.T) {
+	missingVM := vmware_types.ManagedObjectReference{Type: "VirtualMachine", Value: "vm-missing"}
+
+	tests := []struct {
+		name   string
+		policy domain.CleanupPolicy
+		// passVM passes the Virtual Machine, otherwise it is found by UUID
+		passVM bool
+		// uuid is remembered in the task when the Virtual Machine is not passed
+		uuid bool
+		// broken passes a Virtual Machine that does not exist
+		broken     bool
+		wantAction domain.CleanupAction
+		wantError  string
+		// wantState is the power state of the Virtual Machine after the cleanup. Empty if it is destroyed
+		wantState vmware_types.VirtualMachinePowerState
+	}{
+		{
+			name:       "keep",
+			policy:     domain.CleanupPolicyKeep,
+			passVM:     true,
+			wantAction: domain.CleanupActionKept,
+			wantState:  vmware_types.VirtualMachinePowerStatePoweredOn,
+		},
+		{
+			name:       "no policy",
+			passVM:     true,
+			wantAction: domain.CleanupActionKept,
+			wantState:  vmware_types.VirtualMachinePowerStatePoweredOn,
+		},
+		{
+			name:       "destroy",
+			policy:     domain.CleanupPolicyDestroy,
+			passVM:     true,
+			wantAction: domain.CleanupActionDestroyed,
+		},
+		{
+			name:       "power off",
+			policy:     domain.CleanupPolicyPowerOff,
+			passVM:     true,
+			wantAction: domain.CleanupActionPoweredOff,
+			wantState:  vmware_types.VirtualMachinePowerStatePoweredOff,
+		},
+		{
+			name:       "destroy found by uuid",
+			policy:     domain.CleanupPolicyDestroy,
+			uuid:       true,
+			wantAction: domain.CleanupActionDestroyed,
+		},
+		{
+			name:       "power off found by uuid",
+			policy:     domain.CleanupPolicyPowerOff,
+			uuid:       true,
+			wantAction: domain.CleanupActionPoweredOff,
+			wantState:  vmware_types.VirtualMachinePowerStatePoweredOff,
+		},
+		{
+			name:       "no virtual machine",
+			policy:     domain.CleanupPolicyDestroy,
+			wantAction: domain.CleanupActionNone,
+			wantState:  vmware_types.VirtualMachinePowerStatePoweredOn,
+		},
+		{
+			name:       "destroy error",
+			policy:     domain.CleanupPolicyDestroy,
+			broken:     true,
+			wantAction: domain.CleanupActionFailed,
+			wantError:  "Could not destroy Virtual Machine",
+			wantState:  vmware_types.VirtualMachinePowerStatePoweredOn,
+		},
+		{
+			name:       "power off error",
+			policy:     domain.CleanupPolicyPowerOff,
+			broken:     true,
+			wantAction: domain.CleanupActionFailed,
+			wantError:  "Could not power off Virtual Machine",
+			wantState:  vmware_types.VirtualMachinePowerStatePoweredOn,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, cleanup := newTestVCenter(t)
+			defer cleanup()
+
+			ctx := context.Background()
+			s := &service{Client: c, logger: log.NewNopLogger()}
+			vm := testVM(t, c)
+			uuid := vm.UUID(ctx)
+
+			task := newTestTask("task")
+			task.Update(func(task *domain.Task) {
+				if tt.policy != "" {
+					task.Cleanup = &domain.TaskCleanup{Policy: tt.policy}
+				}
+				if tt.uuid {
+					task.Result.VMUUID = uuid
+				}
+			})
+
+			var arg *object.VirtualMachine
+			switch {
+			case tt.passVM:
+				arg = vm
+			case tt.broken:
+				arg = object.NewVirtualMachine(c, missingVM)
+			}
+
+			got := s.cleanupFailedDeploy(task, arg, log.NewNopLogger())
+
+			wantPolicy := tt.policy
+			if wantPolicy == "" {
+				wantPolicy = domain.CleanupPolicyKeep
+			}
+			if got.Policy != wantPolicy || got.Action != tt.wantAction {
+				t.Errorf("service.cleanupFailedDeploy() = %+v, want policy %v, action %v", got, wantPolicy, tt.wantAction)
+			}
+			if !strings.HasPrefix(got.Error, tt.wantError) || (tt.wantError == "") != (got.Error == "") {
+				t.Errorf("service.cleanupFailedDeploy() error = %q, want %q", got.Error, tt.wantError)
+			}
+
+			found, err := s.findVMByUUID(ctx, uuid)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantState == "" {
+				if found != nil {
+					t.Error("service.cleanupFailedDeploy() did not destroy the Virtual Machine")
+				}
+				return
+			}
+			if found == nil {
+				t.Fatal("service.cleanupFailedDeploy() destroyed the Virtual Machine")
+			}
+			if state, err := found.PowerState(ctx); err != nil || state != tt.wantState {
+				t.Errorf("Virtual Machine power state = %v, %v, want %v", state, err, tt.wantState)
+			}
+		})
+	}
+}
+
+func TestService_CleanupFailedDeployRemovedVM(t *testing.T) {
+	c, cleanup := newTestVCenter(t)
+	defer cleanup()
+
+	s := &service{Client: c, logger: log.NewNopLogger()}
+	task := newTestTask("task")
+	task.Update(func(task *domain.Task) {
+		task.Cleanup = &domain.TaskCleanup{Policy: domain.CleanupPolicyDestroy}
+		task.Result.VMUUID = "00000000-0000-0000-0000-000000000000"
+	})
+
+	got := s.cleanupFailedDeploy(task, nil, log.NewNopLogger())
+	if got.Action != domain.CleanupActionNone || got.Error != "" {
+		t.Errorf("service.cleanupFailedDeploy() = %+v, want action %v", got, domain.CleanupActionNone)
+	}
+}
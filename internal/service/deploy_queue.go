@@ -0,0 +1,237 @@
+package service
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/vterdunov/janna-api/internal/domain"
+)
+
+var (
+	errDeployQueueFull   = errors.New("deploy queue is full. Try again later")
+	errDeployQueueClosed = errors.New("Janna is shutting down. Try again later") //nolint: stylecheck,golint
+)
+
+// deployJob is a deploy waiting for a free worker
+type deployJob struct {
+	id         string
+	datacenter string
+	task       TaskStatuser
+	// position is the last queue position reported to the task status
+	position int
+	// reportedSeq is the sequence number of the last position written to the task status.
+	// It is guarded by deployQueue.reportMu
+	reportedSeq uint64
+
+	// run does the deploy. It is called in a separate goroutine
+	run func()
+	// drop finishes the job that was removed from the queue before it has started
+	drop func()
+}
+
+// deployQueue runs deploys with limited concurrency.
+// Jobs start in FIFO order, but a job whose datacenter has reached its limit
+// does not block the jobs of other datacenters.
+type deployQueue struct {
+	mu sync.Mutex
+
+	workers   int
+	queueSize int
+	dcLimits  map[string]int
+
+	running     int
+	runningByDC map[string]int
+	queue       []*deployJob
+	closed      bool
+	// reportSeq numbers the queue positions, so a stale position is not written over a newer one
+	reportSeq uint64
+
+	// reportMu serializes writes of queue positions to the tasks statuses.
+	// Positions are written without mu, status updates may be slow
+	reportMu sync.Mutex
+}
+
+// positionReport is a queue position that must be written to the task status
+type positionReport struct {
+	job      *deployJob
+	position int
+	seq      uint64
+}
+
+func newDeployQueue(workers, queueSize int, dcLimits map[string]int) *deployQueue {
+	if workers < 1 {
+		workers = 1
+	}
+
+	return &deployQueue{
+		workers:     workers,
+		queueSize:   queueSize,
+		dcLimits:    dcLimits,
+		runningByDC: make(map[string]int),
+	}
+}
+
+// push adds the job to the end of the queue and starts it if there is a free worker
+func (q *deployQueue) push(j *deployJob) error {
+	q.mu.Lock()
+
+	if q.closed {
+		q.mu.Unlock()
+		return errDeployQueueClosed
+	}
+
+	if q.queueSize > 0 && len(q.queue) >= q.queueSize {
+		q.mu.Unlock()
+		return errDeployQueueFull
+	}
+
+	q.queue = append(q.queue, j)
+	reports := q.dispatch()
+	q.mu.Unlock()
+
+	q.report(reports)
+
+	return nil
+}
+
+// remove drops the job if it is still waiting in the queue.
+// It reports whether the job was found.
+func (q *deployQueue) remove(id string) bool {
+	q.mu.Lock()
+
+	var removed *deployJob
+	for i, j := range q.queue {
+		if j.id == id {
+			removed = j
+			q.queue = append(q.queue[:i], q.queue[i+1:]...)
+			break
+		}
+	}
+
+	var reports []positionReport
+	if removed != nil {
+		reports = q.reportPositions()
+	}
+	q.mu.Unlock()
+
+	if removed == nil {
+		return false
+	}
+
+	q.report(reports)
+	removed.drop()
+	return true
+}
+
+// close stops accepting and starting jobs. It returns the jobs that have not been started
+func (q *deployQueue) close() []*deployJob {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.closed = true
+	jobs := q.queue
+	q.queue = nil
+
+	return jobs
+}
+
+func (q *deployQueue) isClosed() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.closed
+}
+
+// dispatch starts queued jobs while there are free workers. It returns the changed queue positions.
+// Must be called with the lock held.
+func (q *deployQueue) dispatch() []positionReport {
+	if q.closed {
+		return nil
+	}
+
+	waiting := q.queue[:0]
+	for _, j := range q.queue {
+		if q.running < q.workers && q.datacenterIsFree(j.datacenter) {
+			q.running++
+			q.runningByDC[j.datacenter]++
+			go q.run(j)
+			continue
+		}
+		waiting = append(waiting, j)
+	}
+
+	for i := len(waiting); i < len(q.queue); i++ {
+		q.queue[i] = nil
+	}
+	q.queue = waiting
+
+	return q.reportPositions()
+}
+
+func (q *deployQueue) datacenterIsFree(dc string) bool {
+	limit, ok := q.dcLimits[dc]
+	return !ok || q.runningByDC[dc] < limit
+}
+
+func (q *deployQueue) run(j *deployJob) {
+	defer func() {
+		q.mu.Lock()
+		q.running--
+		q.runningByDC[j.datacenter]--
+		if q.runningByDC[j.datacenter] == 0 {
+			delete(q.runningByDC, j.datacenter)
+		}
+		reports := q.dispatch()
+		q.mu.Unlock()
+
+		q.report(reports)
+	}()
+
+	j.run()
+}
+
+// reportPositions returns the changed queue positions. They are written by report after the lock is released.
+// Must be called with the lock held.
+func (q *deployQueue) reportPositions() []positionReport {
+	var reports []positionReport
+	for i, j := range q.queue {
+		position := i + 1
+		if j.position == position {
+			continue
+		}
+
+		if reports == nil {
+			q.reportSeq++
+		}
+		j.position = position
+		reports = append(reports, positionReport{job: j, position: position, seq: q.reportSeq})
+	}
+
+	return reports
+}
+
+// report writes the queue positions to the tasks statuses. Positions older than
+// the already written ones are skipped, and so are tasks that have left the queue.
+func (q *deployQueue) report(reports []positionReport) {
+	if len(reports) == 0 {
+		return
+	}
+
+	q.reportMu.Lock()
+	defer q.reportMu.Unlock()
+
+	for _, r := range reports {
+		if r.seq <= r.job.reportedSeq {
+			continue
+		}
+		r.job.reportedSeq = r.seq
+
+		position := r.position
+		r.job.task.Update(func(t *domain.Task) {
+			if t.Stage != domain.TaskStageQueued {
+				return
+			}
+			t.QueuePosition = position
+		})
+	}
+}
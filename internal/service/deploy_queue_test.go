@@ -0,0 +1,343 @@
+package service
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/vterdunov/janna-api/internal/domain"
+)
+
+// blockingJob is a deploy job that runs until it is released
+type blockingJob struct {
+	*deployJob
+	started chan struct{}
+	release chan struct{}
+	dropped chan struct{}
+}
+
+func newBlockingJob(id, dc string) *blockingJob {
+	j := &blockingJob{
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+		dropped: make(chan struct{}),
+	}
+	task := newTestTask(id)
+	task.Update(func(t *domain.Task) {
+		t.SetStage(domain.TaskStageQueued)
+	})
+	j.deployJob = &deployJob{
+		id:         id,
+		datacenter: dc,
+		task:       task,
+		run: func() {
+			close(j.started)
+			<-j.release
+		},
+		drop: func() {
+			close(j.dropped)
+		},
+	}
+	return j
+}
+
+func closesSoon(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	case <-time.After(100 * time.Millisecond):
+		return false
+	}
+}
+
+func waitClosed(t *testing.T, ch chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s did not happen", what)
+	}
+}
+
+func TestDeployQueue_FIFO(t *testing.T) {
+	q := newDeployQueue(1, 0, nil)
+
+	var mu sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+
+	first := newBlockingJob("0", "dc1")
+	if err := q.push(first.deployJob); err != nil {
+		t.Fatal(err)
+	}
+	waitClosed(t, first.started, "start of the first job")
+
+	var want []string
+	for i := 1; i <= 5; i++ {
+		id := fmt.Sprint(i)
+		want = append(want, id)
+		wg.Add(1)
+		err := q.push(&deployJob{
+			id:         id,
+			datacenter: "dc1",
+			task:       newTestTask(id),
+			run: func() {
+				defer wg.Done()
+				mu.Lock()
+				order = append(order, id)
+				mu.Unlock()
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	close(first.release)
+	wg.Wait()
+
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("deployQueue started jobs in order %v, want %v", order, want)
+	}
+}
+
+func TestDeployQueue_DatacenterLimit(t *testing.T) {
+	q := newDeployQueue(2, 0, map[string]int{"dc1": 1})
+
+	a := newBlockingJob("a", "dc1")
+	b := newBlockingJob("b", "dc1")
+	c := newBlockingJob("c", "dc2")
+	for _, j := range []*blockingJob{a, b, c} {
+		if err := q.push(j.deployJob); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	waitClosed(t, a.started, "start of job 'a'")
+	waitClosed(t, c.started, "start of job 'c' in another datacenter")
+	if closesSoon(b.started) {
+		t.Fatal("job 'b' started over the datacenter limit")
+	}
+	if got := b.task.Get().QueuePosition; got != 1 {
+		t.Errorf("job 'b' queue position = %d, want 1", got)
+	}
+
+	close(a.release)
+	waitClosed(t, b.started, "start of job 'b' after the datacenter is free")
+	close(b.release)
+	close(c.release)
+}
+
+func TestDeployQueue_Full(t *testing.T) {
+	q := newDeployQueue(1, 1, nil)
+
+	running := newBlockingJob("running", "dc1")
+	defer close(running.release)
+	if err := q.push(running.deployJob); err != nil {
+		t.Fatal(err)
+	}
+	waitClosed(t, running.started, "start of the running job")
+
+	if err := q.push(newBlockingJob("queued", "dc1").deployJob); err != nil {
+		t.Fatalf("deployQueue.push() error = %v", err)
+	}
+
+	if err := q.push(newBlockingJob("rejected", "dc1").deployJob); err != errDeployQueueFull {
+		t.Errorf("deployQueue.push() error = %v, want %v", err, errDeployQueueFull)
+	}
+}
+
+func TestDeployQueue_Closed(t *testing.T) {
+	q := newDeployQueue(1, 0, nil)
+
+	running := newBlockingJob("running", "dc1")
+	defer close(running.release)
+	q.push(running.deployJob) //nolint: errcheck
+	waitClosed(t, running.started, "start of the running job")
+
+	queued := newBlockingJob("queued", "dc1")
+	q.push(queued.deployJob) //nolint: errcheck
+
+	jobs := q.close()
+	if len(jobs) != 1 || jobs[0].id != "queued" {
+		t.Errorf("deployQueue.close() returned %d jobs, want the queued one", len(jobs))
+	}
+
+	if err := q.push(newBlockingJob("late", "dc1").deployJob); err != errDeployQueueClosed {
+		t.Errorf("deployQueue.push() error = %v, want %v", err, errDeployQueueClosed)
+	}
+}
+
+func TestDeployQueue_ReportPositions(t *testing.T) {
+	q := newDeployQueue(1, 0, nil)
+
+	running := newBlockingJob("running", "dc1")
+	q.push(running.deployJob) //nolint: errcheck
+	waitClosed(t, running.started, "start of the running job")
+
+	jobs := map[string]*blockingJob{}
+	for _, id := range []string{"b", "c", "d"} {
+		jobs[id] = newBlockingJob(id, "dc1")
+		q.push(jobs[id].deployJob) //nolint: errcheck
+	}
+
+	positions := func() map[string]int {
+		res := map[string]int{}
+		for id, j := range jobs {
+			res[id] = j.task.Get().QueuePosition
+		}
+		return res
+	}
+
+	if got, want := positions(), map[string]int{"b": 1, "c": 2, "d": 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("queue positions = %v, want %v", got, want)
+	}
+
+	if !q.remove("c") {
+		t.Fatal("deployQueue.remove() did not find the queued job")
+	}
+	waitClosed(t, jobs["c"].dropped, "drop of the removed job")
+	delete(jobs, "c")
+
+	if got, want := positions(), map[string]int{"b": 1, "d": 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("queue positions after remove = %v, want %v", got, want)
+	}
+
+	close(running.release)
+	waitClosed(t, jobs["b"].started, "start of job 'b'")
+
+	if got := jobs["d"].task.Get().QueuePosition; got != 1 {
+		t.Errorf("job 'd' queue position after dispatch = %d, want 1", got)
+	}
+
+	close(jobs["b"].release)
+	waitClosed(t, jobs["d"].started, "start of job 'd'")
+	close(jobs["d"].release)
+}
+
+// TestDeployQueue_RemoveRace removes jobs while the queue dispatches them. Run it with -race.
+// Every job must be either run or dropped exactly once.
+func TestDeployQueue_RemoveRace(t *testing.T) {
+	const jobs = 200
+	q := newDeployQueue(4, 0, map[string]int{"dc1": 2})
+
+	var runs, drops [jobs]int32
+	var wg sync.WaitGroup
+	wg.Add(jobs)
+
+	for i := 0; i < jobs; i++ {
+		i := i
+		dc := "dc1"
+		if i%2 == 0 {
+			dc = "dc2"
+		}
+		err := q.push(&deployJob{
+			id:         fmt.Sprint(i),
+			datacenter: dc,
+			task:       newTestTask(fmt.Sprint(i)),
+			run: func() {
+				atomic.AddInt32(&runs[i], 1)
+				wg.Done()
+			},
+			drop: func() {
+				atomic.AddInt32(&drops[i], 1)
+				wg.Done()
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if i%3 == 0 {
+			go q.remove(fmt.Sprint(i))
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	waitClosed(t, done, "finish of all jobs")
+
+	for i := 0; i < jobs; i++ {
+		if total := runs[i] + drops[i]; total != 1 {
+			t.Errorf("job %d was run %d and dropped %d times", i, runs[i], drops[i])
+		}
+	}
+}
+
+// slowTask is a task whose status updates block until they are released
+type slowTask struct {
+	*testTask
+	updating chan struct{}
+	release  chan struct{}
+}
+
+func (t *slowTask) Update(fn func(t *domain.Task)) TaskStatuser {
+	t.updating <- struct{}{}
+	<-t.release
+	return t.testTask.Update(fn)
+}
+
+func TestDeployQueue_ReportWithoutLock(t *testing.T) {
+	q := newDeployQueue(1, 0, nil)
+
+	running := newBlockingJob("running", "dc1")
+	defer close(running.release)
+	q.push(running.deployJob) //nolint: errcheck
+	waitClosed(t, running.started, "start of the running job")
+
+	slow := newBlockingJob("slow", "dc1")
+	task := &slowTask{testTask: slow.task.(*testTask), updating: make(chan struct{}), release: make(chan struct{})}
+	slow.task = task
+	go q.push(slow.deployJob) //nolint: errcheck
+
+	select {
+	case <-task.updating:
+	case <-time.After(5 * time.Second):
+		t.Fatal("queue position of the slow job was not reported")
+	}
+
+	// the queue is not locked while the position is written
+	checked := make(chan struct{})
+	go func() {
+		q.isClosed()
+		close(checked)
+	}()
+	waitClosed(t, checked, "queue access while a queue position is written")
+
+	close(task.release)
+	waitFor := time.After(5 * time.Second)
+	for task.Get().QueuePosition != 1 {
+		select {
+		case <-waitFor:
+			t.Fatalf("slow job queue position = %d, want 1", task.Get().QueuePosition)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestDeployQueue_ReportStartedJob(t *testing.T) {
+	q := newDeployQueue(1, 0, nil)
+	j := newBlockingJob("started", "dc1")
+	j.task.Update(func(t *domain.Task) {
+		t.SetStage(domain.TaskStageStart)
+	})
+
+	// a position computed before the job has started must not be written after it
+	q.report([]positionReport{{job: j.deployJob, position: 3, seq: 1}})
+	if got := j.task.Get().QueuePosition; got != 0 {
+		t.Errorf("started job queue position = %d, want 0", got)
+	}
+
+	queued := newBlockingJob("queued", "dc1")
+	q.report([]positionReport{{job: queued.deployJob, position: 1, seq: 3}})
+	q.report([]positionReport{{job: queued.deployJob, position: 2, seq: 2}})
+	if got := queued.task.Get().QueuePosition; got != 1 {
+		t.Errorf("queued job position = %d after a stale report, want 1", got)
+	}
+}
@@ -75,6 +75,54 @@ func (mw instrumentingMiddleware) VMDeploy(ctx context.Context, params *types.VM
 	return mw.Service.VMDeploy(ctx, params)
 }
 
+func (mw instrumentingMiddleware) VMDeployPlan(ctx context.Context, params *types.VMDeployParams) (_ *domain.DeployPlan, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "VMDeployPlan", "success", fmt.Sprint(err == nil)}
+		mw.duration.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	return mw.Service.VMDeployPlan(ctx, params)
+}
+
+func (mw instrumentingMiddleware) OVAInspect(ctx context.Context, params *types.OVAInspectParams) (_ *domain.OVAInfo, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "OVAInspect", "success", fmt.Sprint(err == nil)}
+		mw.duration.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	return mw.Service.OVAInspect(ctx, params)
+}
+
+func (mw instrumentingMiddleware) OVAUpload(ctx context.Context, params *types.OVAUploadParams) (_ *domain.OVAUpload, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "OVAUpload", "success", fmt.Sprint(err == nil)}
+		mw.duration.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	return mw.Service.OVAUpload(ctx, params)
+}
+
+func (mw instrumentingMiddleware) OVAUploadDelete(ctx context.Context, params *types.OVAUploadDeleteParams) (err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "OVAUploadDelete", "success", fmt.Sprint(err == nil)}
+		mw.duration.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	return mw.Service.OVAUploadDelete(ctx, params)
+}
+
+func (mw instrumentingMiddleware) LibrariesList(ctx context.Context) (_ []domain.Library, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "LibrariesList", "success", fmt.Sprint(err == nil)}
+		mw.duration.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	return mw.Service.LibrariesList(ctx)
+}
+
+func (mw instrumentingMiddleware) LibraryItemsList(ctx context.Context, params *types.LibraryItemsListParams) (_ []domain.LibraryItem, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "LibraryItemsList", "success", fmt.Sprint(err == nil)}
+		mw.duration.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	return mw.Service.LibraryItemsList(ctx, params)
+}
+
 func (mw instrumentingMiddleware) VMSnapshotsList(ctx context.Context, params *types.VMSnapshotsListParams) (_ []domain.Snapshot, err error) {
 	defer func(begin time.Time) {
 		lvs := []string{"method", "VMSnapshotsList", "success", fmt.Sprint(err == nil)}
@@ -139,7 +187,15 @@ func (mw instrumentingMiddleware) RoleList(ctx context.Context) (_ []domain.Role
 	return mw.Service.RoleList(ctx)
 }
 
-func (mw instrumentingMiddleware) TaskInfo(ctx context.Context, taskID string) (_ map[string]interface{}, err error) {
+func (mw instrumentingMiddleware) TasksList(ctx context.Context, params *types.TasksListParams) (_ []domain.Task, _ int, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "TasksList", "success", fmt.Sprint(err == nil)}
+		mw.duration.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	return mw.Service.TasksList(ctx, params)
+}
+
+func (mw instrumentingMiddleware) TaskInfo(ctx context.Context, taskID string) (_ *domain.Task, err error) {
 	defer func(begin time.Time) {
 		lvs := []string{"method", "TaskInfo", "success", fmt.Sprint(err == nil)}
 		mw.duration.With(lvs...).Observe(time.Since(begin).Seconds())
@@ -147,6 +203,38 @@ func (mw instrumentingMiddleware) TaskInfo(ctx context.Context, taskID string) (
 	return mw.Service.TaskInfo(ctx, taskID)
 }
 
+func (mw instrumentingMiddleware) TaskCancel(ctx context.Context, params *types.TaskCancelParams) (err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "TaskCancel", "success", fmt.Sprint(err == nil)}
+		mw.duration.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	return mw.Service.TaskCancel(ctx, params)
+}
+
+func (mw instrumentingMiddleware) TaskEvents(ctx context.Context, taskID string) (_ <-chan domain.Task, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "TaskEvents", "success", fmt.Sprint(err == nil)}
+		mw.duration.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	return mw.Service.TaskEvents(ctx, taskID)
+}
+
+func (mw instrumentingMiddleware) ResumeTasks(ctx context.Context) (err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "ResumeTasks", "success", fmt.Sprint(err == nil)}
+		mw.duration.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	return mw.Service.ResumeTasks(ctx)
+}
+
+func (mw instrumentingMiddleware) Shutdown(ctx context.Context) (err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "Shutdown", "success", fmt.Sprint(err == nil)}
+		mw.duration.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	return mw.Service.Shutdown(ctx)
+}
+
 func (mw instrumentingMiddleware) OpenAPI(ctx context.Context) (_ []byte, err error) {
 	defer func(begin time.Time) {
 		lvs := []string{"method", "OpenAPI", "success", fmt.Sprint(err == nil)}
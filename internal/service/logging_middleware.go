@@ -115,7 +115,7 @@ func (s *loggingMiddleware) VMDeploy(ctx context.Context, params *types.VMDeploy
 		s.logger.Log(
 			"method", "VMDeploy",
 			"request_id", reqID,
-			"params", fmt.Sprintf("%+v", params),
+			"params", fmt.Sprintf("%+v", maskDeployParams(params)),
 			"err", err,
 		)
 	}()
@@ -123,6 +123,128 @@ func (s *loggingMiddleware) VMDeploy(ctx context.Context, params *types.VMDeploy
 	return s.Service.VMDeploy(ctx, params)
 }
 
+// maskDeployParams hides the callback secret and OVF properties values, they often keep passwords
+func maskDeployParams(params *types.VMDeployParams) *types.VMDeployParams {
+	p := *params
+	if p.Callback.Secret != "" {
+		p.Callback.Secret = "***"
+	}
+	if len(p.Properties) != 0 {
+		p.Properties = make(map[string]string, len(params.Properties))
+		for k := range params.Properties {
+			p.Properties[k] = "***"
+		}
+	}
+	return &p
+}
+
+func (s *loggingMiddleware) VMDeployPlan(ctx context.Context, params *types.VMDeployParams) (_ *domain.DeployPlan, err error) {
+	reqID, ok := ctx.Value(ContextKeyRequestXRequestID).(string)
+	if !ok {
+		reqID = ""
+	}
+
+	defer func() {
+		s.logger.Log(
+			"method", "VMDeployPlan",
+			"request_id", reqID,
+			"params", fmt.Sprintf("%+v", maskDeployParams(params)),
+			"err", err,
+		)
+	}()
+
+	return s.Service.VMDeployPlan(ctx, params)
+}
+
+func (s *loggingMiddleware) OVAInspect(ctx context.Context, params *types.OVAInspectParams) (_ *domain.OVAInfo, err error) {
+	reqID, ok := ctx.Value(ContextKeyRequestXRequestID).(string)
+	if !ok {
+		reqID = ""
+	}
+
+	defer func() {
+		s.logger.Log(
+			"method", "OVAInspect",
+			"request_id", reqID,
+			"params", fmt.Sprintf("%+v", params),
+			"err", err,
+		)
+	}()
+
+	return s.Service.OVAInspect(ctx, params)
+}
+
+func (s *loggingMiddleware) OVAUpload(ctx context.Context, params *types.OVAUploadParams) (_ *domain.OVAUpload, err error) {
+	reqID, ok := ctx.Value(ContextKeyRequestXRequestID).(string)
+	if !ok {
+		reqID = ""
+	}
+
+	defer func() {
+		s.logger.Log(
+			"method", "OVAUpload",
+			"request_id", reqID,
+			"name", params.Name,
+			"err", err,
+		)
+	}()
+
+	return s.Service.OVAUpload(ctx, params)
+}
+
+func (s *loggingMiddleware) OVAUploadDelete(ctx context.Context, params *types.OVAUploadDeleteParams) (err error) {
+	reqID, ok := ctx.Value(ContextKeyRequestXRequestID).(string)
+	if !ok {
+		reqID = ""
+	}
+
+	defer func() {
+		s.logger.Log(
+			"method", "OVAUploadDelete",
+			"request_id", reqID,
+			"params", fmt.Sprintf("%+v", params),
+			"err", err,
+		)
+	}()
+
+	return s.Service.OVAUploadDelete(ctx, params)
+}
+
+func (s *loggingMiddleware) LibrariesList(ctx context.Context) (_ []domain.Library, err error) {
+	reqID, ok := ctx.Value(ContextKeyRequestXRequestID).(string)
+	if !ok {
+		reqID = ""
+	}
+
+	defer func() {
+		s.logger.Log(
+			"method", "LibrariesList",
+			"request_id", reqID,
+			"err", err,
+		)
+	}()
+
+	return s.Service.LibrariesList(ctx)
+}
+
+func (s *loggingMiddleware) LibraryItemsList(ctx context.Context, params *types.LibraryItemsListParams) (_ []domain.LibraryItem, err error) {
+	reqID, ok := ctx.Value(ContextKeyRequestXRequestID).(string)
+	if !ok {
+		reqID = ""
+	}
+
+	defer func() {
+		s.logger.Log(
+			"method", "LibraryItemsList",
+			"request_id", reqID,
+			"params", fmt.Sprintf("%+v", params),
+			"err", err,
+		)
+	}()
+
+	return s.Service.LibraryItemsList(ctx, params)
+}
+
 func (s *loggingMiddleware) VMSnapshotsList(ctx context.Context, params *types.VMSnapshotsListParams) (_ []domain.Snapshot, err error) {
 	reqID, ok := ctx.Value(ContextKeyRequestXRequestID).(string)
 	if !ok {
@@ -284,7 +406,25 @@ func (s *loggingMiddleware) RoleList(ctx context.Context) (_ []domain.Role, err
 	return s.Service.RoleList(ctx)
 }
 
-func (s *loggingMiddleware) TaskInfo(ctx context.Context, taskID string) (_ map[string]interface{}, err error) {
+func (s *loggingMiddleware) TasksList(ctx context.Context, params *types.TasksListParams) (_ []domain.Task, _ int, err error) {
+	reqID, ok := ctx.Value(ContextKeyRequestXRequestID).(string)
+	if !ok {
+		reqID = ""
+	}
+
+	defer func() {
+		s.logger.Log(
+			"request_id", reqID,
+			"method", "TasksList",
+			"params", fmt.Sprintf("%+v", params),
+			"err", err,
+		)
+	}()
+
+	return s.Service.TasksList(ctx, params)
+}
+
+func (s *loggingMiddleware) TaskInfo(ctx context.Context, taskID string) (_ *domain.Task, err error) {
 	reqID, ok := ctx.Value(ContextKeyRequestXRequestID).(string)
 	if !ok {
 		reqID = ""
@@ -301,6 +441,64 @@ func (s *loggingMiddleware) TaskInfo(ctx context.Context, taskID string) (_ map[
 	return s.Service.TaskInfo(ctx, taskID)
 }
 
+func (s *loggingMiddleware) TaskCancel(ctx context.Context, params *types.TaskCancelParams) (err error) {
+	reqID, ok := ctx.Value(ContextKeyRequestXRequestID).(string)
+	if !ok {
+		reqID = ""
+	}
+
+	defer func() {
+		s.logger.Log(
+			"request_id", reqID,
+			"method", "TaskCancel",
+			"params", fmt.Sprintf("%+v", params),
+			"err", err,
+		)
+	}()
+
+	return s.Service.TaskCancel(ctx, params)
+}
+
+func (s *loggingMiddleware) TaskEvents(ctx context.Context, taskID string) (_ <-chan domain.Task, err error) {
+	reqID, ok := ctx.Value(ContextKeyRequestXRequestID).(string)
+	if !ok {
+		reqID = ""
+	}
+
+	defer func() {
+		s.logger.Log(
+			"request_id", reqID,
+			"method", "TaskEvents",
+			"task_id", taskID,
+			"err", err,
+		)
+	}()
+
+	return s.Service.TaskEvents(ctx, taskID)
+}
+
+func (s *loggingMiddleware) ResumeTasks(ctx context.Context) (err error) {
+	defer func() {
+		s.logger.Log(
+			"method", "ResumeTasks",
+			"err", err,
+		)
+	}()
+
+	return s.Service.ResumeTasks(ctx)
+}
+
+func (s *loggingMiddleware) Shutdown(ctx context.Context) (err error) {
+	defer func() {
+		s.logger.Log(
+			"method", "Shutdown",
+			"err", err,
+		)
+	}()
+
+	return s.Service.Shutdown(ctx)
+}
+
 func (s *loggingMiddleware) OpenAPI(ctx context.Context) (_ []byte, err error) {
 	reqID, ok := ctx.Value(ContextKeyRequestXRequestID).(string)
 	if !ok {
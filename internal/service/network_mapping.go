@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/ovf"
+	"github.com/vmware/govmomi/vim25/mo"
+	vmware_types "github.com/vmware/govmomi/vim25/types"
+
+	"github.com/vterdunov/janna-api/internal/domain"
+)
+
+// resolvedNetwork is a network of the OVF mapped to an existing vSphere network
+type resolvedNetwork struct {
+	Name    string
+	Network string
+	Kind    domain.NetworkKind
+	Ref     vmware_types.ManagedObjectReference
+}
+
+// resolveNetworks maps the OVF networks to vSphere networks. OVF networks are mapped to
+// the networks with the same name unless the request overrides them, and to the default network
+// if vSphere does not have a network with the same name.
+// It fails if the request maps a network the OVF does not declare, a requested vSphere network
+// does not exist, or an OVF network is left unmapped.
+func (o *Deployment) resolveNetworks(ctx context.Context, declared []string) ([]resolvedNetwork, error) {
+	networks := map[string]string{}
+	requested := map[string]bool{}
+
+	for _, name := range declared {
+		networks[name] = name
+	}
+
+	for _, net := range o.NetworkMapping {
+		if _, ok := networks[net.Name]; !ok {
+			return nil, fmt.Errorf("OVF does not declare network '%s'. Declared networks: %s", net.Name, declaredNetworks(declared))
+		}
+		networks[net.Name] = net.Network
+		requested[net.Name] = true
+	}
+
+	names := make([]string, 0, len(networks))
+	for name := range networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var resolved []resolvedNetwork
+	var unmapped []string
+	for _, name := range names {
+		dst := networks[name]
+		ref, kind, err := o.findNetwork(ctx, dst)
+		if _, notFound := err.(*find.NotFoundError); notFound && !requested[name] {
+			if o.DefaultNetwork == "" {
+				unmapped = append(unmapped, name)
+				continue
+			}
+
+			dst = o.DefaultNetwork
+			ref, kind, err = o.findNetwork(ctx, dst)
+			err = errors.Wrap(err, "could not find default network")
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not map OVF network '%s' to '%s'", name, dst)
+		}
+
+		o.logger.Log("msg", "networks mapping", "name", name, "network", dst, "kind", kind)
+		resolved = append(resolved, resolvedNetwork{
+			Name:    name,
+			Network: dst,
+			Kind:    kind,
+			Ref:     ref.Reference(),
+		})
+	}
+
+	if len(unmapped) != 0 {
+		return nil, fmt.Errorf("OVF networks are not mapped: %s. Pass them in 'networks' or configure the default network", strings.Join(unmapped, ", "))
+	}
+
+	return resolved, nil
+}
+
+// findNetwork finds a standard network, a distributed port group or an NSX segment by name or inventory path.
+// Distributed switches and their uplink port groups can not be used by Virtual Machines.
+func (o *Deployment) findNetwork(ctx context.Context, path string) (object.NetworkReference, domain.NetworkKind, error) {
+	list, err := o.Finder.NetworkList(ctx, path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var found []object.NetworkReference
+	for _, n := range list {
+		if _, ok := n.(*object.DistributedVirtualSwitch); ok {
+			continue
+		}
+		found = append(found, n)
+	}
+
+	switch len(found) {
+	case 0:
+		return nil, "", fmt.Errorf("'%s' is a distributed switch. Pass one of its port groups", path)
+	case 1:
+	default:
+		paths := make([]string, 0, len(found))
+		for _, n := range found {
+			paths = append(paths, networkPath(n))
+		}
+		return nil, "", fmt.Errorf("found %d networks '%s': %s. Pass the inventory path", len(found), path, strings.Join(paths, ", "))
+	}
+
+	switch n := found[0].(type) {
+	case *object.DistributedVirtualPortgroup:
+		var pg mo.DistributedVirtualPortgroup
+		if err := n.Properties(ctx, n.Reference(), []string{"config"}, &pg); err != nil {
+			return nil, "", errors.Wrap(err, "could not get port group config")
+		}
+		if pg.Config.Uplink != nil && *pg.Config.Uplink {
+			return nil, "", fmt.Errorf("'%s' is an uplink port group", path)
+		}
+		return n, domain.NetworkKindDistributedPortGroup, nil
+
+	case *object.OpaqueNetwork:
+		return n, domain.NetworkKindNSXSegment, nil
+
+	default:
+		return n, domain.NetworkKindStandard, nil
+	}
+}
+
+// setNetworks reports the final network mapping in the task result
+func (o *Deployment) setNetworks(networks []resolvedNetwork) {
+	if o.task == nil {
+		return
+	}
+
+	o.task.Update(func(t *domain.Task) {
+		t.Result.Networks = networkMappings(networks)
+	})
+}
+
+func networkPath(n object.NetworkReference) string {
+	switch n := n.(type) {
+	case *object.Network:
+		return n.InventoryPath
+	case *object.DistributedVirtualPortgroup:
+		return n.InventoryPath
+	case *object.OpaqueNetwork:
+		return n.InventoryPath
+	default:
+		return n.Reference().Value
+	}
+}
+
+func ovfNetworkMapping(networks []resolvedNetwork) []vmware_types.OvfNetworkMapping {
+	res := make([]vmware_types.OvfNetworkMapping, 0, len(networks))
+	for _, n := range networks {
+		res = append(res, vmware_types.OvfNetworkMapping{Name: n.Name, Network: n.Ref})
+	}
+	return res
+}
+
+func networkMappings(networks []resolvedNetwork) []domain.NetworkMapping {
+	res := make([]domain.NetworkMapping, 0, len(networks))
+	for _, n := range networks {
+		res = append(res, domain.NetworkMapping{Name: n.Name, Network: n.Network, Kind: n.Kind})
+	}
+	return res
+}
+
+// ovfNetworks returns the names of the networks the OVF descriptor declares
+func ovfNetworks(e *ovf.Envelope) []string {
+	if e.Network == nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(e.Network.Networks))
+	for _, net := range e.Network.Networks {
+		names = append(names, net.Name)
+	}
+
+	return names
+}
+
+func declaredNetworks(names []string) string {
+	if len(names) == 0 {
+		return "none"
+	}
+
+	return strings.Join(names, ", ")
+}
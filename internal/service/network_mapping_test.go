@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vterdunov/janna-api/internal/domain"
+)
+
+func TestDeployment_ResolveNetworks(t *testing.T) {
+	c, cleanup := newTestVCenter(t)
+	defer cleanup()
+
+	tests := []struct {
+		name           string
+		declared       []string
+		mapping        []Network
+		defaultNetwork string
+		want           []domain.NetworkMapping
+		wantErr        string
+	}{
+		{
+			name:     "same name",
+			declared: []string{"VM Network"},
+			want:     []domain.NetworkMapping{{Name: "VM Network", Network: "VM Network", Kind: domain.NetworkKindStandard}},
+		},
+		{
+			name:     "requested port group",
+			declared: []string{"VM Network"},
+			mapping:  []Network{{Name: "VM Network", Network: "DC0_DVPG0"}},
+			want:     []domain.NetworkMapping{{Name: "VM Network", Network: "DC0_DVPG0", Kind: domain.NetworkKindDistributedPortGroup}},
+		},
+		{
+			name:     "inventory path",
+			declared: []string{"VM Network"},
+			mapping:  []Network{{Name: "VM Network", Network: "/DC0/network/DC0_DVPG0"}},
+			want:     []domain.NetworkMapping{{Name: "VM Network", Network: "/DC0/network/DC0_DVPG0", Kind: domain.NetworkKindDistributedPortGroup}},
+		},
+		{
+			name:           "unknown target",
+			declared:       []string{"VM Network"},
+			mapping:        []Network{{Name: "VM Network", Network: "missing"}},
+			defaultNetwork: "DC0_DVPG0",
+			wantErr:        "could not map OVF network 'VM Network' to 'missing'",
+		},
+		{
+			name:     "distributed switch target",
+			declared: []string{"VM Network"},
+			mapping:  []Network{{Name: "VM Network", Network: "DVS0"}},
+			wantErr:  "'DVS0' is a distributed switch",
+		},
+		{
+			name:     "undeclared network",
+			declared: []string{"VM Network"},
+			mapping:  []Network{{Name: "Storage", Network: "VM Network"}},
+			wantErr:  "OVF does not declare network 'Storage'. Declared networks: VM Network",
+		},
+		{
+			name:    "nothing declared",
+			mapping: []Network{{Name: "Storage", Network: "VM Network"}},
+			wantErr: "Declared networks: none",
+		},
+		{
+			name:           "default network",
+			declared:       []string{"Storage", "VM Network"},
+			defaultNetwork: "DC0_DVPG0",
+			want: []domain.NetworkMapping{
+				{Name: "Storage", Network: "DC0_DVPG0", Kind: domain.NetworkKindDistributedPortGroup},
+				{Name: "VM Network", Network: "VM Network", Kind: domain.NetworkKindStandard},
+			},
+		},
+		{
+			name:           "missing default network",
+			declared:       []string{"Storage"},
+			defaultNetwork: "missing",
+			wantErr:        "could not find default network",
+		},
+		{
+			name:     "unmapped networks",
+			declared: []string{"VM Network", "Storage", "Backup"},
+			wantErr:  "OVF networks are not mapped: Backup, Storage",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestDeployment(t, c)
+			d.NetworkMapping = tt.mapping
+			d.DefaultNetwork = tt.defaultNetwork
+
+			got, err := d.resolveNetworks(context.Background(), tt.declared)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Deployment.resolveNetworks() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if mappings := networkMappings(got); !reflect.DeepEqual(mappings, tt.want) {
+				t.Errorf("Deployment.resolveNetworks() = %+v, want %+v", mappings, tt.want)
+			}
+			for _, n := range got {
+				if n.Ref.Value == "" {
+					t.Errorf("Deployment.resolveNetworks() network %s has no reference", n.Name)
+				}
+			}
+		})
+	}
+}
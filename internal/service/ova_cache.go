@@ -0,0 +1,410 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/vim25/soap"
+
+	"github.com/vterdunov/janna-api/internal/domain"
+)
+
+const (
+	cacheExt     = ".ova"
+	cachePartExt = ".part"
+)
+
+var (
+	errTooBigForCache = errors.New("OVA is bigger than the cache")
+	// errCacheFull is returned when the files in use and the other downloads leave no room for the OVA
+	errCacheFull = errors.New("OVA cache is full")
+)
+
+// ovaCache keeps remote OVA files on a local disk, so the same OVA is downloaded once.
+// Files are keyed by URL and a validator: ETag or Digest response header, or the checksum passed by a user.
+// OVA files without a validator are not cached. Least recently used files are evicted when the cache is full.
+type ovaCache struct {
+	mu      sync.Mutex
+	dir     string
+	maxSize int64
+	// size is a total size of the downloaded files and the bytes reserved by the downloads in progress
+	size    int64
+	entries map[string]*cacheEntry
+	// tooBig keeps the keys of the OVA files that turned out to be bigger than the cache
+	tooBig map[string]bool
+
+	client   *soap.Client
+	requests metrics.Counter
+	logger   log.Logger
+}
+
+type cacheEntry struct {
+	key  string
+	path string
+	// size is the file size. While the file is downloaded, it is the reserved size
+	size     int64
+	lastUsed time.Time
+	// users is a number of deploys that read the file or wait for its download
+	users int
+	// ready is closed when the download is finished. err is set if the download has failed
+	ready chan struct{}
+	err   error
+}
+
+// newOVACache creates the cache and restores the files downloaded before Janna restart.
+// It returns nil if the directory is empty, a nil cache passes all OVA files through.
+func newOVACache(dir string, maxSize int64, client *soap.Client, requests metrics.Counter, logger log.Logger) *ovaCache {
+	if dir == "" {
+		return nil
+	}
+
+	c := &ovaCache{
+		dir:      dir,
+		maxSize:  maxSize,
+		entries:  make(map[string]*cacheEntry),
+		tooBig:   make(map[string]bool),
+		client:   client,
+		requests: requests,
+		logger:   logger,
+	}
+
+	c.restore()
+
+	return c
+}
+
+func (c *ovaCache) restore() {
+	files, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			c.logger.Log("msg", "Could not read cache directory", "err", err)
+		}
+		return
+	}
+
+	for _, f := range files {
+		p := filepath.Join(c.dir, f.Name())
+		switch filepath.Ext(f.Name()) {
+		case cachePartExt:
+			// interrupted download
+			os.Remove(p)
+		case cacheExt:
+			key := strings.TrimSuffix(f.Name(), cacheExt)
+			ready := make(chan struct{})
+			close(ready)
+			c.entries[key] = &cacheEntry{
+				key:      key,
+				path:     p,
+				size:     f.Size(),
+				lastUsed: f.ModTime(),
+				ready:    ready,
+			}
+			c.size += f.Size()
+		}
+	}
+
+	c.evict(0)
+}
+
+// open returns a local path of the cached OVA, downloading it on a miss.
+// The file is kept until release is called. Local paths are returned as is with an empty status.
+// A downloaded OVA is verified against the checksum, so hits and misses do not need to be verified again.
+func (c *ovaCache) open(ctx context.Context, ovaURL, ovaChecksum string, t TaskStatuser) (string, domain.OVACacheStatus, func(), error) {
+	noop := func() {}
+	if c == nil || !isRemoteOVA(ovaURL) {
+		return ovaURL, "", noop, nil
+	}
+
+	validator, size := c.validator(ctx, ovaURL, ovaChecksum)
+	if validator == "" || size > c.maxSize {
+		c.requests.With("result", string(domain.OVACacheBypass)).Add(1)
+		return ovaURL, domain.OVACacheBypass, noop, nil
+	}
+	if size < 0 {
+		size = 0
+	}
+
+	var sum *checksum
+	if ovaChecksum != "" {
+		var err error
+		if sum, err = parseChecksum(ovaChecksum); err != nil {
+			return "", "", nil, err
+		}
+	}
+
+	key := cacheKey(ovaURL, validator)
+	bypass := func() (string, domain.OVACacheStatus, func(), error) {
+		c.requests.With("result", string(domain.OVACacheBypass)).Add(1)
+		return ovaURL, domain.OVACacheBypass, noop, nil
+	}
+
+	for {
+		c.mu.Lock()
+		if c.tooBig[key] {
+			c.mu.Unlock()
+			return bypass()
+		}
+
+		e, ok := c.entries[key]
+		if !ok {
+			break
+		}
+		e.users++
+		c.mu.Unlock()
+
+		select {
+		case <-e.ready:
+		case <-ctx.Done():
+			c.release(e)
+			return "", "", nil, ctx.Err()
+		}
+
+		if e.err == errTooBigForCache || e.err == errCacheFull {
+			c.release(e)
+			return bypass()
+		}
+
+		if e.err != nil {
+			// the deploy that was downloading the file has failed, try again
+			c.release(e)
+			continue
+		}
+
+		c.requests.With("result", string(domain.OVACacheHit)).Add(1)
+		return e.path, domain.OVACacheHit, func() { c.release(e) }, nil
+	}
+
+	// the lock is held here. The known size is reserved, the rest is reserved while the file is downloaded
+	e := &cacheEntry{
+		key:   key,
+		path:  filepath.Join(c.dir, key+cacheExt),
+		size:  size,
+		users: 1,
+		ready: make(chan struct{}),
+	}
+	c.entries[key] = e
+	c.size += size
+	c.evict(0)
+	c.mu.Unlock()
+
+	if t != nil {
+		t.Update(func(task *domain.Task) {
+			task.Message = "Downloading OVA to the cache"
+		})
+	}
+
+	c.logger.Log("msg", "OVA cache miss. Downloading", "url", ovaURL)
+	err := c.download(ctx, ovaURL, e, sum)
+
+	c.mu.Lock()
+	e.err = err
+	if err != nil {
+		c.size -= e.size
+		delete(c.entries, key)
+	}
+	if err == errTooBigForCache {
+		c.tooBig[key] = true
+	}
+	c.evict(0)
+	close(e.ready)
+	c.mu.Unlock()
+
+	if err == errTooBigForCache || err == errCacheFull {
+		c.logger.Log("msg", "OVA does not fit to the cache. The cache is bypassed", "url", ovaURL, "err", err)
+		c.release(e)
+		return bypass()
+	}
+
+	if err != nil {
+		c.release(e)
+		return "", "", nil, errors.Wrap(err, "could not download OVA to the cache")
+	}
+
+	c.requests.With("result", string(domain.OVACacheMiss)).Add(1)
+	return e.path, domain.OVACacheMiss, func() { c.release(e) }, nil
+}
+
+// validator returns a value that changes with the OVA content and the OVA size if it is known
+func (c *ovaCache) validator(ctx context.Context, ovaURL, checksum string) (string, int64) {
+	if checksum != "" {
+		return strings.ToLower(checksum), 0
+	}
+
+	u, err := url.Parse(ovaURL)
+	if err != nil {
+		return "", 0
+	}
+
+	res, err := c.client.DownloadRequest(ctx, u, &soap.Download{Method: http.MethodHead})
+	if err != nil {
+		c.logger.Log("msg", "Could not get OVA headers. The cache is bypassed", "url", ovaURL, "err", err)
+		return "", 0
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		c.logger.Log("msg", "Could not get OVA headers. The cache is bypassed", "url", ovaURL, "status", res.Status)
+		return "", 0
+	}
+
+	// weak ETags are fine, OVA files are not served with content encoding
+	validator := res.Header.Get("ETag")
+	if validator == "" {
+		validator = res.Header.Get("Digest")
+	}
+
+	return validator, res.ContentLength
+}
+
+// download saves the OVA to the entry path. The OVA is verified against the user checksum while it is saved
+func (c *ovaCache) download(ctx context.Context, ovaURL string, e *cacheEntry, sum *checksum) error {
+	if err := os.MkdirAll(c.dir, 0750); err != nil {
+		return errors.Wrap(err, "could not create cache directory")
+	}
+
+	u, err := url.Parse(ovaURL)
+	if err != nil {
+		return err
+	}
+
+	body, _, err := c.client.Download(ctx, u, &soap.DefaultDownload)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	part := e.path + cachePartExt
+	f, err := os.Create(part)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = &reservingWriter{w: f, c: c, e: e}
+	var h hash.Hash
+	if sum != nil {
+		h = sum.newHash()
+		w = io.MultiWriter(w, h)
+	}
+
+	size, err := io.Copy(w, io.LimitReader(body, c.maxSize+1))
+	if cErr := f.Close(); err == nil {
+		err = cErr
+	}
+
+	if err == nil && size > c.maxSize {
+		err = errTooBigForCache
+	}
+
+	if err == nil && sum != nil {
+		err = sum.verify("OVA", h)
+	}
+
+	if err != nil {
+		os.Remove(part)
+		return err
+	}
+
+	if err := os.Rename(part, e.path); err != nil {
+		os.Remove(part)
+		return err
+	}
+
+	// the reserved size may be bigger than the file
+	c.mu.Lock()
+	c.size -= e.size - size
+	e.size = size
+	c.mu.Unlock()
+
+	return nil
+}
+
+// reservingWriter reserves the cache space for the bytes written over the entry size
+type reservingWriter struct {
+	w       io.Writer
+	c       *ovaCache
+	e       *cacheEntry
+	written int64
+}
+
+func (r *reservingWriter) Write(p []byte) (int, error) {
+	r.written += int64(len(p))
+
+	r.c.mu.Lock()
+	if grow := r.written - r.e.size; grow > 0 {
+		r.e.size += grow
+		r.c.size += grow
+		r.c.evict(0)
+	}
+	full := r.c.size > r.c.maxSize
+	r.c.mu.Unlock()
+
+	if full && r.written <= r.c.maxSize {
+		return 0, errCacheFull
+	}
+
+	return r.w.Write(p)
+}
+
+func (c *ovaCache) release(e *cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e.users--
+	e.lastUsed = time.Now()
+
+	if e.err == nil {
+		// keep the LRU order after Janna restart
+		os.Chtimes(e.path, e.lastUsed, e.lastUsed) //nolint: errcheck
+	}
+
+	c.evict(0)
+}
+
+// evict removes least recently used files that are not in use until there is room for the reserved bytes.
+// Must be called with the lock held.
+func (c *ovaCache) evict(reserve int64) {
+	for c.size+reserve > c.maxSize {
+		var oldest *cacheEntry
+		for _, e := range c.entries {
+			if e.users > 0 || e.size == 0 {
+				continue
+			}
+			if oldest == nil || e.lastUsed.Before(oldest.lastUsed) {
+				oldest = e
+			}
+		}
+
+		if oldest == nil {
+			return
+		}
+
+		delete(c.entries, oldest.key)
+		c.size -= oldest.size
+		if err := os.Remove(oldest.path); err != nil && !os.IsNotExist(err) {
+			c.logger.Log("msg", "Could not remove cached OVA", "path", oldest.path, "err", err)
+		}
+	}
+}
+
+func cacheKey(ovaURL, validator string) string {
+	sum := sha256.Sum256([]byte(ovaURL + "\n" + validator))
+	return hex.EncodeToString(sum[:])
+}
+
+func isRemoteOVA(ovaURL string) bool {
+	return strings.HasPrefix(ovaURL, "http://") || strings.HasPrefix(ovaURL, "https://")
+}
@@ -0,0 +1,392 @@
+package service
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics/discard"
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/vim25/soap"
+
+	"github.com/vterdunov/janna-api/internal/domain"
+)
+
+// ovaServer serves files with an ETag. Paths starting with '/noetag' are served without it,
+// paths starting with '/chunked' do not report the size in HEAD responses
+type ovaServer struct {
+	*httptest.Server
+
+	mu   sync.Mutex
+	gets map[string]int
+}
+
+func newOVAServer() *ovaServer {
+	s := &ovaServer{gets: map[string]int{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := r.URL.Path
+		content := "content of " + p
+
+		if r.Method == http.MethodGet {
+			s.mu.Lock()
+			s.gets[p]++
+			s.mu.Unlock()
+		}
+
+		if !hasPrefix(p, "/noetag") {
+			w.Header().Set("ETag", `"`+p+`"`)
+		}
+		if r.Method == http.MethodHead {
+			if !hasPrefix(p, "/chunked") {
+				w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			}
+			return
+		}
+
+		w.Write([]byte(content)) //nolint: errcheck
+	}))
+	return s
+}
+
+func (s *ovaServer) downloads(p string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.gets[p]
+}
+
+func hasPrefix(s, prefix string) bool {
+	return len(s) >= len(prefix) && s[:len(prefix)] == prefix
+}
+
+func newTestOVACache(t *testing.T, dir string, maxSize int64) *ovaCache {
+	t.Helper()
+
+	u, err := url.Parse("http://127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return newOVACache(dir, maxSize, soap.NewClient(u, true), discard.NewCounter(), log.NewNopLogger())
+}
+
+func testCacheDir(t *testing.T) string {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "janna-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// openOVA opens the OVA in the cache and checks the returned file content
+func openOVA(t *testing.T, c *ovaCache, ovaURL, checksum string) (domain.OVACacheStatus, func()) {
+	t.Helper()
+
+	src, status, release, err := c.open(context.Background(), ovaURL, checksum, nil)
+	if err != nil {
+		t.Fatalf("ovaCache.open() error = %v", err)
+	}
+
+	if status == domain.OVACacheHit || status == domain.OVACacheMiss {
+		u, _ := url.Parse(ovaURL)
+		data, err := ioutil.ReadFile(src)
+		if err != nil || string(data) != "content of "+u.Path {
+			t.Errorf("ovaCache.open() file content = %q, %v", data, err)
+		}
+	} else if src != ovaURL {
+		t.Errorf("ovaCache.open() = %v, want the URL %v", src, ovaURL)
+	}
+
+	return status, release
+}
+
+func TestOVACache_Open(t *testing.T) {
+	srv := newOVAServer()
+	defer srv.Close()
+
+	dir := testCacheDir(t)
+	defer os.RemoveAll(dir)
+	c := newTestOVACache(t, dir, 1<<20)
+
+	tests := []struct {
+		name          string
+		path          string
+		wantStatus    domain.OVACacheStatus
+		wantDownloads int
+	}{
+		{"miss", "/a.ova", domain.OVACacheMiss, 1},
+		{"hit", "/a.ova", domain.OVACacheHit, 1},
+		{"other file", "/b.ova", domain.OVACacheMiss, 1},
+		{"no validator", "/noetag.ova", domain.OVACacheBypass, 0},
+		{"no validator again", "/noetag.ova", domain.OVACacheBypass, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, release := openOVA(t, c, srv.URL+tt.path, "")
+			release()
+
+			if status != tt.wantStatus {
+				t.Errorf("ovaCache.open() status = %v, want %v", status, tt.wantStatus)
+			}
+			if got := srv.downloads(tt.path); got != tt.wantDownloads {
+				t.Errorf("ovaCache.open() downloaded the file %d times, want %d", got, tt.wantDownloads)
+			}
+		})
+	}
+}
+
+func TestOVACache_OpenLocal(t *testing.T) {
+	var nilCache *ovaCache
+	dir := testCacheDir(t)
+	defer os.RemoveAll(dir)
+
+	for _, c := range []*ovaCache{nilCache, newTestOVACache(t, dir, 1<<20)} {
+		src, status, release, err := c.open(context.Background(), "/tmp/local.ova", "", nil)
+		release()
+		if err != nil || src != "/tmp/local.ova" || status != "" {
+			t.Errorf("ovaCache.open() = %v, %v, %v, want the local path", src, status, err)
+		}
+	}
+}
+
+func TestOVACache_OpenChecksum(t *testing.T) {
+	srv := newOVAServer()
+	defer srv.Close()
+
+	dir := testCacheDir(t)
+	defer os.RemoveAll(dir)
+	c := newTestOVACache(t, dir, 1<<20)
+
+	good := "sha256:" + sha256Hex("content of /noetag.ova")
+	bad := "sha256:" + sha256Hex("other content")
+
+	// the checksum is a validator, so a file without ETag is cached
+	if status, release := openOVA(t, c, srv.URL+"/noetag.ova", good); status != domain.OVACacheMiss {
+		t.Errorf("ovaCache.open() status = %v, want %v", status, domain.OVACacheMiss)
+	} else {
+		release()
+	}
+
+	_, _, _, err := c.open(context.Background(), srv.URL+"/noetag.ova", bad, nil)
+	if _, ok := errors.Cause(err).(*domain.IntegrityError); !ok {
+		t.Fatalf("ovaCache.open() error = %v, want integrity error", err)
+	}
+	if len(c.entries) != 1 || c.size != int64(len("content of /noetag.ova")) {
+		t.Errorf("ovaCache kept %d entries of %d bytes after a failed check", len(c.entries), c.size)
+	}
+}
+
+func TestOVACache_TooBig(t *testing.T) {
+	srv := newOVAServer()
+	defer srv.Close()
+
+	dir := testCacheDir(t)
+	defer os.RemoveAll(dir)
+	c := newTestOVACache(t, dir, 10)
+
+	tests := []struct {
+		name          string
+		path          string
+		wantDownloads int
+	}{
+		{"known size", "/big.ova", 0},
+		{"unknown size", "/chunked-big.ova", 1},
+		{"unknown size is remembered", "/chunked-big.ova", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, release := openOVA(t, c, srv.URL+tt.path, "")
+			release()
+
+			if status != domain.OVACacheBypass {
+				t.Errorf("ovaCache.open() status = %v, want %v", status, domain.OVACacheBypass)
+			}
+			if got := srv.downloads(tt.path); got != tt.wantDownloads {
+				t.Errorf("ovaCache.open() downloaded the file %d times, want %d", got, tt.wantDownloads)
+			}
+			if c.size != 0 {
+				t.Errorf("ovaCache size = %d, want 0", c.size)
+			}
+		})
+	}
+}
+
+func TestOVACache_ConcurrentOpen(t *testing.T) {
+	srv := newOVAServer()
+	defer srv.Close()
+
+	dir := testCacheDir(t)
+	defer os.RemoveAll(dir)
+	c := newTestOVACache(t, dir, 1<<20)
+
+	const deploys = 10
+	statuses := make(chan domain.OVACacheStatus, deploys)
+	var wg sync.WaitGroup
+	for i := 0; i < deploys; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			src, status, release, err := c.open(context.Background(), srv.URL+"/a.ova", "", nil)
+			if err != nil {
+				t.Errorf("ovaCache.open() error = %v", err)
+				return
+			}
+			if _, err := os.Stat(src); err != nil {
+				t.Errorf("ovaCache.open() returned a missing file: %v", err)
+			}
+			release()
+			statuses <- status
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	count := map[domain.OVACacheStatus]int{}
+	for s := range statuses {
+		count[s]++
+	}
+	if count[domain.OVACacheMiss] != 1 || count[domain.OVACacheHit] != deploys-1 {
+		t.Errorf("ovaCache.open() statuses = %v, want a single miss", count)
+	}
+	if got := srv.downloads("/a.ova"); got != 1 {
+		t.Errorf("ovaCache.open() downloaded the file %d times, want 1", got)
+	}
+}
+
+func TestOVACache_Evict(t *testing.T) {
+	srv := newOVAServer()
+	defer srv.Close()
+
+	dir := testCacheDir(t)
+	defer os.RemoveAll(dir)
+
+	// every file is 17 bytes, two of them fit
+	c := newTestOVACache(t, dir, 40)
+	open := func(p string) (domain.OVACacheStatus, func()) {
+		return openOVA(t, c, srv.URL+p, "")
+	}
+
+	_, release := open("/a.ova")
+	release()
+	_, release = open("/b.ova")
+	release()
+	// 'a' becomes the most recently used
+	time.Sleep(10 * time.Millisecond)
+	if status, release := open("/a.ova"); status != domain.OVACacheHit {
+		t.Fatalf("ovaCache.open() status = %v, want %v", status, domain.OVACacheHit)
+	} else {
+		release()
+	}
+
+	status, releaseC := open("/c.ova")
+	if status != domain.OVACacheMiss {
+		t.Fatalf("ovaCache.open() status = %v, want %v", status, domain.OVACacheMiss)
+	}
+
+	if status, release := open("/b.ova"); status != domain.OVACacheMiss {
+		t.Errorf("least recently used file was not evicted, status = %v", status)
+	} else {
+		release()
+	}
+	releaseC()
+
+	if c.size > c.maxSize {
+		t.Errorf("ovaCache size = %d, over the max size %d", c.size, c.maxSize)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*"+cacheExt))
+	if len(files) != len(c.entries) || len(files) != 2 {
+		t.Errorf("cache directory has %d files for %d entries, want 2", len(files), len(c.entries))
+	}
+}
+
+func TestOVACache_InUse(t *testing.T) {
+	srv := newOVAServer()
+	defer srv.Close()
+
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"known size", "/c.ova"},
+		{"unknown size", "/chunked-c.ova"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := testCacheDir(t)
+			defer os.RemoveAll(dir)
+			c := newTestOVACache(t, dir, 40)
+
+			// files in use are not evicted, so there is no room for the third one
+			_, releaseA := openOVA(t, c, srv.URL+"/a.ova", "")
+			defer releaseA()
+			_, releaseB := openOVA(t, c, srv.URL+"/b.ova", "")
+			defer releaseB()
+
+			status, release := openOVA(t, c, srv.URL+tt.path, "")
+			release()
+
+			if status != domain.OVACacheBypass {
+				t.Errorf("ovaCache.open() status = %v, want %v", status, domain.OVACacheBypass)
+			}
+			if c.size != 34 || len(c.entries) != 2 {
+				t.Errorf("ovaCache has %d entries of %d bytes, want 2 entries of 34 bytes", len(c.entries), c.size)
+			}
+
+			// the cache was full, the file is not too big
+			if c.tooBig[cacheKey(srv.URL+tt.path, `"`+tt.path+`"`)] {
+				t.Error("ovaCache remembered the file as too big")
+			}
+		})
+	}
+}
+
+func TestOVACache_Restore(t *testing.T) {
+	srv := newOVAServer()
+	defer srv.Close()
+
+	dir := testCacheDir(t)
+	defer os.RemoveAll(dir)
+
+	write := func(name, content string, age time.Duration) {
+		p := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(p, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		mtime := time.Now().Add(-age)
+		if err := os.Chtimes(p, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	keyA := cacheKey(srv.URL+"/a.ova", `"/a.ova"`)
+	write(keyA+cacheExt, "content of /a.ova", time.Minute)
+	write("old"+cacheExt, "content of /old.ova", time.Hour)
+	write("interrupted"+cacheExt+cachePartExt, "content", time.Minute)
+
+	// only one file fits
+	c := newTestOVACache(t, dir, 20)
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(files) != 1 || filepath.Base(files[0]) != keyA+cacheExt {
+		t.Errorf("restored cache directory has files %v, want only the recent one", files)
+	}
+	if c.size != int64(len("content of /a.ova")) {
+		t.Errorf("restored cache size = %d, want %d", c.size, len("content of /a.ova"))
+	}
+
+	status, release := openOVA(t, c, srv.URL+"/a.ova", "")
+	release()
+	if status != domain.OVACacheHit || srv.downloads("/a.ova") != 0 {
+		t.Errorf("ovaCache.open() status = %v after restore, want %v without a download", status, domain.OVACacheHit)
+	}
+}
@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/ovf"
+
+	"github.com/vterdunov/janna-api/internal/domain"
+	"github.com/vterdunov/janna-api/internal/types"
+)
+
+// CIM resource types, see DSP0243 and CIM_ResourceAllocationSettingData
+const (
+	cimResourceTypeProcessor = 3
+	cimResourceTypeMemory    = 4
+)
+
+// allocationUnitsRe matches programmatic units like 'byte * 2^20'
+var allocationUnitsRe = regexp.MustCompile(`^byte\s*(?:\*\s*2\^\s*(\d+))?$`)
+
+// namedAllocationUnits are the legacy unit names some OVF tools produce
+var namedAllocationUnits = map[string]int64{
+	"kilobytes": 1 << 10,
+	"megabytes": 1 << 20,
+	"gigabytes": 1 << 30,
+}
+
+// OVAInspect reads the OVF descriptor of the OVA package. It downloads the package
+// until the descriptor is found, but does not call vCenter API.
+func (s *service) OVAInspect(ctx context.Context, params *types.OVAInspectParams) (*domain.OVAInfo, error) {
+	ovaPath, release, err := s.resolveOVA(params.OVAURL)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	ova, err := readOVA(s.Client, ovaPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read OVA")
+	}
+
+	return ovaInfo(ova.envelope), nil
+}
+
+func ovaInfo(e *ovf.Envelope) *domain.OVAInfo {
+	info := &domain.OVAInfo{
+		Name: "Virtual Appliance",
+	}
+
+	if e.Annotation != nil {
+		info.Annotation = e.Annotation.Annotation
+	}
+
+	if e.Network != nil {
+		for _, n := range e.Network.Networks {
+			info.Networks = append(info.Networks, domain.OVANetwork{
+				Name:        n.Name,
+				Description: n.Description,
+			})
+		}
+	}
+
+	info.Disks = ovaDisks(e)
+
+	props := ovfProperties(e)
+	keys := make([]string, 0, len(props))
+	for k := range props {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		p := props[k]
+		info.Properties = append(info.Properties, domain.OVAProperty{
+			Key:              k,
+			Type:             p.Type,
+			Label:            stringValue(p.Label),
+			Description:      stringValue(p.Description),
+			Default:          stringValue(p.Default),
+			UserConfigurable: p.UserConfigurable != nil && *p.UserConfigurable,
+			Password:         p.Password != nil && *p.Password,
+		})
+	}
+
+	if e.DeploymentOption != nil {
+		for _, c := range e.DeploymentOption.Configuration {
+			info.DeploymentOptions = append(info.DeploymentOptions, domain.OVADeploymentOption{
+				ID:          c.ID,
+				Label:       c.Label,
+				Description: c.Description,
+				Default:     c.Default != nil && *c.Default,
+			})
+		}
+	}
+
+	if e.VirtualSystem != nil {
+		info.Name = e.VirtualSystem.ID
+		if e.VirtualSystem.Name != nil {
+			info.Name = *e.VirtualSystem.Name
+		}
+
+		if len(e.VirtualSystem.Annotation) != 0 && info.Annotation == "" {
+			info.Annotation = e.VirtualSystem.Annotation[0].Annotation
+		}
+
+		if len(e.VirtualSystem.VirtualHardware) != 0 {
+			info.Hardware = ovaHardware(&e.VirtualSystem.VirtualHardware[0])
+		}
+	}
+
+	return info
+}
+
+func ovaDisks(e *ovf.Envelope) []domain.OVADisk {
+	if e.Disk == nil {
+		return nil
+	}
+
+	files := make(map[string]ovf.File, len(e.References))
+	for _, f := range e.References {
+		files[f.ID] = f
+	}
+
+	disks := make([]domain.OVADisk, 0, len(e.Disk.Disks))
+	for _, d := range e.Disk.Disks {
+		disk := domain.OVADisk{
+			ID: d.DiskID,
+		}
+
+		// capacity can be a reference to a vApp property, then it is unknown before the deploy
+		if capacity, err := strconv.ParseInt(d.Capacity, 10, 64); err == nil {
+			disk.CapacityBytes = capacity * allocationUnits(stringValue(d.CapacityAllocationUnits))
+		}
+
+		if d.PopulatedSize != nil {
+			disk.PopulatedSizeBytes = int64(*d.PopulatedSize)
+		}
+
+		if d.FileRef != nil {
+			if f, ok := files[*d.FileRef]; ok {
+				disk.File = f.Href
+				disk.FileSizeBytes = int64(f.Size)
+			}
+		}
+
+		disks = append(disks, disk)
+	}
+
+	return disks
+}
+
+// ovaHardware reads CPU and memory of the default configuration
+func ovaHardware(hw *ovf.VirtualHardwareSection) domain.OVAHardware {
+	var res domain.OVAHardware
+	if hw.System != nil && hw.System.VirtualSystemType != nil {
+		res.VirtualSystemType = *hw.System.VirtualSystemType
+	}
+
+	for _, item := range hw.Item {
+		// items with configuration attribute belong to a non default deployment option
+		if item.Configuration != nil || item.ResourceType == nil || item.VirtualQuantity == nil {
+			continue
+		}
+
+		qty := int64(*item.VirtualQuantity)
+		switch *item.ResourceType {
+		case cimResourceTypeProcessor:
+			res.NumCPU = qty
+		case cimResourceTypeMemory:
+			// OVF spec default for memory is megabytes
+			units := int64(1 << 20)
+			if item.AllocationUnits != nil {
+				units = allocationUnits(*item.AllocationUnits)
+			}
+			res.MemoryMB = qty * units >> 20
+		}
+	}
+
+	return res
+}
+
+// allocationUnits returns a number of bytes in the unit. Unknown units are treated as bytes
+func allocationUnits(units string) int64 {
+	units = strings.ToLower(strings.TrimSpace(units))
+	if n, ok := namedAllocationUnits[units]; ok {
+		return n
+	}
+
+	m := allocationUnitsRe.FindStringSubmatch(units)
+	if m == nil || m[1] == "" {
+		return 1
+	}
+
+	exp, err := strconv.Atoi(m[1])
+	if err != nil || exp > 62 {
+		return 1
+	}
+
+	return 1 << uint(exp)
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/soap"
+
+	"github.com/vterdunov/janna-api/internal/domain"
+	"github.com/vterdunov/janna-api/internal/types"
+)
+
+const testInspectOVF = `<?xml version="1.0" encoding="UTF-8"?>
+<Envelope xmlns="http://schemas.dmtf.org/ovf/envelope/1" xmlns:ovf="http://schemas.dmtf.org/ovf/envelope/1"
+    xmlns:rasd="http://schemas.dmtf.org/wbem/wscim/1/cim-schema/2/CIM_ResourceAllocationSettingData"
+    xmlns:vssd="http://schemas.dmtf.org/wbem/wscim/1/cim-schema/2/CIM_VirtualSystemSettingData">
+  <References>
+    <File ovf:id="file1" ovf:href="disk1.vmdk" ovf:size="1024"/>
+  </References>
+  <DiskSection>
+    <Info>Virtual disks</Info>
+    <Disk ovf:diskId="vmdisk1" ovf:fileRef="file1" ovf:capacity="10" ovf:capacityAllocationUnits="byte * 2^30" ovf:populatedSize="2048"/>
+    <Disk ovf:diskId="vmdisk2" ovf:capacity="${disk_size}"/>
+  </DiskSection>
+  <NetworkSection>
+    <Info>Networks</Info>
+    <Network ovf:name="VM Network">
+      <Description>The VM Network network</Description>
+    </Network>
+  </NetworkSection>
+  <DeploymentOptionSection>
+    <Info>Deployment options</Info>
+    <Configuration ovf:id="small" ovf:default="true">
+      <Label>Small</Label>
+      <Description>2 CPU, 4 GB</Description>
+    </Configuration>
+    <Configuration ovf:id="large">
+      <Label>Large</Label>
+      <Description>8 CPU, 16 GB</Description>
+    </Configuration>
+  </DeploymentOptionSection>
+  <VirtualSystem ovf:id="ubuntu">
+    <Info>A virtual machine</Info>
+    <Name>Ubuntu 18.04</Name>
+    <AnnotationSection>
+      <Info>Annotation</Info>
+      <Annotation>Ubuntu server</Annotation>
+    </AnnotationSection>
+    <ProductSection>
+      <Info>Properties</Info>
+      <Property ovf:key="hostname" ovf:type="string" ovf:userConfigurable="true" ovf:value="ubuntu">
+        <Label>Hostname</Label>
+      </Property>
+      <Property ovf:key="password" ovf:type="string" ovf:userConfigurable="true" ovf:password="true"/>
+    </ProductSection>
+    <VirtualHardwareSection>
+      <Info>Virtual hardware</Info>
+      <System>
+        <vssd:VirtualSystemType>vmx-13</vssd:VirtualSystemType>
+      </System>
+      <Item>
+        <rasd:AllocationUnits>hertz * 10^6</rasd:AllocationUnits>
+        <rasd:ResourceType>3</rasd:ResourceType>
+        <rasd:VirtualQuantity>2</rasd:VirtualQuantity>
+      </Item>
+      <Item ovf:configuration="large">
+        <rasd:ResourceType>3</rasd:ResourceType>
+        <rasd:VirtualQuantity>8</rasd:VirtualQuantity>
+      </Item>
+      <Item>
+        <rasd:AllocationUnits>byte * 2^30</rasd:AllocationUnits>
+        <rasd:ResourceType>4</rasd:ResourceType>
+        <rasd:VirtualQuantity>4</rasd:VirtualQuantity>
+      </Item>
+    </VirtualHardwareSection>
+  </VirtualSystem>
+</Envelope>`
+
+func TestService_OVAInspect(t *testing.T) {
+	p := writeTestOVA(t, map[string]string{"ubuntu.ovf": testInspectOVF, "disk1.vmdk": testDisk})
+	defer os.RemoveAll(filepath.Dir(p))
+
+	u, err := url.Parse("https://vcenter.example.com/sdk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &service{Client: &vim25.Client{Client: soap.NewClient(u, true)}}
+
+	got, err := s.OVAInspect(context.Background(), &types.OVAInspectParams{OVAURL: p})
+	if err != nil {
+		t.Fatalf("service.OVAInspect() error = %v", err)
+	}
+
+	want := &domain.OVAInfo{
+		Name:       "Ubuntu 18.04",
+		Annotation: "Ubuntu server",
+		Networks:   []domain.OVANetwork{{Name: "VM Network", Description: "The VM Network network"}},
+		Disks: []domain.OVADisk{
+			{ID: "vmdisk1", File: "disk1.vmdk", CapacityBytes: 10 << 30, PopulatedSizeBytes: 2048, FileSizeBytes: 1024},
+			// the capacity is defined by a vApp property
+			{ID: "vmdisk2"},
+		},
+		Properties: []domain.OVAProperty{
+			{Key: "hostname", Type: "string", Label: "Hostname", Default: "ubuntu", UserConfigurable: true},
+			{Key: "password", Type: "string", UserConfigurable: true, Password: true},
+		},
+		DeploymentOptions: []domain.OVADeploymentOption{
+			{ID: "small", Label: "Small", Description: "2 CPU, 4 GB", Default: true},
+			{ID: "large", Label: "Large", Description: "8 CPU, 16 GB"},
+		},
+		Hardware: domain.OVAHardware{VirtualSystemType: "vmx-13", NumCPU: 2, MemoryMB: 4096},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("service.OVAInspect() = %+v, want %+v", got, want)
+	}
+}
+
+func TestAllocationUnits(t *testing.T) {
+	tests := []struct {
+		units string
+		want  int64
+	}{
+		{"byte", 1},
+		{"byte * 2^20", 1 << 20},
+		{"BYTE*2^30", 1 << 30},
+		{"megabytes", 1 << 20},
+		{"GigaBytes", 1 << 30},
+		{"byte * 2^63", 1},
+		{"hertz * 10^6", 1},
+		{"", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.units, func(t *testing.T) {
+			if got := allocationUnits(tt.units); got != tt.want {
+				t.Errorf("allocationUnits(%q) = %d, want %d", tt.units, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1" //nolint: gosec
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/govc/importx"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/soap"
+
+	"github.com/vterdunov/janna-api/internal/domain"
+)
+
+// manifestLineRe matches OVF manifest lines like 'SHA256(disk1.vmdk)= 1f2e...'
+var manifestLineRe = regexp.MustCompile(`^(SHA1|SHA256|SHA512)\((.+)\)\s*=\s*([0-9a-fA-F]+)$`)
+
+// checksum is an expected digest of a file
+type checksum struct {
+	// algo is one of sha1, sha256, sha512
+	algo string
+	// sum is a lower case hex digest
+	sum string
+}
+
+// parseChecksum parses a user checksum in '<algo>:<hex>' form
+func parseChecksum(s string) (*checksum, error) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid checksum '%s'. Use '<algorithm>:<hex digest>' form", s)
+	}
+
+	c := &checksum{algo: strings.ToLower(parts[0]), sum: strings.ToLower(parts[1])}
+	h := c.newHash()
+	if h == nil {
+		return nil, fmt.Errorf("unsupported checksum algorithm '%s'. Possible values are sha1, sha256, sha512", parts[0])
+	}
+
+	if _, err := hex.DecodeString(c.sum); err != nil || len(c.sum) != h.Size()*2 {
+		return nil, fmt.Errorf("invalid %s checksum digest '%s'", c.algo, parts[1])
+	}
+
+	return c, nil
+}
+
+func (c *checksum) newHash() hash.Hash {
+	switch c.algo {
+	case "sha1":
+		return sha1.New() //nolint: gosec
+	case "sha256":
+		return sha256.New()
+	case "sha512":
+		return sha512.New()
+	}
+	return nil
+}
+
+// verify compares the digest with the expected one
+func (c *checksum) verify(name string, h hash.Hash) error {
+	if got := hex.EncodeToString(h.Sum(nil)); got != c.sum {
+		return &domain.IntegrityError{
+			Message: fmt.Sprintf("%s %s mismatch: expected %s, got %s", name, strings.ToUpper(c.algo), c.sum, got),
+		}
+	}
+	return nil
+}
+
+// ovaManifest keeps the checksums from the OVA .mf file by file names.
+// A nil manifest means the OVA does not have one and files are not verified.
+type ovaManifest map[string]*checksum
+
+// readManifest reads the .mf file from the OVA. It returns nil if there is no manifest
+func readManifest(archive importx.ArchiveFlag) (ovaManifest, error) {
+	f, _, err := archive.Open("*.mf")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "could not open manifest")
+	}
+	defer f.Close()
+
+	m := ovaManifest{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		match := manifestLineRe.FindStringSubmatch(line)
+		if match == nil {
+			return nil, &domain.IntegrityError{Message: fmt.Sprintf("malformed manifest line '%s'", line)}
+		}
+
+		m[path.Base(match[2])] = &checksum{
+			algo: strings.ToLower(match[1]),
+			sum:  strings.ToLower(match[3]),
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "could not read manifest")
+	}
+
+	return m, nil
+}
+
+// verifyDescriptor checks the OVF descriptor. The descriptor file name is not known,
+// so it is compared with the only .ovf entry of the manifest
+func (m ovaManifest) verifyDescriptor(ovfData []byte) error {
+	if m == nil {
+		return nil
+	}
+
+	var name string
+	for n := range m {
+		if path.Ext(n) == ".ovf" {
+			if name != "" {
+				return &domain.IntegrityError{Message: "manifest lists several OVF descriptors"}
+			}
+			name = n
+		}
+	}
+
+	if name == "" {
+		return &domain.IntegrityError{Message: "OVF descriptor is not listed in the manifest"}
+	}
+
+	c := m[name]
+	h := c.newHash()
+	h.Write(ovfData) //nolint: errcheck
+
+	return c.verify(name, h)
+}
+
+// reader wraps the file reader to compute its digest while it is uploaded.
+// The returned function checks the digest after the whole file was read.
+func (m ovaManifest) reader(file string, r io.Reader) (io.Reader, func() error, error) {
+	if m == nil {
+		return r, func() error { return nil }, nil
+	}
+
+	name := path.Base(file)
+	c, ok := m[name]
+	if !ok {
+		return nil, nil, &domain.IntegrityError{Message: fmt.Sprintf("file '%s' is not listed in the manifest", name)}
+	}
+
+	h := c.newHash()
+	verify := func() error {
+		return c.verify(name, h)
+	}
+
+	return io.TeeReader(r, h), verify, nil
+}
+
+// verifyOVAChecksum reads the whole OVA and compares its digest with the user checksum.
+// A remote OVA is streamed through the hash without saving it to disk
+func verifyOVAChecksum(ctx context.Context, client *vim25.Client, ovaPath string, c *checksum) error {
+	var f io.ReadCloser
+	if isRemoteOVA(ovaPath) {
+		u, err := url.Parse(ovaPath)
+		if err != nil {
+			return errors.Wrap(err, "could not parse OVA URL")
+		}
+
+		f, _, err = client.Download(ctx, u, &soap.DefaultDownload)
+		if err != nil {
+			return errors.Wrap(err, "could not download OVA")
+		}
+	} else {
+		var err error
+		f, err = os.Open(ovaPath)
+		if err != nil {
+			return errors.Wrap(err, "could not open OVA")
+		}
+	}
+	defer f.Close()
+
+	h := c.newHash()
+	if _, err := io.Copy(h, f); err != nil {
+		return errors.Wrap(err, "could not read OVA")
+	}
+
+	return c.verify("OVA", h)
+}
@@ -0,0 +1,288 @@
+package service
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha1" //nolint: gosec
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/vmware/govmomi/govc/importx"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/soap"
+
+	"github.com/vterdunov/janna-api/internal/domain"
+)
+
+const (
+	testOVF  = "<Envelope/>"
+	testDisk = "disk content"
+)
+
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s)) //nolint: gosec
+	return hex.EncodeToString(sum[:])
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// writeTestOVA writes a tar archive with the files to a temporary directory
+func writeTestOVA(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "janna-ova")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := filepath.Join(dir, "test.ova")
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w := tar.NewWriter(f)
+	for _, name := range names {
+		if err := w.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(files[name]))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(files[name])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+func testArchive(path string) importx.ArchiveFlag {
+	return importx.ArchiveFlag{Archive: TapeArchive{path: path}}
+}
+
+func isIntegrityError(err error) bool {
+	_, ok := err.(*domain.IntegrityError)
+	return ok
+}
+
+func TestParseChecksum(t *testing.T) {
+	sum := sha256Hex(testDisk)
+
+	tests := []struct {
+		name    string
+		s       string
+		want    *checksum
+		wantErr bool
+	}{
+		{"sha256", "sha256:" + sum, &checksum{algo: "sha256", sum: sum}, false},
+		{"upper case", "SHA1:" + strings.ToUpper(sha1Hex(testDisk)), &checksum{algo: "sha1", sum: sha1Hex(testDisk)}, false},
+		{"no algorithm", sum, nil, true},
+		{"unknown algorithm", "md5:" + sum, nil, true},
+		{"not hex", "sha256:" + strings.Repeat("z", 64), nil, true},
+		{"wrong length", "sha256:" + sha1Hex(testDisk), nil, true},
+		{"empty digest", "sha256:", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseChecksum(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseChecksum() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseChecksum() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadManifest(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		want     ovaManifest
+		wantErr  bool
+	}{
+		{
+			name: "sha1 and sha256",
+			manifest: fmt.Sprintf("SHA1(test.ovf)= %s\n\nSHA256(disks/disk1.vmdk)=%s\n",
+				strings.ToUpper(sha1Hex(testOVF)), sha256Hex(testDisk)),
+			want: ovaManifest{
+				"test.ovf":   {algo: "sha1", sum: sha1Hex(testOVF)},
+				"disk1.vmdk": {algo: "sha256", sum: sha256Hex(testDisk)},
+			},
+		},
+		{
+			name:     "malformed line",
+			manifest: "MD5(test.ovf)= 0123\n",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := writeTestOVA(t, map[string]string{"test.ovf": testOVF, "test.mf": tt.manifest})
+			defer os.RemoveAll(filepath.Dir(p))
+
+			got, err := readManifest(testArchive(p))
+			if tt.wantErr {
+				if !isIntegrityError(err) {
+					t.Errorf("readManifest() error = %v, want integrity error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readManifest() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadManifest_Missing(t *testing.T) {
+	p := writeTestOVA(t, map[string]string{"test.ovf": testOVF})
+	defer os.RemoveAll(filepath.Dir(p))
+
+	got, err := readManifest(testArchive(p))
+	if err != nil || got != nil {
+		t.Errorf("readManifest() = %v, %v, want no manifest", got, err)
+	}
+}
+
+func TestOVAManifest_VerifyDescriptor(t *testing.T) {
+	disk := &checksum{algo: "sha256", sum: sha256Hex(testDisk)}
+
+	tests := []struct {
+		name     string
+		manifest ovaManifest
+		wantErr  bool
+	}{
+		{"no manifest", nil, false},
+		{"sha256", ovaManifest{"test.ovf": {algo: "sha256", sum: sha256Hex(testOVF)}, "disk1.vmdk": disk}, false},
+		{"sha1", ovaManifest{"test.ovf": {algo: "sha1", sum: sha1Hex(testOVF)}}, false},
+		{"wrong digest", ovaManifest{"test.ovf": {algo: "sha256", sum: sha256Hex("other")}}, true},
+		{"sha1 digest as sha256", ovaManifest{"test.ovf": {algo: "sha256", sum: sha1Hex(testOVF)}}, true},
+		{"not listed", ovaManifest{"disk1.vmdk": disk}, true},
+		{"several descriptors", ovaManifest{
+			"a.ovf": {algo: "sha256", sum: sha256Hex(testOVF)},
+			"b.ovf": {algo: "sha256", sum: sha256Hex(testOVF)},
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.manifest.verifyDescriptor([]byte(testOVF))
+			if tt.wantErr != (err != nil) || (err != nil && !isIntegrityError(err)) {
+				t.Errorf("ovaManifest.verifyDescriptor() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOVAManifest_Reader(t *testing.T) {
+	tests := []struct {
+		name       string
+		manifest   ovaManifest
+		wantErr    bool
+		wantVerify bool
+	}{
+		{"no manifest", nil, false, false},
+		{"sha256", ovaManifest{"disk1.vmdk": {algo: "sha256", sum: sha256Hex(testDisk)}}, false, false},
+		{"sha1", ovaManifest{"disk1.vmdk": {algo: "sha1", sum: sha1Hex(testDisk)}}, false, false},
+		{"wrong digest", ovaManifest{"disk1.vmdk": {algo: "sha256", sum: sha256Hex("other")}}, false, true},
+		{"missing file", ovaManifest{"disk2.vmdk": {algo: "sha256", sum: sha256Hex(testDisk)}}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, verify, err := tt.manifest.reader("disks/disk1.vmdk", strings.NewReader(testDisk))
+			if tt.wantErr {
+				if !isIntegrityError(err) {
+					t.Errorf("ovaManifest.reader() error = %v, want integrity error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := ioutil.ReadAll(r)
+			if err != nil || string(data) != testDisk {
+				t.Fatalf("ovaManifest.reader() read %q, %v", data, err)
+			}
+
+			err = verify()
+			if tt.wantVerify != (err != nil) || (err != nil && !isIntegrityError(err)) {
+				t.Errorf("verify() error = %v, wantErr %v", err, tt.wantVerify)
+			}
+		})
+	}
+}
+
+func TestVerifyOVAChecksum(t *testing.T) {
+	p := writeTestOVA(t, map[string]string{"test.ovf": testOVF})
+	defer os.RemoveAll(filepath.Dir(p))
+
+	data, err := ioutil.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/test.ova" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data) //nolint: errcheck
+	}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &vim25.Client{Client: soap.NewClient(u, true)}
+
+	tests := []struct {
+		name      string
+		path      string
+		c         *checksum
+		wantErr   bool
+		integrity bool
+	}{
+		{"sha256", p, &checksum{algo: "sha256", sum: sha256Hex(string(data))}, false, false},
+		{"sha1", p, &checksum{algo: "sha1", sum: sha1Hex(string(data))}, false, false},
+		{"wrong digest", p, &checksum{algo: "sha256", sum: sha256Hex(testOVF)}, true, true},
+		{"remote", srv.URL + "/test.ova", &checksum{algo: "sha256", sum: sha256Hex(string(data))}, false, false},
+		{"remote wrong digest", srv.URL + "/test.ova", &checksum{algo: "sha256", sum: sha256Hex(testOVF)}, true, true},
+		{"remote not found", srv.URL + "/missing.ova", &checksum{algo: "sha256", sum: sha256Hex(testOVF)}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyOVAChecksum(context.Background(), client, tt.path, tt.c)
+			if tt.wantErr != (err != nil) || (err != nil && isIntegrityError(err) != tt.integrity) {
+				t.Errorf("verifyOVAChecksum() error = %v, wantErr %v, integrity %v", err, tt.wantErr, tt.integrity)
+			}
+		})
+	}
+}
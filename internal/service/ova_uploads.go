@@ -0,0 +1,256 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
+
+	"github.com/vterdunov/janna-api/internal/domain"
+	"github.com/vterdunov/janna-api/internal/types"
+	"github.com/vterdunov/janna-api/pkg/uuid"
+)
+
+// UploadScheme prefixes references to uploaded OVA files. POST /vms accepts them as 'ova_url'
+const UploadScheme = "upload://"
+
+const (
+	uploadExt           = ".ova"
+	uploadPartExt       = ".part"
+	uploadCleanInterval = time.Minute
+)
+
+var errUploadNotFound = errors.New("uploaded OVA not found")
+
+// stagedUpload is an OVA file in the staging area
+type stagedUpload struct {
+	upload domain.OVAUpload
+	path   string
+	// users is a number of running deploys and inspections that read the file
+	users int
+}
+
+// uploadStore keeps uploaded OVA files on a local disk until they expire
+type uploadStore struct {
+	mu      sync.Mutex
+	dir     string
+	maxSize int64
+	ttl     time.Duration
+	uploads map[string]*stagedUpload
+	logger  log.Logger
+}
+
+// newUploadStore creates the store and restores files uploaded before Janna restart
+func newUploadStore(dir string, maxSize int64, ttl time.Duration, logger log.Logger) *uploadStore {
+	s := &uploadStore{
+		dir:     dir,
+		maxSize: maxSize,
+		ttl:     ttl,
+		uploads: make(map[string]*stagedUpload),
+		logger:  logger,
+	}
+
+	s.restore()
+	go s.gc()
+
+	return s
+}
+
+func (s *uploadStore) restore() {
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			s.logger.Log("msg", "Could not read uploads directory", "err", err)
+		}
+		return
+	}
+
+	for _, f := range files {
+		p := filepath.Join(s.dir, f.Name())
+		switch filepath.Ext(f.Name()) {
+		case uploadPartExt:
+			// interrupted upload
+			os.Remove(p)
+		case uploadExt:
+			id := strings.TrimSuffix(f.Name(), uploadExt)
+			s.uploads[id] = &stagedUpload{
+				upload: domain.OVAUpload{
+					ID:        id,
+					Size:      f.Size(),
+					CreatedAt: f.ModTime(),
+					ExpiresAt: f.ModTime().Add(s.ttl),
+				},
+				path: p,
+			}
+		}
+	}
+}
+
+// save stores the body in the staging area. Bodies bigger than the size limit are rejected
+func (s *uploadStore) save(name string, body io.Reader) (*stagedUpload, error) {
+	if err := os.MkdirAll(s.dir, 0750); err != nil {
+		return nil, errors.Wrap(err, "could not create uploads directory")
+	}
+
+	id := uuid.NewUUID()
+	p := filepath.Join(s.dir, id+uploadExt)
+	part := p + uploadPartExt
+
+	f, err := os.OpenFile(part, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0640)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create upload file")
+	}
+
+	size, err := io.Copy(f, io.LimitReader(body, s.maxSize+1))
+	if cErr := f.Close(); err == nil {
+		err = cErr
+	}
+
+	if err == nil && size > s.maxSize {
+		err = fmt.Errorf("OVA is bigger than %d MB", s.maxSize>>20)
+	}
+
+	if err == nil && size == 0 {
+		err = errors.New("OVA is empty")
+	}
+
+	if err != nil {
+		os.Remove(part)
+		return nil, err
+	}
+
+	if err := os.Rename(part, p); err != nil {
+		os.Remove(part)
+		return nil, errors.Wrap(err, "could not save upload file")
+	}
+
+	now := time.Now()
+	u := &stagedUpload{
+		upload: domain.OVAUpload{
+			ID:        id,
+			Name:      name,
+			Size:      size,
+			CreatedAt: now,
+			ExpiresAt: now.Add(s.ttl),
+		},
+		path: p,
+	}
+
+	s.mu.Lock()
+	s.uploads[id] = u
+	s.mu.Unlock()
+
+	return u, nil
+}
+
+// acquire returns a path to the uploaded file and keeps the file until release is called
+func (s *uploadStore) acquire(id string) (string, func(), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.uploads[id]
+	if !ok {
+		return "", nil, errUploadNotFound
+	}
+	u.users++
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			s.mu.Lock()
+			u.users--
+			s.mu.Unlock()
+		})
+	}
+
+	return u.path, release, nil
+}
+
+// delete removes the uploaded file. Files in use can not be removed
+func (s *uploadStore) delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.uploads[id]
+	if !ok {
+		return errUploadNotFound
+	}
+
+	if u.users > 0 {
+		return errors.New("uploaded OVA is used by a running deploy")
+	}
+
+	delete(s.uploads, id)
+	return os.Remove(u.path)
+}
+
+// gc removes expired files that are not in use
+func (s *uploadStore) gc() {
+	ticker := time.NewTicker(uploadCleanInterval)
+
+	for now := range ticker.C {
+		s.removeExpired(now)
+	}
+}
+
+// removeExpired removes the files that have expired by now and are not in use
+func (s *uploadStore) removeExpired(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, u := range s.uploads {
+		if u.users > 0 || now.Before(u.upload.ExpiresAt) {
+			continue
+		}
+
+		delete(s.uploads, id)
+		if err := os.Remove(u.path); err != nil && !os.IsNotExist(err) {
+			s.logger.Log("msg", "Could not remove expired upload", "id", id, "err", err)
+		}
+	}
+}
+
+// resolveOVA turns a reference to an uploaded file into a local path.
+// Other URLs are returned as is. release must be called when the file is not needed anymore.
+func (s *service) resolveOVA(ovaURL string) (string, func(), error) {
+	if !strings.HasPrefix(ovaURL, UploadScheme) {
+		return ovaURL, func() {}, nil
+	}
+
+	id := strings.TrimPrefix(ovaURL, UploadScheme)
+	p, release, err := s.uploads.acquire(id)
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "could not use '%s'", ovaURL)
+	}
+
+	return p, release, nil
+}
+
+// OVAUpload stores the OVA in the staging area. The file is checked to be an OVA with an OVF descriptor
+func (s *service) OVAUpload(ctx context.Context, params *types.OVAUploadParams) (*domain.OVAUpload, error) {
+	u, err := s.uploads.save(params.Name, params.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not upload OVA")
+	}
+
+	if _, err := readOVA(s.Client, u.path); err != nil {
+		s.uploads.delete(u.upload.ID) //nolint: errcheck
+		return nil, errors.Wrap(err, "uploaded file is not a valid OVA")
+	}
+
+	res := u.upload
+	return &res, nil
+}
+
+// OVAUploadDelete removes the uploaded OVA from the staging area
+func (s *service) OVAUploadDelete(ctx context.Context, params *types.OVAUploadDeleteParams) error {
+	return s.uploads.delete(params.ID)
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+)
+
+func newTestUploadStore(t *testing.T, maxSize int64) (*uploadStore, func()) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "janna-uploads")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return newUploadStore(dir, maxSize, time.Hour, log.NewNopLogger()), func() { os.RemoveAll(dir) }
+}
+
+func TestUploadStore_SizeLimit(t *testing.T) {
+	s, cleanup := newTestUploadStore(t, 2<<20)
+	defer cleanup()
+
+	tests := []struct {
+		name    string
+		size    int
+		wantErr string
+	}{
+		{"at the limit", 2 << 20, ""},
+		{"over the limit", 2<<20 + 1, "OVA is bigger than 2 MB"},
+		{"empty", 0, "OVA is empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := s.save("test.ova", strings.NewReader(strings.Repeat("a", tt.size)))
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("uploadStore.save() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if u.upload.Size != int64(tt.size) || u.upload.Name != "test.ova" {
+				t.Errorf("uploadStore.save() = %+v, want %d bytes of 'test.ova'", u.upload, tt.size)
+			}
+		})
+	}
+
+	// rejected uploads do not leave files behind
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || filepath.Ext(files[0].Name()) != uploadExt {
+		t.Errorf("uploads directory has %d files, want the single saved OVA", len(files))
+	}
+}
+
+func TestUploadStore_Expiry(t *testing.T) {
+	s, cleanup := newTestUploadStore(t, 1<<20)
+	defer cleanup()
+
+	inUse, err := s.save("in-use.ova", strings.NewReader(testOVF))
+	if err != nil {
+		t.Fatal(err)
+	}
+	unused, err := s.save("unused.ova", strings.NewReader(testOVF))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, release, err := s.acquire(inUse.upload.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.removeExpired(time.Now())
+	if _, err := os.Stat(unused.path); err != nil {
+		t.Errorf("uploadStore.removeExpired() removed the file before it expired: %v", err)
+	}
+
+	s.removeExpired(time.Now().Add(2 * time.Hour))
+	if _, err := os.Stat(unused.path); !os.IsNotExist(err) {
+		t.Errorf("uploadStore.removeExpired() kept the expired file: %v", err)
+	}
+	if _, err := os.Stat(inUse.path); err != nil {
+		t.Errorf("uploadStore.removeExpired() removed the file in use: %v", err)
+	}
+
+	release()
+	s.removeExpired(time.Now().Add(2 * time.Hour))
+	if _, err := os.Stat(inUse.path); !os.IsNotExist(err) {
+		t.Errorf("uploadStore.removeExpired() kept the expired file after release: %v", err)
+	}
+}
+
+func TestUploadStore_NotFound(t *testing.T) {
+	s, cleanup := newTestUploadStore(t, 1<<20)
+	defer cleanup()
+
+	if _, _, err := s.acquire("unknown"); err != errUploadNotFound {
+		t.Errorf("uploadStore.acquire() error = %v, want %v", err, errUploadNotFound)
+	}
+	if err := s.delete("unknown"); err != errUploadNotFound {
+		t.Errorf("uploadStore.delete() error = %v, want %v", err, errUploadNotFound)
+	}
+
+	svc := &service{uploads: s}
+	if _, _, err := svc.resolveOVA(UploadScheme + "unknown"); err == nil || !strings.Contains(err.Error(), "could not use 'upload://unknown': uploaded OVA not found") {
+		t.Errorf("service.resolveOVA() error = %v, want not found", err)
+	}
+
+	u, err := s.save("test.ova", strings.NewReader(testOVF))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, release, err := svc.resolveOVA(UploadScheme + u.upload.ID)
+	if err != nil || p != u.path {
+		t.Fatalf("service.resolveOVA() = %v, %v, want %v", p, err, u.path)
+	}
+	if err := s.delete(u.upload.ID); err == nil {
+		t.Error("uploadStore.delete() removed the file in use")
+	}
+	release()
+	if err := s.delete(u.upload.ID); err != nil {
+		t.Errorf("uploadStore.delete() error = %v", err)
+	}
+}
+
+func TestUploadStore_Restore(t *testing.T) {
+	s, cleanup := newTestUploadStore(t, 1<<20)
+	defer cleanup()
+
+	u, err := s.save("test.ova", strings.NewReader(testOVF))
+	if err != nil {
+		t.Fatal(err)
+	}
+	part := filepath.Join(s.dir, "interrupted"+uploadExt+uploadPartExt)
+	if err := ioutil.WriteFile(part, []byte(testOVF), 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := newUploadStore(s.dir, s.maxSize, s.ttl, log.NewNopLogger())
+	if _, _, err := restored.acquire(u.upload.ID); err != nil {
+		t.Errorf("uploadStore.acquire() of the restored file error = %v", err)
+	}
+	if _, err := os.Stat(part); !os.IsNotExist(err) {
+		t.Errorf("newUploadStore() kept the interrupted upload: %v", err)
+	}
+}
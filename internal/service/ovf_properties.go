@@ -0,0 +1,88 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/vmware/govmomi/ovf"
+	vmware_types "github.com/vmware/govmomi/vim25/types"
+)
+
+// ovfPropertyMapping validates requested vApp properties against the properties
+// declared in the OVF ProductSections and returns them in the import spec format.
+// Keys are in OVF environment form: [class.]key[.instance]
+func ovfPropertyMapping(e *ovf.Envelope, props map[string]string) ([]vmware_types.KeyValue, error) {
+	if len(props) == 0 {
+		return nil, nil
+	}
+
+	declared := ovfProperties(e)
+
+	keys := make([]string, 0, len(props))
+	for k := range props {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	mapping := make([]vmware_types.KeyValue, 0, len(props))
+	for _, k := range keys {
+		p, ok := declared[k]
+		if !ok {
+			return nil, fmt.Errorf("OVF does not declare property '%s'. Declared properties: %s", k, declaredKeys(declared))
+		}
+
+		if p.UserConfigurable == nil || !*p.UserConfigurable {
+			return nil, fmt.Errorf("OVF property '%s' is not user configurable", k)
+		}
+
+		v := props[k]
+		// vSphere accepts only True/False as boolean values
+		if p.Type == "boolean" {
+			v = strings.Title(strings.ToLower(v))
+		}
+
+		mapping = append(mapping, vmware_types.KeyValue{Key: k, Value: v})
+	}
+
+	return mapping, nil
+}
+
+// ovfProperties returns the properties declared in the envelope by their full keys
+func ovfProperties(e *ovf.Envelope) map[string]ovf.Property {
+	props := make(map[string]ovf.Property)
+	if e == nil || e.VirtualSystem == nil {
+		return props
+	}
+
+	for _, section := range e.VirtualSystem.Product {
+		for _, p := range section.Property {
+			// OVF spec, section 9.5.1:
+			// key-value-env = [class-value "."] key-value-prod ["." instance-value]
+			k := p.Key
+			if section.Class != nil && *section.Class != "" {
+				k = *section.Class + "." + k
+			}
+			if section.Instance != nil && *section.Instance != "" {
+				k = k + "." + *section.Instance
+			}
+			props[k] = p
+		}
+	}
+
+	return props
+}
+
+func declaredKeys(props map[string]ovf.Property) string {
+	if len(props) == 0 {
+		return "none"
+	}
+
+	keys := make([]string, 0, len(props))
+	for k := range props {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return strings.Join(keys, ", ")
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vmware/govmomi/ovf"
+	vmware_types "github.com/vmware/govmomi/vim25/types"
+)
+
+const testPropertiesOVF = `<?xml version="1.0" encoding="UTF-8"?>
+<Envelope xmlns="http://schemas.dmtf.org/ovf/envelope/1" xmlns:ovf="http://schemas.dmtf.org/ovf/envelope/1">
+  <VirtualSystem ovf:id="vm">
+    <ProductSection>
+      <Property ovf:key="hostname" ovf:type="string" ovf:userConfigurable="true"/>
+      <Property ovf:key="version" ovf:type="string"/>
+    </ProductSection>
+    <ProductSection ovf:class="vami" ovf:instance="vm">
+      <Property ovf:key="ip0" ovf:type="string" ovf:userConfigurable="true"/>
+      <Property ovf:key="dhcp" ovf:type="boolean" ovf:userConfigurable="true"/>
+    </ProductSection>
+  </VirtualSystem>
+</Envelope>`
+
+func TestOVFPropertyMapping(t *testing.T) {
+	e, err := ovf.Unmarshal(strings.NewReader(testPropertiesOVF))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		props   map[string]string
+		want    []vmware_types.KeyValue
+		wantErr string
+	}{
+		{
+			name: "no properties",
+		},
+		{
+			name:  "full keys",
+			props: map[string]string{"hostname": "web-01", "vami.ip0.vm": "10.0.0.42", "vami.dhcp.vm": "TRUE"},
+			want: []vmware_types.KeyValue{
+				{Key: "hostname", Value: "web-01"},
+				{Key: "vami.dhcp.vm", Value: "True"},
+				{Key: "vami.ip0.vm", Value: "10.0.0.42"},
+			},
+		},
+		{
+			name:    "unknown key",
+			props:   map[string]string{"password": "secret"},
+			wantErr: "OVF does not declare property 'password'. Declared properties: hostname, vami.dhcp.vm, vami.ip0.vm, version",
+		},
+		{
+			name:    "key without class and instance",
+			props:   map[string]string{"ip0": "10.0.0.42"},
+			wantErr: "OVF does not declare property 'ip0'",
+		},
+		{
+			name:    "not user configurable",
+			props:   map[string]string{"version": "2"},
+			wantErr: "OVF property 'version' is not user configurable",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ovfPropertyMapping(e, tt.props)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ovfPropertyMapping() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ovfPropertyMapping() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOVFPropertyMapping_NoProperties(t *testing.T) {
+	_, err := ovfPropertyMapping(&ovf.Envelope{}, map[string]string{"hostname": "web-01"})
+	if err == nil || !strings.Contains(err.Error(), "Declared properties: none") {
+		t.Errorf("ovfPropertyMapping() error = %v, want 'Declared properties: none'", err)
+	}
+}
@@ -59,6 +59,24 @@ type Service interface {
 	// VMDeploy create VM from OVA file
 	VMDeploy(context.Context, *types.VMDeployParams) (string, error)
 
+	// VMDeployPlan resolves where VMDeploy would place the VM without creating anything
+	VMDeployPlan(context.Context, *types.VMDeployParams) (*domain.DeployPlan, error)
+
+	// OVAInspect describes the content of an OVA package without deploying it
+	OVAInspect(context.Context, *types.OVAInspectParams) (*domain.OVAInfo, error)
+
+	// OVAUpload stores an OVA in the staging area, so it can be deployed by reference
+	OVAUpload(context.Context, *types.OVAUploadParams) (*domain.OVAUpload, error)
+
+	// OVAUploadDelete removes an uploaded OVA from the staging area
+	OVAUploadDelete(context.Context, *types.OVAUploadDeleteParams) error
+
+	// LibrariesList returns vSphere Content Libraries
+	LibrariesList(context.Context) ([]domain.Library, error)
+
+	// LibraryItemsList returns items of a Content Library
+	LibraryItemsList(context.Context, *types.LibraryItemsListParams) ([]domain.LibraryItem, error)
+
 	// VMSnapshotsList returns VM snapshots list
 	VMSnapshotsList(context.Context, *types.VMSnapshotsListParams) ([]domain.Snapshot, error)
 
@@ -81,9 +99,22 @@ type Service interface {
 
 	RoleList(context.Context) ([]domain.Role, error)
 
-	// TasksList(context.Context) (*status.Tasks, error)
+	// TasksList returns a page of background tasks and the total number of matched tasks
+	TasksList(context.Context, *types.TasksListParams) ([]domain.Task, int, error)
+
+	TaskInfo(context.Context, string) (*domain.Task, error)
 
-	TaskInfo(context.Context, string) (map[string]interface{}, error)
+	// TaskCancel stops a running background task
+	TaskCancel(context.Context, *types.TaskCancelParams) error
+
+	// TaskEvents streams background task changes until the task is finished
+	TaskEvents(context.Context, string) (<-chan domain.Task, error)
+
+	// ResumeTasks finishes or cleans up deploy tasks interrupted by a restart
+	ResumeTasks(context.Context) error
+
+	// Shutdown stops accepting deploys and drains background tasks until the context is done
+	Shutdown(context.Context) error
 
 	// Reads Open API spec file
 	OpenAPI(context.Context) ([]byte, error)
@@ -98,6 +129,13 @@ type service struct {
 	cfg      *config.Config
 	Client   *vim25.Client
 	statuses Statuser
+	running  *runningTasks
+	webhooks *webhookSender
+	deploys  *deployQueue
+	uploads  *uploadStore
+	ovaCache *ovaCache
+	// stopped is closed when the shutdown is over, so open task event streams end
+	stopped chan struct{}
 }
 
 // New creates a new instance of the Service with wrapped middlewares
@@ -106,10 +144,11 @@ func New(
 	cfg *config.Config,
 	client *vim25.Client,
 	duration metrics.Histogram,
+	cacheRequests metrics.Counter,
 	statuses Statuser,
 ) Service {
 	// Build the layers of the service "onion" from the inside out.
-	svc := NewSimpleService(logger, cfg, client, statuses)
+	svc := NewSimpleService(logger, cfg, client, cacheRequests, statuses)
 	svc = NewLoggingService(log.With(logger, "component", "core"))(svc)
 	svc = NewInstrumentingService(duration)(svc)
 
@@ -121,6 +160,7 @@ func NewSimpleService(
 	logger log.Logger,
 	cfg *config.Config,
 	client *vim25.Client,
+	cacheRequests metrics.Counter,
 	statuses Statuser,
 ) Service {
 	return &service{
@@ -128,6 +168,12 @@ func NewSimpleService(
 		cfg:      cfg,
 		Client:   client,
 		statuses: statuses,
+		running:  newRunningTasks(),
+		webhooks: newWebhookSender(),
+		deploys:  newDeployQueue(cfg.Deploy.Workers, cfg.Deploy.QueueSize, cfg.Deploy.DatacenterLimits),
+		uploads:  newUploadStore(cfg.Uploads.Path, cfg.Uploads.MaxSize, cfg.Uploads.TTL, log.With(logger, "component", "uploads")),
+		ovaCache: newOVACache(cfg.OVACache.Path, cfg.OVACache.MaxSize, client.Client, cacheRequests, log.With(logger, "component", "ova_cache")),
+		stopped:  make(chan struct{}),
 	}
 }
 
@@ -232,6 +278,11 @@ func (s *service) VMDelete(ctx context.Context, params *types.VMDeleteParams) er
 		return err
 	}
 
+	return destroyVM(ctx, vm)
+}
+
+// destroyVM powers off Virtual Machine if needed and destroys it
+func destroyVM(ctx context.Context, vm *object.VirtualMachine) error {
 	state, psErr := vm.PowerState(ctx)
 	if psErr != nil {
 		return errors.Wrap(psErr, "could not get Virtual Machine power state")
@@ -243,7 +294,7 @@ func (s *service) VMDelete(ctx context.Context, params *types.VMDeleteParams) er
 			return errors.Wrap(pOffErr, "could not power off Virtual Machine before destroying")
 		}
 
-		if err = task.Wait(ctx); err != nil {
+		if err := task.Wait(ctx); err != nil {
 			return errors.Wrap(err, "could not power off Virtual Machine before destroying")
 		}
 	}
@@ -399,14 +450,20 @@ func (s *service) VMScreenshot(ctx context.Context, params *types.VMScreenshotPa
 	return screenshot, nil
 }
 
-func (s *service) TaskInfo(ctx context.Context, taskID string) (map[string]interface{}, error) {
+func (s *service) TaskInfo(ctx context.Context, taskID string) (*domain.Task, error) {
 	t := s.statuses.FindByID(taskID)
 	if t != nil {
-		return t.Get(), nil
+		task := t.Get()
+		return &task, nil
 	}
 	return nil, errors.New("task not found")
 }
 
+func (s *service) TasksList(ctx context.Context, params *types.TasksListParams) ([]domain.Task, int, error) {
+	tasks, total := s.statuses.FindAll(params)
+	return tasks, total, nil
+}
+
 func (s *service) OpenAPI(_ context.Context) ([]byte, error) {
 	spec, err := ioutil.ReadFile("./api/openapi.json")
 	if err != nil {
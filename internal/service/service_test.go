@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+
+	"github.com/vterdunov/janna-api/internal/domain"
+	"github.com/vterdunov/janna-api/internal/types"
+)
+
+// testTask is an in-memory TaskStatuser. The status package can not be used here, it imports service
+type testTask struct {
+	mu   sync.Mutex
+	task domain.Task
+}
+
+func newTestTask(id string) *testTask {
+	return &testTask{task: domain.Task{ID: id, Kind: domain.TaskKindVMDeploy}}
+}
+
+func (t *testTask) ID() string {
+	return t.task.ID
+}
+
+func (t *testTask) Update(fn func(t *domain.Task)) TaskStatuser {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	fn(&t.task)
+	return t
+}
+
+func (t *testTask) Get() domain.Task {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.task.Copy()
+}
+
+// testStatuses keeps a single task and lets a test publish its updates
+type testStatuses struct {
+	Statuser
+	task    *testTask
+	updates chan domain.Task
+}
+
+func (s *testStatuses) FindByID(id string) TaskStatuser {
+	if id != s.task.ID() {
+		return nil
+	}
+	return s.task
+}
+
+func (s *testStatuses) FindAll(params *types.TasksListParams) ([]domain.Task, int) {
+	return []domain.Task{s.task.Get()}, 1
+}
+
+func (s *testStatuses) Subscribe(id string) (<-chan domain.Task, func()) {
+	return s.updates, func() {}
+}
+
+// newTestVCenter starts a simulated vCenter with one datacenter 'DC0'. It has a standard network 'VM Network'
+// and a distributed switch 'DVS0' with port group 'DC0_DVPG0'
+func newTestVCenter(t *testing.T) (*vim25.Client, func()) {
+	t.Helper()
+
+	m := simulator.VPX()
+	if err := m.Create(); err != nil {
+		t.Fatal(err)
+	}
+	srv := m.Service.NewServer()
+
+	c, err := govmomi.NewClient(context.Background(), srv.URL, true)
+	if err != nil {
+		srv.Close()
+		m.Remove()
+		t.Fatal(err)
+	}
+
+	return c.Client, func() {
+		srv.Close()
+		m.Remove()
+	}
+}
+
+// newTestDeployment returns a deployment in the default datacenter of the simulated vCenter
+func newTestDeployment(t *testing.T, c *vim25.Client) *Deployment {
+	t.Helper()
+
+	d := &Deployment{
+		Client: c,
+		Finder: find.NewFinder(c, true),
+		logger: log.NewNopLogger(),
+	}
+	if err := d.chooseDatacenter(context.Background(), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	return d
+}
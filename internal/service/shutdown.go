@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// interruptTimeout is a time to wait for interrupted tasks to save their state
+const interruptTimeout = time.Minute
+
+// Shutdown stops accepting new deploys and waits for running tasks and their webhooks until the context is done.
+// Then it interrupts the rest of the tasks. Interrupted tasks keep their stage in the task store
+// and are resumed after restart. Task event streams are closed when Shutdown returns,
+// so the HTTP server does not wait for them.
+func (s *service) Shutdown(ctx context.Context) error {
+	l := s.logger
+	defer close(s.stopped)
+
+	// queued deploys would not have a chance to finish in time
+	for _, j := range s.deploys.close() {
+		s.running.interrupt(j.id)
+		j.drop()
+	}
+
+	l.Log("msg", "Waiting for running tasks")
+	if s.running.wait(ctx) {
+		l.Log("msg", "All tasks have finished")
+		if !s.webhooks.wait(ctx) {
+			return errors.New("some webhooks have not been delivered in time")
+		}
+		return nil
+	}
+
+	ids := s.running.ids()
+	l.Log("msg", "Grace period is over. Interrupting running tasks", "tasks", len(ids))
+	for _, id := range ids {
+		s.running.interrupt(id)
+	}
+
+	// deploys abort import leases and save their state
+	waitCtx, cancel := context.WithTimeout(context.Background(), interruptTimeout)
+	defer cancel()
+
+	if !s.running.wait(waitCtx) {
+		return errors.New("some tasks have not saved their state in time")
+	}
+
+	// tasks that have finished during the grace period may still deliver webhooks
+	if !s.webhooks.wait(waitCtx) {
+		return errors.New("some webhooks have not been delivered in time")
+	}
+
+	return nil
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/vterdunov/janna-api/internal/types"
+)
+
+// runningTask keeps the way to stop a background task from outside of its goroutine
+type runningTask struct {
+	cancel context.CancelFunc
+
+	mu          sync.Mutex
+	cancelled   bool
+	destroyVM   bool
+	interrupted bool
+}
+
+// Cancelled reports whether the task was cancelled by a user and whether the VM should be destroyed
+func (r *runningTask) Cancelled() (cancelled, destroyVM bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.cancelled, r.destroyVM
+}
+
+// Interrupted reports whether the task was stopped by Janna shutdown
+func (r *runningTask) Interrupted() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.interrupted
+}
+
+// runningTasks is a registry of the background tasks that are running in this process
+type runningTasks struct {
+	sync.Mutex
+	tasks map[string]*runningTask
+	// drained is closed when the last task is removed
+	drained chan struct{}
+}
+
+func newRunningTasks() *runningTasks {
+	return &runningTasks{
+		tasks: make(map[string]*runningTask),
+	}
+}
+
+func (r *runningTasks) add(id string, cancel context.CancelFunc) *runningTask {
+	rt := &runningTask{cancel: cancel}
+
+	r.Lock()
+	r.tasks[id] = rt
+	r.Unlock()
+
+	return rt
+}
+
+func (r *runningTasks) has(id string) bool {
+	r.Lock()
+	defer r.Unlock()
+
+	_, ok := r.tasks[id]
+	return ok
+}
+
+func (r *runningTasks) remove(id string) {
+	r.Lock()
+	delete(r.tasks, id)
+	if len(r.tasks) == 0 && r.drained != nil {
+		close(r.drained)
+		r.drained = nil
+	}
+	r.Unlock()
+}
+
+// wait blocks until all tasks are removed or the context is done.
+// It reports whether all tasks were removed.
+func (r *runningTasks) wait(ctx context.Context) bool {
+	r.Lock()
+	if len(r.tasks) == 0 {
+		r.Unlock()
+		return true
+	}
+	if r.drained == nil {
+		r.drained = make(chan struct{})
+	}
+	drained := r.drained
+	r.Unlock()
+
+	select {
+	case <-drained:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// interrupt stops the task because of Janna shutdown
+func (r *runningTasks) interrupt(id string) {
+	r.Lock()
+	rt, ok := r.tasks[id]
+	r.Unlock()
+
+	if !ok {
+		return
+	}
+
+	rt.mu.Lock()
+	rt.interrupted = true
+	rt.mu.Unlock()
+
+	rt.cancel()
+}
+
+func (r *runningTasks) ids() []string {
+	r.Lock()
+	defer r.Unlock()
+
+	ids := make([]string, 0, len(r.tasks))
+	for id := range r.tasks {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (r *runningTasks) cancel(id string, destroyVM bool) bool {
+	r.Lock()
+	rt, ok := r.tasks[id]
+	r.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	rt.mu.Lock()
+	rt.cancelled = true
+	rt.destroyVM = destroyVM
+	rt.mu.Unlock()
+
+	rt.cancel()
+	return true
+}
+
+func (s *service) TaskCancel(ctx context.Context, params *types.TaskCancelParams) error {
+	if s.statuses.FindByID(params.TaskID) == nil {
+		return errors.New("task not found")
+	}
+
+	if !s.running.cancel(params.TaskID, params.DestroyVM) {
+		return errors.New("task is not running")
+	}
+
+	// drop the task if it is still waiting for a worker
+	s.deploys.remove(params.TaskID)
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/vterdunov/janna-api/internal/types"
+)
+
+func TestService_TaskCancel(t *testing.T) {
+	q := newDeployQueue(1, 0, nil)
+	s := &service{running: newRunningTasks(), deploys: q}
+
+	running := newBlockingJob("running", "dc1")
+	runningCtx, cancelRunning := context.WithCancel(context.Background())
+	defer cancelRunning()
+	rtRunning := s.running.add(running.id, cancelRunning)
+	if err := q.push(running.deployJob); err != nil {
+		t.Fatal(err)
+	}
+	waitClosed(t, running.started, "start of the running job")
+
+	// the only worker is busy, so the job waits in the queue
+	queued := newBlockingJob("queued", "dc1")
+	queuedCtx, cancelQueued := context.WithCancel(context.Background())
+	defer cancelQueued()
+	rtQueued := s.running.add(queued.id, cancelQueued)
+	if err := q.push(queued.deployJob); err != nil {
+		t.Fatal(err)
+	}
+
+	// queued task
+	s.statuses = &testStatuses{task: queued.task.(*testTask)}
+	if err := s.TaskCancel(context.Background(), &types.TaskCancelParams{TaskID: "queued"}); err != nil {
+		t.Fatalf("service.TaskCancel() error = %v", err)
+	}
+	waitClosed(t, queued.dropped, "drop of the cancelled queued job")
+	if queuedCtx.Err() == nil {
+		t.Error("service.TaskCancel() did not cancel the queued task context")
+	}
+	if cancelled, destroyVM := rtQueued.Cancelled(); !cancelled || destroyVM {
+		t.Errorf("queued task Cancelled() = %v, %v, want true, false", cancelled, destroyVM)
+	}
+
+	// running task
+	s.statuses = &testStatuses{task: running.task.(*testTask)}
+	if err := s.TaskCancel(context.Background(), &types.TaskCancelParams{TaskID: "running", DestroyVM: true}); err != nil {
+		t.Fatalf("service.TaskCancel() error = %v", err)
+	}
+	if runningCtx.Err() == nil {
+		t.Error("service.TaskCancel() did not cancel the running task context")
+	}
+	if cancelled, destroyVM := rtRunning.Cancelled(); !cancelled || !destroyVM {
+		t.Errorf("running task Cancelled() = %v, %v, want true, true", cancelled, destroyVM)
+	}
+	if closesSoon(queued.started) {
+		t.Error("cancelled queued job has started")
+	}
+	close(running.release)
+	s.running.remove(running.id)
+	s.running.remove(queued.id)
+
+	// finished task
+	if err := s.TaskCancel(context.Background(), &types.TaskCancelParams{TaskID: "running"}); err == nil || err.Error() != "task is not running" {
+		t.Errorf("service.TaskCancel() error = %v, want 'task is not running'", err)
+	}
+
+	// unknown task
+	if err := s.TaskCancel(context.Background(), &types.TaskCancelParams{TaskID: "unknown"}); err == nil || err.Error() != "task not found" {
+		t.Errorf("service.TaskCancel() error = %v, want 'task not found'", err)
+	}
+}
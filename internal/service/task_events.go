@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/vterdunov/janna-api/internal/domain"
+)
+
+// TaskEvents returns a channel that receives the current task record and then the record after every change.
+// The channel is closed when the task reaches a final stage, the context is done or Janna has shut down.
+// Interrupted tasks never reach a final stage, so their streams are closed on shutdown.
+func (s *service) TaskEvents(ctx context.Context, taskID string) (<-chan domain.Task, error) {
+	t := s.statuses.FindByID(taskID)
+	if t == nil {
+		return nil, errors.New("task not found")
+	}
+
+	// subscribe before reading the current record to not miss an update in between
+	updates, unsubscribe := s.statuses.Subscribe(taskID)
+
+	events := make(chan domain.Task)
+	go func() {
+		defer close(events)
+		defer unsubscribe()
+
+		send := func(task domain.Task) bool {
+			select {
+			case events <- task:
+				return !task.Stage.IsFinal()
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		if !send(t.Get()) {
+			return
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.stopped:
+				// send the updates that are already published and close the stream
+				for {
+					select {
+					case task, ok := <-updates:
+						if !ok || !send(task) {
+							return
+						}
+					default:
+						return
+					}
+				}
+			case task, ok := <-updates:
+				if !ok || !send(task) {
+					return
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
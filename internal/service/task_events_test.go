@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vterdunov/janna-api/internal/domain"
+)
+
+func TestService_TaskEventsShutdown(t *testing.T) {
+	task := newTestTask("42")
+	task.Update(func(task *domain.Task) {
+		task.SetStage(domain.TaskStageImport)
+	})
+
+	statuses := &testStatuses{task: task, updates: make(chan domain.Task, 1)}
+	s := &service{statuses: statuses, stopped: make(chan struct{})}
+
+	events, err := s.TaskEvents(context.Background(), "42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := <-events; got.Stage != domain.TaskStageImport {
+		t.Errorf("TaskEvents() first event stage = %v, want %v", got.Stage, domain.TaskStageImport)
+	}
+
+	// an interrupted task saves its state and never reaches a final stage
+	task.Update(func(task *domain.Task) {
+		task.Interrupted = true
+	})
+	statuses.updates <- task.Get()
+	close(s.stopped)
+
+	timeout := time.After(time.Second)
+	var got []domain.Task
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				if len(got) != 1 || !got[0].Interrupted {
+					t.Errorf("TaskEvents() sent %+v after shutdown, want the interrupted task", got)
+				}
+				return
+			}
+			got = append(got, e)
+		case <-timeout:
+			t.Fatal("TaskEvents() stream is open after shutdown")
+		}
+	}
+}
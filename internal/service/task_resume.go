@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
+
+	"github.com/vterdunov/janna-api/internal/domain"
+	"github.com/vterdunov/janna-api/internal/types"
+)
+
+// ResumeTasks finds deploy tasks that were left unfinished by a previous Janna process
+// and reconciles them with vSphere. Deploys that have passed the import are finished,
+// the rest are marked failed and the cleanup policy of the task is applied.
+// Callbacks are not delivered for resumed tasks, because callback secrets are not persisted.
+// Guest OS customization is not applied again, because the request parameters are not persisted.
+func (s *service) ResumeTasks(ctx context.Context) error {
+	tasks, _ := s.statuses.FindAll(&types.TasksListParams{})
+
+	for _, task := range tasks {
+		if task.Kind != domain.TaskKindVMDeploy || task.Stage.IsFinal() {
+			continue
+		}
+
+		if s.running.has(task.ID) {
+			continue
+		}
+
+		t := s.statuses.FindByID(task.ID)
+		if t == nil {
+			continue
+		}
+
+		l := log.With(s.logger, "request_id", task.RequestID, "vm", task.VMName, "task_id", task.ID)
+		l.Log("msg", "Resuming interrupted deploy", "stage", task.Stage)
+
+		taskCtx, cancel := context.WithTimeout(context.Background(), s.cfg.TaskTTL)
+		rt := s.running.add(task.ID, cancel)
+
+		go func(task domain.Task) {
+			defer cancel()
+			defer s.running.remove(task.ID)
+
+			s.resumeDeploy(taskCtx, t, rt, &task, l)
+		}(task)
+	}
+
+	return nil
+}
+
+func (s *service) resumeDeploy(ctx context.Context, t TaskStatuser, rt *runningTask, task *domain.Task, l log.Logger) {
+	t.Update(func(task *domain.Task) {
+		task.Interrupted = false
+		task.QueuePosition = 0
+		task.Message = "Resuming after Janna restart"
+	})
+
+	var vm *object.VirtualMachine
+	if task.Result.VMUUID != "" {
+		var err error
+		vm, err = s.findVMByUUID(ctx, task.Result.VMUUID)
+		if err != nil {
+			err = errors.Wrap(err, "Could not reconcile interrupted deploy")
+			l.Log("err", err)
+			s.failDeploy(t, rt, nil, err, l)
+			return
+		}
+	}
+
+	switch task.Stage {
+	case domain.TaskStageCreate:
+		if vm == nil {
+			err := errors.New("Deploy was interrupted by Janna restart. Virtual Machine does not exist anymore") //nolint: stylecheck,golint
+			l.Log("err", err)
+			s.failDeploy(t, rt, nil, err, l)
+			return
+		}
+
+		s.finishDeploy(ctx, t, rt, vm, l)
+
+	case domain.TaskStageImport:
+		// the cleanup policy decides what to do with the partially imported Virtual Machine.
+		// vSphere removes it by itself when the import lease expires
+		err := errors.New("Deploy was interrupted by Janna restart during the import") //nolint: stylecheck,golint
+		l.Log("err", err)
+		s.failDeploy(t, rt, vm, err, l)
+
+	case domain.TaskStageClone:
+		// the clone task may be still running in vSphere
+		err := fmt.Errorf("Deploy was interrupted by Janna restart during the clone. Check whether Virtual Machine '%s' was created", task.VMName) //nolint: stylecheck,golint
+		l.Log("err", err)
+		s.failDeploy(t, rt, nil, err, l)
+
+	case domain.TaskStageLibrary:
+		// vCenter continues the deploy without Janna
+		err := fmt.Errorf("Deploy was interrupted by Janna restart during the Content Library deploy. Check whether Virtual Machine '%s' was created", task.VMName) //nolint: stylecheck,golint
+		l.Log("err", err)
+		s.failDeploy(t, rt, nil, err, l)
+
+	case domain.TaskStageReconfigure:
+		// the hardware may be partially changed, so the cleanup policy decides what to do with the Virtual Machine
+		err := errors.New("Deploy was interrupted by Janna restart during the hardware reconfiguration") //nolint: stylecheck,golint
+		l.Log("err", err)
+		s.failDeploy(t, rt, vm, err, l)
+
+	default:
+		err := fmt.Errorf("Deploy was interrupted by Janna restart on '%s' stage", task.Stage) //nolint: stylecheck,golint
+		l.Log("err", err)
+		s.failDeploy(t, rt, nil, err, l)
+	}
+}
+
+// findVMByUUID searches the Virtual Machine in all datacenters. It returns nil if the VM does not exist
+func (s *service) findVMByUUID(ctx context.Context, uuid string) (*object.VirtualMachine, error) {
+	ref, err := object.NewSearchIndex(s.Client).FindByUuid(ctx, nil, uuid, true, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if ref == nil {
+		return nil, nil
+	}
+
+	vm, ok := ref.(*object.VirtualMachine)
+	if !ok {
+		return nil, errors.New("could not assert reference to Virtual Machine")
+	}
+
+	return vm, nil
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	vmware_types "github.com/vmware/govmomi/vim25/types"
+
+	"github.com/vterdunov/janna-api/internal/config"
+	"github.com/vterdunov/janna-api/internal/domain"
+)
+
+// newTestResumeService returns a service that keeps the single interrupted deploy task
+func newTestResumeService(c *vim25.Client, task *testTask) *service {
+	return &service{
+		Client:   c,
+		logger:   log.NewNopLogger(),
+		cfg:      &config.Config{TaskTTL: 5 * time.Second},
+		statuses: &testStatuses{task: task},
+		running:  newRunningTasks(),
+	}
+}
+
+// powerOffWithIP powers off the simulated Virtual Machine and sets the IP address its guest reports.
+// The simulator does not fill the guest IP configuration that vm.WaitForNetIP waits for
+func powerOffWithIP(t *testing.T, vm *object.VirtualMachine, ip string) {
+	t.Helper()
+
+	if err := powerOffVM(context.Background(), vm); err != nil {
+		t.Fatal(err)
+	}
+
+	simVM := simulator.Map.Get(vm.Reference()).(*simulator.VirtualMachine)
+	nics := append([]vmware_types.GuestNicInfo(nil), simVM.Guest.Net...)
+	if len(nics) == 0 {
+		t.Fatal("simulated Virtual Machine does not have NICs")
+	}
+	nics[0].IpAddress = []string{ip}
+	nics[0].IpConfig = &vmware_types.NetIpConfigInfo{
+		IpAddress: []vmware_types.NetIpConfigInfoIpAddress{{IpAddress: ip, PrefixLength: 24}},
+	}
+
+	simulator.Map.Update(simVM, []vmware_types.PropertyChange{{Name: "guest.net", Val: nics}})
+}
+
+func TestService_ResumeTasks(t *testing.T) {
+	tests := []struct {
+		name   string
+		stage  domain.TaskStage
+		policy domain.CleanupPolicy
+		// missingVM remembers the UUID of a Virtual Machine that does not exist
+		missingVM  bool
+		wantStage  domain.TaskStage
+		wantError  string
+		wantAction domain.CleanupAction
+		// wantState is the power state of the Virtual Machine after the resume. Empty if it is destroyed
+		wantState vmware_types.VirtualMachinePowerState
+	}{
+		{
+			name:      "create is finished",
+			stage:     domain.TaskStageCreate,
+			wantStage: domain.TaskStageComplete,
+			wantState: vmware_types.VirtualMachinePowerStatePoweredOn,
+		},
+		{
+			name:       "import is destroyed by policy",
+			stage:      domain.TaskStageImport,
+			policy:     domain.CleanupPolicyDestroy,
+			wantStage:  domain.TaskStageError,
+			wantError:  "Deploy was interrupted by Janna restart during the import",
+			wantAction: domain.CleanupActionDestroyed,
+		},
+		{
+			name:       "import is kept by default",
+			stage:      domain.TaskStageImport,
+			wantStage:  domain.TaskStageError,
+			wantError:  "Deploy was interrupted by Janna restart during the import",
+			wantAction: domain.CleanupActionKept,
+			wantState:  vmware_types.VirtualMachinePowerStatePoweredOff,
+		},
+		{
+			name:       "virtual machine not found",
+			stage:      domain.TaskStageCreate,
+			policy:     domain.CleanupPolicyDestroy,
+			missingVM:  true,
+			wantStage:  domain.TaskStageError,
+			wantError:  "Virtual Machine does not exist anymore",
+			wantAction: domain.CleanupActionNone,
+			wantState:  vmware_types.VirtualMachinePowerStatePoweredOff,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, cleanup := newTestVCenter(t)
+			defer cleanup()
+
+			ctx := context.Background()
+			vm := testVM(t, c)
+			powerOffWithIP(t, vm, "10.0.0.42")
+			uuid := vm.UUID(ctx)
+
+			task := newTestTask("task")
+			task.Update(func(task *domain.Task) {
+				task.Kind = domain.TaskKindVMDeploy
+				task.SetStage(tt.stage)
+				task.Interrupted = true
+				task.Result.VMUUID = uuid
+				if tt.missingVM {
+					task.Result.VMUUID = "00000000-0000-0000-0000-000000000000"
+				}
+				if tt.policy != "" {
+					task.Cleanup = &domain.TaskCleanup{Policy: tt.policy}
+				}
+			})
+
+			s := newTestResumeService(c, task)
+			if err := s.ResumeTasks(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			waitCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+			defer cancel()
+			if !s.running.wait(waitCtx) {
+				t.Fatal("resumed task has not finished in time")
+			}
+
+			got := task.Get()
+			if got.Stage != tt.wantStage || got.Interrupted {
+				t.Errorf("service.ResumeTasks() stage = %v, interrupted %v, want %v", got.Stage, got.Interrupted, tt.wantStage)
+			}
+			if tt.wantError != "" && (got.Error == nil || !strings.Contains(got.Error.Message, tt.wantError)) {
+				t.Errorf("service.ResumeTasks() error = %+v, want %q", got.Error, tt.wantError)
+			}
+			if tt.wantStage == domain.TaskStageComplete && (len(got.Result.IPs) != 1 || got.Result.IPs[0] != "10.0.0.42") {
+				t.Errorf("service.ResumeTasks() IPs = %v, want [10.0.0.42]", got.Result.IPs)
+			}
+			if tt.wantAction != "" && (got.Cleanup == nil || got.Cleanup.Action != tt.wantAction) {
+				t.Errorf("service.ResumeTasks() cleanup = %+v, want action %v", got.Cleanup, tt.wantAction)
+			}
+
+			found, err := s.findVMByUUID(ctx, uuid)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantState == "" {
+				if found != nil {
+					t.Error("service.ResumeTasks() did not destroy the Virtual Machine")
+				}
+				return
+			}
+			if found == nil {
+				t.Fatal("service.ResumeTasks() destroyed the Virtual Machine")
+			}
+			if state, err := found.PowerState(ctx); err != nil || state != tt.wantState {
+				t.Errorf("Virtual Machine power state = %v, %v, want %v", state, err, tt.wantState)
+			}
+		})
+	}
+}
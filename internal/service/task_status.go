@@ -1,16 +1,29 @@
 package service
 
+import (
+	"github.com/vterdunov/janna-api/internal/domain"
+	"github.com/vterdunov/janna-api/internal/types"
+)
+
 // Statuser represents behavior of storage that keeps statuses
 //nolint: misspell
 type Statuser interface {
-	NewTask() TaskStatuser
+	NewTask(kind domain.TaskKind) TaskStatuser
 	FindByID(id string) TaskStatuser
+	// FindAll returns a page of tasks that match the params, newest first,
+	// and the total number of matched tasks
+	FindAll(params *types.TasksListParams) ([]domain.Task, int)
+	// Subscribe returns a channel that receives a copy of the task record after every update
+	// and a function that cancels the subscription. Slow subscribers may miss intermediate updates,
+	// but they always receive the latest one.
+	Subscribe(id string) (<-chan domain.Task, func())
 }
 
 // TaskStatuser represents behavior of every single task
 type TaskStatuser interface {
 	ID() string
-	Str(keyvals ...string) TaskStatuser
-	StrArr(key string, arr []string) TaskStatuser
-	Get() (statuses map[string]interface{})
+	// Update changes the task record. The changes are saved when fn returns
+	Update(fn func(t *domain.Task)) TaskStatuser
+	// Get returns a copy of the task record
+	Get() domain.Task
 }
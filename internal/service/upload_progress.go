@@ -0,0 +1,112 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/vmware/govmomi/nfc"
+
+	"github.com/vterdunov/janna-api/internal/domain"
+)
+
+// uploadTracker aggregates disks upload progress and reports it to the task status.
+// A nil tracker is valid and reports nothing.
+type uploadTracker struct {
+	mu      sync.Mutex
+	task    TaskStatuser
+	started time.Time
+	files   []domain.FileProgress
+}
+
+func newUploadTracker(task TaskStatuser, items []nfc.FileItem) *uploadTracker {
+	if task == nil {
+		return nil
+	}
+
+	files := make([]domain.FileProgress, 0, len(items))
+	for _, item := range items {
+		f := domain.FileProgress{
+			Path:       item.Path,
+			TotalBytes: item.Size,
+		}
+		files = append(files, f)
+	}
+
+	u := &uploadTracker{
+		task:    task,
+		started: time.Now(),
+		files:   files,
+	}
+
+	u.mu.Lock()
+	u.report()
+	u.mu.Unlock()
+
+	return u
+}
+
+// SetSize sets the real file size. OVF descriptor may not declare it,
+// so it is known only when the file was opened.
+func (u *uploadTracker) SetSize(path string, size int64) {
+	if u == nil {
+		return
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	for i := range u.files {
+		if u.files[i].Path == path {
+			u.files[i].TotalBytes = size
+			u.files[i].TransferredBytes = int64(float64(size) * float64(u.files[i].Percentage) / 100)
+		}
+	}
+
+	u.report()
+}
+
+// Update sets the file upload percentage
+func (u *uploadTracker) Update(path string, percentage float32) {
+	if u == nil {
+		return
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	for i := range u.files {
+		if u.files[i].Path == path {
+			u.files[i].Percentage = percentage
+			u.files[i].TransferredBytes = int64(float64(u.files[i].TotalBytes) * float64(percentage) / 100)
+		}
+	}
+
+	u.report()
+}
+
+// report must be called with the lock held
+func (u *uploadTracker) report() {
+	p := domain.UploadProgress{
+		Files: append([]domain.FileProgress{}, u.files...),
+	}
+
+	for _, f := range u.files {
+		p.TotalBytes += f.TotalBytes
+		p.TransferredBytes += f.TransferredBytes
+	}
+
+	if p.TotalBytes > 0 {
+		p.Percentage = float32(float64(p.TransferredBytes) / float64(p.TotalBytes) * 100)
+	}
+
+	if p.TransferredBytes > 0 {
+		elapsed := time.Since(u.started)
+		left := p.TotalBytes - p.TransferredBytes
+		p.ETA = time.Duration(float64(elapsed) * float64(left) / float64(p.TransferredBytes)).Round(time.Second)
+	}
+
+	u.task.Update(func(t *domain.Task) {
+		t.Upload = &p
+		t.Progress = int(p.Percentage)
+	})
+}
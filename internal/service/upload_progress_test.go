@@ -0,0 +1,56 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/vmware/govmomi/nfc"
+)
+
+func TestUploadTracker(t *testing.T) {
+	task := newTestTask("42")
+	u := newUploadTracker(task, []nfc.FileItem{
+		{Path: "disk1.vmdk", Size: 1000},
+		{Path: "disk2.vmdk", Size: 3000},
+		// the OVF descriptor does not declare the size of the last disk
+		{Path: "disk3.vmdk"},
+	})
+
+	if got := task.Get().Upload; got == nil || got.TotalBytes != 4000 || got.TransferredBytes != 0 {
+		t.Fatalf("newUploadTracker() progress = %+v, want 4000 total bytes", got)
+	}
+
+	u.Update("disk1.vmdk", 100)
+	u.Update("disk2.vmdk", 50)
+	u.SetSize("disk3.vmdk", 4000)
+	u.Update("disk3.vmdk", 25)
+
+	got := task.Get()
+	p := got.Upload
+	if p.TotalBytes != 8000 || p.TransferredBytes != 3500 {
+		t.Errorf("uploadTracker bytes = %d of %d, want 3500 of 8000", p.TransferredBytes, p.TotalBytes)
+	}
+	if p.Percentage != 43.75 || got.Progress != 43 {
+		t.Errorf("uploadTracker percentage = %v, task progress %d, want 43.75, 43", p.Percentage, got.Progress)
+	}
+
+	want := []int64{1000, 1500, 1000}
+	if len(p.Files) != len(want) {
+		t.Fatalf("uploadTracker reported %d files, want %d", len(p.Files), len(want))
+	}
+	for i, f := range p.Files {
+		if f.TransferredBytes != want[i] {
+			t.Errorf("uploadTracker file %s transferred = %d, want %d", f.Path, f.TransferredBytes, want[i])
+		}
+	}
+}
+
+func TestUploadTracker_Nil(t *testing.T) {
+	u := newUploadTracker(nil, []nfc.FileItem{{Path: "disk1.vmdk", Size: 1000}})
+	if u != nil {
+		t.Fatalf("newUploadTracker() = %v, want nil without a task", u)
+	}
+
+	// a nil tracker reports nothing
+	u.SetSize("disk1.vmdk", 1000)
+	u.Update("disk1.vmdk", 50)
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/progress"
+	vmware_types "github.com/vmware/govmomi/vim25/types"
+
+	"github.com/vterdunov/janna-api/internal/domain"
+)
+
+// Clone creates the Virtual Machine from a template or another Virtual Machine.
+// The source is found by UUID or by inventory path.
+func (o *Deployment) Clone(ctx context.Context, uuid, path, anno string) (*vmware_types.ManagedObjectReference, error) {
+	src, err := o.findCloneSource(ctx, uuid, path)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := o.ResourcePool.Reference()
+	ds := o.Datastore.Reference()
+	spec := vmware_types.VirtualMachineCloneSpec{
+		Location: vmware_types.VirtualMachineRelocateSpec{
+			Pool:      &pool,
+			Datastore: &ds,
+		},
+		PowerOn:  false,
+		Template: false,
+	}
+
+	if o.Host != nil {
+		host := o.Host.Reference()
+		spec.Location.Host = &host
+	}
+
+	if anno != "" {
+		spec.Config = &vmware_types.VirtualMachineConfigSpec{
+			Annotation: anno,
+		}
+	}
+
+	o.logger.Log("msg", "Clone Virtual Machine", "source_uuid", uuid, "source_path", path)
+	task, err := src.Clone(ctx, o.Folder, o.Name, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := task.WaitForResult(ctx, cloneProgress{task: o.task})
+	if err != nil {
+		if ctx.Err() != nil {
+			o.cancelTask(task)
+		}
+		return nil, err
+	}
+
+	moref, ok := info.Result.(vmware_types.ManagedObjectReference)
+	if !ok {
+		return nil, errors.New("could not get cloned Virtual Machine reference")
+	}
+
+	return &moref, nil
+}
+
+func (o *Deployment) findCloneSource(ctx context.Context, uuid, path string) (*object.VirtualMachine, error) {
+	if uuid == "" {
+		vm, err := o.Finder.VirtualMachine(ctx, path)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not find clone source by path")
+		}
+		return vm, nil
+	}
+
+	ref, err := object.NewSearchIndex(o.Client).FindByUuid(ctx, o.Datacenter, uuid, true, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not find clone source by UUID")
+	}
+
+	if ref == nil {
+		return nil, errors.Errorf("could not find clone source with UUID '%s'", uuid)
+	}
+
+	vm, ok := ref.(*object.VirtualMachine)
+	if !ok {
+		return nil, errors.New("could not find clone source by UUID. Could not assert reference to Virtual Machine")
+	}
+
+	return vm, nil
+}
+
+// cancelTask cancels the vSphere task. The deploy context may be already cancelled, so a separate one is used.
+func (o *Deployment) cancelTask(task *object.Task) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	o.logger.Log("msg", "Cancel vSphere task")
+	if err := task.Cancel(ctx); err != nil {
+		o.logger.Log("err", errors.Wrap(err, "Could not cancel vSphere task"))
+	}
+}
+
+// cloneProgress reports vSphere clone task progress to the task status
+type cloneProgress struct {
+	task TaskStatuser
+}
+
+func (p cloneProgress) Sink() chan<- progress.Report {
+	ch := make(chan progress.Report)
+
+	go func() {
+		for r := range ch {
+			if p.task == nil {
+				continue
+			}
+
+			percentage := int(r.Percentage())
+			p.task.Update(func(t *domain.Task) {
+				t.Progress = percentage
+			})
+		}
+	}()
+
+	return ch
+}
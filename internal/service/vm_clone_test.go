@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/mo"
+	vmware_types "github.com/vmware/govmomi/vim25/types"
+
+	"github.com/vterdunov/janna-api/internal/types"
+)
+
+func TestDeployment_Clone(t *testing.T) {
+	tests := []struct {
+		name string
+		// byUUID finds the source by its UUID instead of the path
+		byUUID  bool
+		path    string
+		uuid    string
+		wantErr string
+	}{
+		{name: "template by path", path: "/DC0/vm/DC0_H0_VM0"},
+		{name: "template by UUID", byUUID: true},
+		{name: "missing path", path: "/DC0/vm/missing", wantErr: "could not find clone source by path"},
+		{name: "missing UUID", uuid: "00000000-0000-0000-0000-000000000000", wantErr: "could not find clone source with UUID '00000000-0000-0000-0000-000000000000'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, cleanup := newTestVCenter(t)
+			defer cleanup()
+
+			// the simulated datastores are not accessible
+			for _, ds := range simulator.Map.All("Datastore") {
+				simulator.Map.Update(ds, []vmware_types.PropertyChange{{Name: "summary.accessible", Val: true}})
+			}
+
+			ctx := context.Background()
+			src := testVM(t, c)
+			if err := powerOffVM(ctx, src); err != nil {
+				t.Fatal(err)
+			}
+			if err := src.MarkAsTemplate(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			uuid := tt.uuid
+			if tt.byUUID {
+				uuid = src.UUID(ctx)
+			}
+
+			params := &types.VMDeployParams{Name: "clone"}
+			params.ComputerResources.Type = "host"
+			params.ComputerResources.Path = "/DC0/host/DC0_H0/DC0_H0"
+			params.Datastores.Type = "datastore"
+			params.Clone.Path = "/DC0/vm/DC0_H0_VM0"
+
+			d, err := newDeployment(ctx, c, params, 0, log.NewNopLogger())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ref, err := d.Clone(ctx, uuid, tt.path, "cloned by test")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Deployment.Clone() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Deployment.Clone() error = %v", err)
+			}
+
+			vm := object.NewVirtualMachine(c, *ref)
+			var o mo.VirtualMachine
+			if err := vm.Properties(ctx, vm.Reference(), []string{"name", "config"}, &o); err != nil {
+				t.Fatal(err)
+			}
+			// the simulator does not apply the annotation of the clone spec
+			if o.Name != "clone" || o.Config.Template {
+				t.Errorf("Deployment.Clone() Virtual Machine = %s, template %v, want clone, false", o.Name, o.Config.Template)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+	vmware_types "github.com/vmware/govmomi/vim25/types"
+
+	"github.com/vterdunov/janna-api/internal/types"
+)
+
+// defaultCustomizationDomain is used when a hostname is customized without a domain.
+// vSphere requires a domain for Linux guests
+const defaultCustomizationDomain = "localdomain"
+
+// linuxGuestPrefixes are the prefixes of vSphere guest OS identifiers of Linux distributions.
+// Generic identifiers, e.g. 'otherLinux64Guest', contain 'Linux'
+var linuxGuestPrefixes = []string{
+	"asianux", "centos", "coreos", "debian", "fedora", "mandrake", "mandriva", "nld", "opensuse",
+	"oracleLinux", "redhat", "rhel", "sles", "suse", "turboLinux", "ubuntu", "vmwarePhoton",
+}
+
+// customizeVM applies the guest OS customization to the powered off Virtual Machine.
+// The guest applies it on the next power on.
+func customizeVM(ctx context.Context, vm *object.VirtualMachine, c *types.VMCustomization) error {
+	spec, err := customizationSpec(ctx, vm, c)
+	if err != nil {
+		return err
+	}
+
+	task, err := vm.Customize(ctx, *spec)
+	if err != nil {
+		return err
+	}
+
+	return task.Wait(ctx)
+}
+
+// customizationSpec returns the named spec from vCenter or builds a Linux spec from the inline settings.
+// The inline settings are rejected for other guests, e.g. Windows needs Sysprep settings of a named spec.
+func customizationSpec(ctx context.Context, vm *object.VirtualMachine, c *types.VMCustomization) (*vmware_types.CustomizationSpec, error) {
+	if c.SpecName != "" {
+		item, err := object.NewCustomizationSpecManager(vm.Client()).GetCustomizationSpec(ctx, c.SpecName)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not get customization spec '%s'", c.SpecName)
+		}
+		return &item.Spec, nil
+	}
+
+	var o mo.VirtualMachine
+	if err := vm.Properties(ctx, vm.Reference(), []string{"config.guestId"}, &o); err != nil {
+		return nil, errors.Wrap(err, "could not get Virtual Machine guest OS")
+	}
+
+	var guestID string
+	if o.Config != nil {
+		guestID = o.Config.GuestId
+	}
+	if !isLinuxGuest(guestID) {
+		return nil, fmt.Errorf("inline customization supports only Linux guests, Virtual Machine guest OS is '%s'. Use a customization spec stored in vCenter", guestID)
+	}
+
+	devices, err := vm.Device(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get Virtual Machine devices")
+	}
+
+	// vSphere requires settings for every network adapter
+	nics := devices.SelectByType((*vmware_types.VirtualEthernetCard)(nil))
+	if len(c.NICs) > len(nics) {
+		return nil, fmt.Errorf("customization has settings for %d NICs, but Virtual Machine has %d", len(c.NICs), len(nics))
+	}
+
+	var hostname vmware_types.BaseCustomizationName = &vmware_types.CustomizationVirtualMachineName{}
+	if c.Hostname != "" {
+		hostname = &vmware_types.CustomizationFixedName{Name: c.Hostname}
+	}
+
+	domain := c.Domain
+	if domain == "" {
+		domain = defaultCustomizationDomain
+	}
+
+	spec := &vmware_types.CustomizationSpec{
+		Identity: &vmware_types.CustomizationLinuxPrep{
+			HostName: hostname,
+			Domain:   domain,
+		},
+		GlobalIPSettings: vmware_types.CustomizationGlobalIPSettings{
+			DnsServerList: c.DNSServers,
+			DnsSuffixList: c.DNSSuffixes,
+		},
+	}
+
+	for i := range nics {
+		adapter := vmware_types.CustomizationIPSettings{
+			Ip: &vmware_types.CustomizationDhcpIpGenerator{},
+		}
+
+		if i < len(c.NICs) {
+			nic := c.NICs[i]
+			if nic.IP != "" {
+				adapter.Ip = &vmware_types.CustomizationFixedIp{IpAddress: nic.IP}
+				adapter.SubnetMask = nic.Netmask
+				adapter.Gateway = nic.Gateways
+			}
+			adapter.DnsServerList = nic.DNSServers
+		}
+
+		spec.NicSettingMap = append(spec.NicSettingMap, vmware_types.CustomizationAdapterMapping{
+			Adapter: adapter,
+		})
+	}
+
+	return spec, nil
+}
+
+// isLinuxGuest reports whether the vSphere guest OS identifier belongs to a Linux distribution
+func isLinuxGuest(guestID string) bool {
+	if strings.Contains(guestID, "Linux") {
+		return true
+	}
+
+	for _, prefix := range linuxGuestPrefixes {
+		if strings.HasPrefix(guestID, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
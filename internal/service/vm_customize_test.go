@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	vmware_types "github.com/vmware/govmomi/vim25/types"
+
+	"github.com/vterdunov/janna-api/internal/types"
+)
+
+func TestIsLinuxGuest(t *testing.T) {
+	tests := []struct {
+		guestID string
+		want    bool
+	}{
+		{"ubuntu64Guest", true},
+		{"rhel7_64Guest", true},
+		{"centos8_64Guest", true},
+		{"oracleLinux7_64Guest", true},
+		{"otherLinux64Guest", true},
+		{"other3xLinux64Guest", true},
+		{"genericLinuxGuest", true},
+		{"vmwarePhoton64Guest", true},
+		{"windows9Server64Guest", false},
+		{"winNetStandardGuest", false},
+		{"freebsd12_64Guest", false},
+		{"darwin18_64Guest", false},
+		{"otherGuest64", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.guestID, func(t *testing.T) {
+			if got := isLinuxGuest(tt.guestID); got != tt.want {
+				t.Errorf("isLinuxGuest(%q) = %v, want %v", tt.guestID, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCustomizationSpec(t *testing.T) {
+	tests := []struct {
+		name    string
+		guestID string
+		wantErr string
+	}{
+		{"linux", "ubuntu64Guest", ""},
+		{"windows", "windows9Server64Guest", "inline customization supports only Linux guests, Virtual Machine guest OS is 'windows9Server64Guest'"},
+		{"other", "otherGuest", "inline customization supports only Linux guests"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, cleanup := newTestVCenter(t)
+			defer cleanup()
+
+			ctx := context.Background()
+			vm := testVM(t, c)
+			task, err := vm.Reconfigure(ctx, vmware_types.VirtualMachineConfigSpec{GuestId: tt.guestID})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := task.Wait(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			spec, err := customizationSpec(ctx, vm, &types.VMCustomization{Hostname: "web-01"})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("customizationSpec() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			identity, ok := spec.Identity.(*vmware_types.CustomizationLinuxPrep)
+			if !ok || identity.Domain != defaultCustomizationDomain {
+				t.Errorf("customizationSpec() identity = %+v, want Linux settings", spec.Identity)
+			}
+		})
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"math/rand"
 	"os"
 	"path"
+	"strings"
 	"sync"
 	"time"
 
@@ -25,6 +26,7 @@ import (
 	"github.com/vmware/govmomi/vim25/soap"
 	vmware_types "github.com/vmware/govmomi/vim25/types"
 
+	"github.com/vterdunov/janna-api/internal/domain"
 	"github.com/vterdunov/janna-api/internal/types"
 )
 
@@ -32,6 +34,8 @@ type Deployment struct {
 	Client *vim25.Client
 	Finder *find.Finder
 	logger log.Logger
+	// task receives disks upload progress. Can be nil
+	task TaskStatuser
 
 	ovfx
 }
@@ -45,7 +49,19 @@ type ovfx struct {
 	ResourcePool   *object.ResourcePool
 	Host           *object.HostSystem
 	NetworkMapping []Network
+	// DefaultNetwork is a target of the OVF networks without a vSphere network of the same name. Can be empty
+	DefaultNetwork string
 	Annotation     string
+	// DiskProvisioning is a format of the imported disks
+	DiskProvisioning string
+	// Properties are OVF vApp properties values
+	Properties map[string]string
+	// Checksum is a user checksum of the whole OVA in '<algo>:<hex>' form. Can be empty
+	Checksum string
+	// DatastoreStrategy chooses one of the requested datastores
+	DatastoreStrategy domain.DatastoreStrategy
+	// RequiredSpace is the datastore space the disks take. Datastores with less free space are skipped
+	RequiredSpace int64
 }
 
 // Network defines a mapping from each network inside the OVF
@@ -63,13 +79,31 @@ func (s *service) VMDeploy(ctx context.Context, params *types.VMDeployParams) (s
 	// TODO: validate incoming params according business rules (https://github.com/asaskevich/govalidator)
 	// use Endpoint middleware
 
+	if s.deploys.isClosed() {
+		return "", errDeployQueueClosed
+	}
+
 	// predeploy checks
+	if params.Checksum != "" {
+		if _, err := parseChecksum(params.Checksum); err != nil {
+			return "", err
+		}
+	}
+
+	// an uploaded OVA is kept until the deploy is finished
+	ovaPath, releaseOVA, err := s.resolveOVA(params.OVAURL)
+	if err != nil {
+		return "", err
+	}
+
 	exist, err := isVMExist(ctx, s.Client, params)
 	if err != nil {
+		releaseOVA()
 		return "", err
 	}
 
 	if exist {
+		releaseOVA()
 		return "", fmt.Errorf("Virtual Machine '%s' already exist", params.Name) //nolint: stylecheck,golint
 	}
 
@@ -81,36 +115,151 @@ func (s *service) VMDeploy(ctx context.Context, params *types.VMDeployParams) (s
 	l := log.With(s.logger, "request_id", reqID)
 	l = log.With(l, "vm", params.Name)
 
-	taskCtx, cancel := context.WithTimeout(context.Background(), s.cfg.TaskTTL)
+	// jobCtx is cancelled by a user. The deploy timeout starts when a worker picks the job up
+	jobCtx, stop := context.WithCancel(context.Background())
+
+	t := s.statuses.NewTask(domain.TaskKindVMDeploy)
+	t.Update(func(task *domain.Task) {
+		task.SetStage(domain.TaskStageQueued)
+		task.VMName = params.Name
+		task.RequestID = reqID
+		task.Cleanup = &domain.TaskCleanup{Policy: params.CleanupPolicy}
+	})
+	rt := s.running.add(t.ID(), stop)
+
+	finish := func() {
+		stop()
+		releaseOVA()
+		// the delivery starts before the task is removed, so shutdown waits for it
+		if params.Callback.URL != "" {
+			s.webhooks.send(t, params.Callback.URL, params.Callback.Secret, l)
+		}
+		s.running.remove(t.ID())
+	}
+
+	job := &deployJob{
+		id:         t.ID(),
+		datacenter: params.Datacenter,
+		task:       t,
+		drop: func() {
+			if rt.Interrupted() {
+				l.Log("msg", "Deploy was interrupted in the queue")
+				t.Update(func(task *domain.Task) {
+					task.Interrupted = true
+					task.Message = "Interrupted by Janna shutdown"
+				})
+				finish()
+				return
+			}
 
-	t := s.statuses.NewTask()
-	t.Str("stage", "start")
+			l.Log("msg", "Deploy was cancelled in the queue")
+			t.Update(func(task *domain.Task) {
+				task.SetStage(domain.TaskStageCancelled)
+				task.Message = "Deploy was cancelled"
+				task.QueuePosition = 0
+			})
+			finish()
+		},
+	}
 
-	// Start deploy in background
-	go func() {
+	job.run = func() {
+		if jobCtx.Err() != nil {
+			// cancelled right before a worker picked the job up
+			job.drop()
+			return
+		}
+		defer finish()
+
+		taskCtx, cancel := context.WithTimeout(jobCtx, s.cfg.TaskTTL)
 		defer cancel()
-		d, err := newDeployment(taskCtx, s.Client, params, l)
+
+		t.Update(func(task *domain.Task) {
+			task.SetStage(domain.TaskStageStart)
+			task.QueuePosition = 0
+		})
+
+		// the OVA descriptor is needed to choose a datastore. A remote OVA is read only up to the descriptor,
+		// so an unsuitable placement or disk format fails before the OVA is downloaded
+		var err error
+		var ova *ovaPackage
+		if !params.IsClone() && !params.IsLibrary() {
+			if ova, err = readOVA(s.Client, ovaPath); err != nil {
+				err = errors.Wrap(err, "Could not read OVA")
+				l.Log("err", err)
+				s.failDeploy(t, rt, nil, err, l)
+				cancel()
+				return
+			}
+		}
+
+		d, err := newDeployment(taskCtx, s.Client, params, requiredSpace(params, ova), l)
 		if err != nil {
 			err = errors.Wrap(err, "Could not create deployment object")
 			l.Log("err", err)
-			t.Str(
-				"stage", "error",
-				"error", err.Error(),
-			)
+			s.failDeploy(t, rt, nil, err, l)
 			cancel()
 			return
 		}
+		d.task = t
+
+		releaseCache := func() {}
+		if ova != nil {
+			var src string
+			var cacheStatus domain.OVACacheStatus
+			src, cacheStatus, releaseCache, err = s.ovaCache.open(taskCtx, ovaPath, params.Checksum, t)
+			if err == nil {
+				t.Update(func(task *domain.Task) {
+					task.OVACache = cacheStatus
+				})
+				if cacheStatus == domain.OVACacheHit || cacheStatus == domain.OVACacheMiss {
+					// the cache has verified the checksum while it was downloading the OVA
+					d.Checksum = ""
+					ova, err = readOVA(s.Client, src)
+					if err != nil {
+						releaseCache()
+					}
+				}
+			}
+			if err != nil {
+				err = errors.Wrap(err, "Could not read OVA")
+				l.Log("err", err)
+				s.failDeploy(t, rt, nil, err, l)
+				cancel()
+				return
+			}
+		}
 
-		t.Str("stage", "import")
-		moref, err := d.Import(taskCtx, params.OVAURL, params.Annotation)
-		if err != nil {
+		var moref *vmware_types.ManagedObjectReference
+		if params.IsClone() {
+			t.Update(func(task *domain.Task) {
+				task.SetStage(domain.TaskStageClone)
+			})
+			moref, err = d.Clone(taskCtx, params.Clone.UUID, params.Clone.Path, params.Annotation)
+			err = errors.Wrap(err, "Could not clone Virtual Machine")
+		} else if params.IsLibrary() {
+			t.Update(func(task *domain.Task) {
+				task.SetStage(domain.TaskStageLibrary)
+			})
+			var c *libraryClient
+			var logout func()
+			c, logout, err = s.newLibraryClient(taskCtx)
+			if err == nil {
+				moref, err = d.DeployLibraryItem(taskCtx, c, params.Library.Name, params.Library.Item, params.Annotation)
+				logout()
+			}
+			err = errors.Wrap(err, "Could not deploy Content Library item")
+		} else {
+			t.Update(func(task *domain.Task) {
+				task.SetStage(domain.TaskStageImport)
+			})
+			moref, err = d.Import(taskCtx, ova, params.Annotation)
+			releaseCache()
 			err = errors.Wrap(err, "Could not import OVA/OVF")
+		}
+		if err != nil {
 			l.Log("err", err)
 
-			t.Str(
-				"stage", "error",
-				"error", err.Error(),
-			)
+			s.failDeploy(t, rt, nil, err, l)
 
 			if err, ok := err.(stackTracer); ok {
 				for _, f := range err.StackTrace() {
@@ -122,45 +271,163 @@ func (s *service) VMDeploy(ctx context.Context, params *types.VMDeployParams) (s
 			return
 		}
 
-		t.Str("stage", "create")
 		vmx := object.NewVirtualMachine(s.Client, *moref)
 
+		if !params.Hardware.IsEmpty() {
+			l.Log("msg", "Reconfiguring hardware")
+			t.Update(func(task *domain.Task) {
+				task.SetStage(domain.TaskStageReconfigure)
+				task.Result.VMUUID = vmx.UUID(taskCtx)
+				task.Message = "Reconfiguring hardware"
+			})
+			if err := reconfigureVM(taskCtx, vmx, &params.Hardware, d.DiskProvisioning); err != nil {
+				err = errors.Wrap(err, "Could not reconfigure Virtual Machine hardware")
+				l.Log("err", err)
+				s.failDeploy(t, rt, vmx, err, l)
+				cancel()
+				return
+			}
+		}
+
+		t.Update(func(task *domain.Task) {
+			task.SetStage(domain.TaskStageCreate)
+			task.Result.VMUUID = vmx.UUID(taskCtx)
+			// clones keep the disk format of the source
+			if !params.IsClone() {
+				task.Result.DiskProvisioning = params.DiskProvisioning
+			}
+		})
+
+		if !params.Customization.IsEmpty() {
+			l.Log("msg", "Customizing guest OS")
+			t.Update(func(task *domain.Task) {
+				task.Message = "Customizing guest OS"
+			})
+			if err := customizeVM(taskCtx, vmx, &params.Customization); err != nil {
+				err = errors.Wrap(err, "Could not customize guest OS")
+				l.Log("err", err)
+				s.failDeploy(t, rt, vmx, err, l)
+				cancel()
+				return
+			}
+		}
+
+		s.finishDeploy(taskCtx, t, rt, vmx, l)
+
+		cancel()
+	}
+
+	if err := s.deploys.push(job); err != nil {
+		t.Update(func(task *domain.Task) {
+			task.Fail(err)
+			task.Cleanup.Action = domain.CleanupActionNone
+		})
+		stop()
+		releaseOVA()
+		s.running.remove(t.ID())
+		return "", err
+	}
+
+	return t.ID(), nil
+}
+
+// finishDeploy powers on the imported Virtual Machine if it is needed and waits for its IP addresses
+func (s *service) finishDeploy(ctx context.Context, t TaskStatuser, rt *runningTask, vm *object.VirtualMachine, l log.Logger) {
+	state, err := vm.PowerState(ctx)
+	if err != nil {
+		err = errors.Wrap(err, "Could not get Virtual Machine power state")
+		l.Log("err", err)
+		s.failDeploy(t, rt, vm, err, l)
+		return
+	}
+
+	if state != vmware_types.VirtualMachinePowerStatePoweredOn {
 		l.Log("msg", "Powering on...")
-		t.Str("message", "Powerig on")
-		if err = PowerON(taskCtx, vmx); err != nil {
+		t.Update(func(task *domain.Task) {
+			task.Message = "Powering on"
+		})
+		if err = PowerON(ctx, vm); err != nil {
 			err = errors.Wrap(err, "Could not Virtual Machine power on")
 			l.Log("err", err)
-			t.Str(
-				"stage", "error",
-				"error", err.Error(),
-			)
-			cancel()
+			s.failDeploy(t, rt, vm, err, l)
 			return
 		}
+	}
 
-		t.Str("message", "Waiting for IP addresses")
-		ips, err := WaitForIP(taskCtx, vmx)
-		if err != nil {
-			err = errors.Wrap(err, "error getting IP address")
-			l.Log("err", err)
-			t.Str(
-				"stage", "error",
-				"error", err.Error(),
-			)
-			cancel()
-			return
-		}
+	t.Update(func(task *domain.Task) {
+		task.Message = "Waiting for IP addresses"
+	})
+	ips, err := WaitForIP(ctx, vm)
+	if err != nil {
+		err = errors.Wrap(err, "error getting IP address")
+		l.Log("err", err)
+		s.failDeploy(t, rt, vm, err, l)
+		return
+	}
 
-		l.Log("msg", "Successful deploy", "ips", fmt.Sprintf("%v", ips))
-		t.Str(
-			"stage", "complete",
-			"message", "ok",
-		).StrArr("ip", ips)
+	l.Log("msg", "Successful deploy", "ips", fmt.Sprintf("%v", ips))
+	t.Update(func(task *domain.Task) {
+		task.SetStage(domain.TaskStageComplete)
+		task.Message = "ok"
+		task.Progress = 100
+		task.Result.IPs = ips
+	})
+}
 
-		cancel()
-	}()
+// failDeploy records a deploy error in the task status and cleans up the Virtual Machine
+// according to the cleanup policy of the task.
+// If the task was cancelled by a user, it records 'cancelled' stage instead
+// and destroys the Virtual Machine when it was requested.
+// If the task was interrupted by Janna shutdown, it keeps the stage to resume the task after restart.
+func (s *service) failDeploy(t TaskStatuser, rt *runningTask, vm *object.VirtualMachine, err error, l log.Logger) {
+	if rt.Interrupted() {
+		l.Log("msg", "Deploy was interrupted by shutdown", "err", err)
+		t.Update(func(task *domain.Task) {
+			task.Interrupted = true
+			task.Message = "Interrupted by Janna shutdown"
+		})
+		return
+	}
 
-	return t.ID(), nil
+	cancelled, destroy := rt.Cancelled()
+	if !cancelled {
+		cleanup := s.cleanupFailedDeploy(t, vm, l)
+		t.Update(func(task *domain.Task) {
+			task.Fail(err)
+			task.Cleanup = cleanup
+		})
+		return
+	}
+
+	l.Log("msg", "Deploy was cancelled")
+	if !destroy || vm == nil {
+		t.Update(func(task *domain.Task) {
+			task.SetStage(domain.TaskStageCancelled)
+			task.Message = "Deploy was cancelled"
+		})
+		return
+	}
+
+	// the task context is already cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	l.Log("msg", "Destroying Virtual Machine")
+	if err := destroyVM(ctx, vm); err != nil {
+		err = errors.Wrap(err, "Could not destroy Virtual Machine")
+		l.Log("err", err)
+		t.Update(func(task *domain.Task) {
+			task.Error = &domain.TaskError{Stage: task.Stage, Message: err.Error()}
+			task.SetStage(domain.TaskStageCancelled)
+			task.Message = "Deploy was cancelled"
+		})
+		return
+	}
+
+	t.Update(func(task *domain.Task) {
+		task.SetStage(domain.TaskStageCancelled)
+		task.Message = "Deploy was cancelled. Virtual Machine was destroyed"
+	})
 }
 
 func (o *Deployment) chooseDatacenter(ctx context.Context, dcName string) error {
@@ -184,7 +451,7 @@ func (o *Deployment) chooseDatastore(ctx context.Context, dsType string, names [
 			return err
 		}
 	default:
-		errors.New("could not recognize datastore type. Possible values are 'cluster', 'datastore'")
+		return errors.New("could not recognize datastore type. Possible values are 'cluster', 'datastore'")
 	}
 	return nil
 }
@@ -244,6 +511,41 @@ func (o *Deployment) chooseDatastoreWithCluster(ctx context.Context, names []str
 	return nil
 }
 
+// checkDiskProvisioning rejects disk formats the chosen datastore does not support,
+// so the deploy fails before the OVA is downloaded or the Content Library item is deployed
+func (o *Deployment) checkDiskProvisioning(ctx context.Context) error {
+	var ds mo.Datastore
+	if err := o.Datastore.Properties(ctx, o.Datastore.Reference(), []string{"summary", "capability"}, &ds); err != nil {
+		return errors.Wrap(err, "could not get datastore properties")
+	}
+
+	return checkDatastoreProvisioning(ds, o.DiskProvisioning)
+}
+
+// checkDatastoreProvisioning reports whether the datastore can keep disks in the format
+func checkDatastoreProvisioning(ds mo.Datastore, diskProvisioning string) error {
+	name := ds.Summary.Name
+	if !ds.Summary.Accessible {
+		return fmt.Errorf("datastore '%s' is not accessible", name)
+	}
+
+	switch diskProvisioning {
+	case "thin":
+		if !ds.Capability.PerFileThinProvisioningSupported {
+			return fmt.Errorf("datastore '%s' does not support thin disk provisioning", name)
+		}
+	case "seSparse":
+		if ds.Capability.SeSparseSupported == nil || !*ds.Capability.SeSparseSupported {
+			return fmt.Errorf("datastore '%s' does not support seSparse disk provisioning", name)
+		}
+	case "thick", "eagerZeroedThick":
+	default:
+		return fmt.Errorf("unknown disk provisioning '%s'. Possible values are %s", diskProvisioning, strings.Join(types.DiskProvisioningTypes, ", "))
+	}
+
+	return nil
+}
+
 func isStorageDRSEnabled(ctx context.Context, pod *object.StoragePod) (bool, error) {
 	var props mo.StoragePod
 	if err := pod.Properties(ctx, pod.Reference(), nil, &props); err != nil {
@@ -257,16 +559,6 @@ func isStorageDRSEnabled(ctx context.Context, pod *object.StoragePod) (bool, err
 	return props.PodStorageDrsEntry.StorageDrsConfig.PodConfig.Enabled, nil
 }
 
-func (o *Deployment) chooseDatastoreWithDatastore(ctx context.Context, names []string) error {
-	ds, err := o.Finder.DatastoreOrDefault(ctx, pickRandom(names))
-	if err != nil {
-		return err
-	}
-
-	o.Datastore = ds
-	return nil
-}
-
 func pickRandom(slice []string) string {
 	rand.Seed(time.Now().Unix())
 	return slice[rand.Intn(len(slice))]
@@ -349,35 +641,8 @@ func (o *Deployment) computerResourceWithResourcePool(ctx context.Context, rpNam
 	return nil
 }
 
-func (o *Deployment) networkMap(ctx context.Context, e *ovf.Envelope) (p []vmware_types.OvfNetworkMapping) {
-	networks := map[string]string{}
-
-	if e.Network != nil {
-		for _, net := range e.Network.Networks {
-			o.logger.Log("msg", "found OVF networks mapping", "name", net.Name, "network", net.Name)
-			networks[net.Name] = net.Name
-		}
-	}
-
-	for _, net := range o.NetworkMapping {
-		o.logger.Log("msg", "found override networks mapping", "name", net.Name, "network", net.Network)
-		networks[net.Name] = net.Network
-	}
-
-	for src, dst := range networks {
-		if net, err := o.Finder.Network(ctx, dst); err == nil {
-			p = append(p, vmware_types.OvfNetworkMapping{
-				Name:    src,
-				Network: net.Reference(),
-			})
-			o.logger.Log("msg", "networks mapping", "name", src, "network", dst)
-		}
-	}
-
-	return p
-}
-
-func (o *Deployment) Upload(ctx context.Context, lease *nfc.Lease, item nfc.FileItem, archive importx.ArchiveFlag) error {
+// Upload sends the disk to the import lease. The disk is verified against the manifest while it is sent
+func (o *Deployment) Upload(ctx context.Context, lease *nfc.Lease, item nfc.FileItem, archive importx.ArchiveFlag, manifest ovaManifest, tracker *uploadTracker) error {
 	file := item.Path
 
 	f, size, err := archive.Open(file)
@@ -386,8 +651,17 @@ func (o *Deployment) Upload(ctx context.Context, lease *nfc.Lease, item nfc.File
 	}
 	defer f.Close()
 
+	r, verify, err := manifest.reader(file, f)
+	if err != nil {
+		return err
+	}
+
+	tracker.SetSize(file, size)
+
 	outputStr := path.Base(file)
-	pl := newProgressLogger(outputStr, o.logger)
+	pl := newProgressLogger(outputStr, o.logger, func(percentage float32) {
+		tracker.Update(file, percentage)
+	})
 	defer pl.Wait()
 
 	opts := soap.Upload{
@@ -395,7 +669,11 @@ func (o *Deployment) Upload(ctx context.Context, lease *nfc.Lease, item nfc.File
 		Progress:      pl,
 	}
 
-	return lease.Upload(ctx, item, f, opts)
+	if err := lease.Upload(ctx, item, r, opts); err != nil {
+		return err
+	}
+
+	return verify()
 }
 
 type TapeArchive struct {
@@ -444,9 +722,18 @@ func (t *TapeArchiveEntry) Close() error {
 	return t.f.Close()
 }
 
-func (o *Deployment) Import(ctx context.Context, ovaURL string, anno string) (*vmware_types.ManagedObjectReference, error) {
+// ovaPackage is an opened OVA package with its parsed OVF descriptor
+type ovaPackage struct {
+	path     string
+	archive  importx.ArchiveFlag
+	ovfData  []byte
+	envelope *ovf.Envelope
+}
+
+// readOVA opens the OVA package and parses its OVF descriptor
+func readOVA(c *vim25.Client, ovaURL string) (*ovaPackage, error) {
 	opener := importx.Opener{
-		Client: o.Client,
+		Client: c,
 	}
 
 	ta := TapeArchive{
@@ -468,6 +755,44 @@ func (o *Deployment) Import(ctx context.Context, ovaURL string, anno string) (*v
 		return nil, fmt.Errorf("failed to parse ovf: %s", err)
 	}
 
+	return &ovaPackage{
+		path:     ovaURL,
+		archive:  archive,
+		ovfData:  ovfData,
+		envelope: e,
+	}, nil
+}
+
+func (o *Deployment) Import(ctx context.Context, ova *ovaPackage, anno string) (*vmware_types.ManagedObjectReference, error) {
+	// a cached OVA is verified while it is downloaded, the checksum is cleared then.
+	// A remote OVA that bypasses the cache is downloaded once more just to hash it
+	if o.Checksum != "" {
+		c, err := parseChecksum(o.Checksum)
+		if err != nil {
+			return nil, err
+		}
+
+		o.logger.Log("msg", "Verify OVA checksum")
+		o.setMessage("Verifying OVA checksum")
+		if err := verifyOVAChecksum(ctx, o.Client, ova.path, c); err != nil {
+			return nil, err
+		}
+	}
+
+	archive, ovfData, e := ova.archive, ova.ovfData, ova.envelope
+
+	manifest, err := readManifest(archive)
+	if err != nil {
+		return nil, err
+	}
+	if manifest == nil {
+		o.logger.Log("msg", "OVA does not have a manifest. Files are not verified")
+	}
+
+	if err := manifest.verifyDescriptor(ovfData); err != nil {
+		return nil, err
+	}
+
 	name := "Virtual Appliance"
 	if e.VirtualSystem != nil {
 		name = e.VirtualSystem.ID
@@ -481,6 +806,17 @@ func (o *Deployment) Import(ctx context.Context, ovaURL string, anno string) (*v
 		name = o.Name
 	}
 
+	properties, err := ovfPropertyMapping(e, o.Properties)
+	if err != nil {
+		return nil, err
+	}
+
+	networks, err := o.resolveNetworks(ctx, ovfNetworks(e))
+	if err != nil {
+		return nil, err
+	}
+	o.setNetworks(networks)
+
 	o.logger.Log("msg", "Create Import Spec params")
 	cisp := vmware_types.OvfCreateImportSpecParams{
 		// See https://github.com/vmware/govmomi/blob/v0.16.0/vim25/types/enum.go#L3381-L3395
@@ -488,10 +824,11 @@ func (o *Deployment) Import(ctx context.Context, ovaURL string, anno string) (*v
 		// "preallocated", "thin", "seSparse", "rdm", "rdmp",
 		// "raw", "delta", "sparse2Gb", "thick2Gb", "eagerZeroedThick",
 		// "sparseMonolithic", "flatMonolithic", "thick"
-		// TODO: get form params
-		DiskProvisioning: "thin",
+		// Janna accepts only types.DiskProvisioningTypes
+		DiskProvisioning: o.DiskProvisioning,
 		EntityName:       name,
-		NetworkMapping:   o.networkMap(ctx, e),
+		NetworkMapping:   ovfNetworkMapping(networks),
+		PropertyMapping:  properties,
 	}
 
 	o.logger.Log("msg", "Get OVF manager")
@@ -532,18 +869,30 @@ func (o *Deployment) Import(ctx context.Context, ovaURL string, anno string) (*v
 	if err != nil {
 		err = errors.Wrap(err, "error while waiting lease")
 		o.logger.Log("err", err)
+		o.abortLease(lease)
 		return nil, err
 	}
 
+	if o.task != nil {
+		// remember the entity, so a partially imported VM can be found after Janna restart
+		uuid := object.NewVirtualMachine(o.Client, info.Entity).UUID(ctx)
+		o.task.Update(func(t *domain.Task) {
+			t.Result.VMUUID = uuid
+		})
+	}
+
 	o.logger.Log("msg", "Get lease updater")
 	u := lease.StartUpdater(ctx, info)
 	defer u.Done()
 
+	tracker := newUploadTracker(o.task, info.Items)
+
 	o.logger.Log("msg", "Loop over lease info items")
 	for _, item := range info.Items {
 		o.logger.Log("msg", "Upload disk", "disk", item.Path)
-		if err = o.Upload(ctx, lease, item, archive); err != nil {
+		if err = o.Upload(ctx, lease, item, archive, manifest, tracker); err != nil {
 			o.logger.Log("msg", "Could not upload disk to VMWare", "disk", item.Path)
+			o.abortLease(lease)
 			return nil, errors.Wrapf(err, "Could not upload disk to VMWare, disk: %v", item.Path)
 		}
 	}
@@ -552,6 +901,29 @@ func (o *Deployment) Import(ctx context.Context, ovaURL string, anno string) (*v
 	return &info.Entity, lease.Complete(ctx)
 }
 
+// setMessage shows the message in the task status if the deployment has a task
+func (o *Deployment) setMessage(msg string) {
+	if o.task == nil {
+		return
+	}
+
+	o.task.Update(func(t *domain.Task) {
+		t.Message = msg
+	})
+}
+
+// abortLease aborts the import lease, so vSphere removes the partially imported entity.
+// The import context may be already cancelled, so a separate one is used.
+func (o *Deployment) abortLease(lease *nfc.Lease) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	o.logger.Log("msg", "Abort lease")
+	if err := lease.Abort(ctx, nil); err != nil {
+		o.logger.Log("err", errors.Wrap(err, "Could not abort lease"))
+	}
+}
+
 func isVMExist(ctx context.Context, c *vim25.Client, params *types.VMDeployParams) (bool, error) {
 	f := find.NewFinder(c, false)
 	dc, err := f.DatacenterOrDefault(ctx, params.Datacenter)
@@ -570,9 +942,10 @@ func isVMExist(ctx context.Context, c *vim25.Client, params *types.VMDeployParam
 }
 
 // newDeployment create a new deployment object.
-// It choose needed resources
-func newDeployment(ctx context.Context, c *vim25.Client, params *types.VMDeployParams, l log.Logger) (*Deployment, error) { //nolint: unparam
+// It choose needed resources. Datastores with less than requiredSpace bytes free are not chosen
+func newDeployment(ctx context.Context, c *vim25.Client, params *types.VMDeployParams, requiredSpace int64, l log.Logger) (*Deployment, error) { //nolint: unparam
 	d := newSimpleDeployment(c, params, l)
+	d.RequiredSpace = requiredSpace
 
 	// step 1. choose Datacenter and folder
 	if err := d.chooseDatacenter(ctx, params.Datacenter); err != nil {
@@ -605,6 +978,14 @@ func newDeployment(ctx context.Context, c *vim25.Client, params *types.VMDeployP
 		return nil, err
 	}
 
+	// clones keep the disk format of the source
+	if !params.IsClone() {
+		if err := d.checkDiskProvisioning(ctx); err != nil {
+			l.Log("err", err)
+			return nil, err
+		}
+	}
+
 	return d, nil
 }
 
@@ -623,8 +1004,13 @@ func newSimpleDeployment(c *vim25.Client, deployParams *types.VMDeployParams, lo
 	}
 
 	ovf := ovfx{
-		Name:           deployParams.Name,
-		NetworkMapping: nms,
+		Name:              deployParams.Name,
+		NetworkMapping:    nms,
+		DefaultNetwork:    deployParams.DefaultNetwork,
+		DiskProvisioning:  deployParams.DiskProvisioning,
+		Properties:        deployParams.Properties,
+		Checksum:          deployParams.Checksum,
+		DatastoreStrategy: deployParams.Datastores.Strategy,
 	}
 
 	d := &Deployment{
@@ -665,6 +1051,8 @@ func WaitForIP(ctx context.Context, vm *object.VirtualMachine) ([]string, error)
 
 type progressLogger struct {
 	prefix string
+	// report receives upload percentage
+	report func(percentage float32)
 
 	wg sync.WaitGroup
 
@@ -700,6 +1088,9 @@ func (p *progressLogger) loopA() {
 
 	if called {
 		p.logger.Log("msg", "uploaded", "file", p.prefix)
+		if err == nil || err == io.EOF {
+			p.report(100)
+		}
 	}
 }
 
@@ -709,6 +1100,9 @@ func (p *progressLogger) loopB(tick *time.Ticker, ch <-chan progress.Report) err
 	var ok bool
 	var err error
 
+	reportTick := time.NewTicker(time.Second)
+	defer reportTick.Stop()
+
 	for ok = true; ok; {
 		select {
 		case r, ok = <-ch:
@@ -721,6 +1115,10 @@ func (p *progressLogger) loopB(tick *time.Ticker, ch <-chan progress.Report) err
 				pc := fmt.Sprintf("%.0f%%", r.Percentage())
 				p.logger.Log("msg", "uploading disks", "file", p.prefix, "progress", pc)
 			}
+		case <-reportTick.C:
+			if r != nil {
+				p.report(r.Percentage())
+			}
 		}
 	}
 
@@ -738,9 +1136,10 @@ func (p *progressLogger) Sink() chan<- progress.Report {
 	return ch
 }
 
-func newProgressLogger(prefix string, logger log.Logger) *progressLogger {
+func newProgressLogger(prefix string, logger log.Logger, report func(percentage float32)) *progressLogger {
 	p := &progressLogger{
 		prefix: prefix,
+		report: report,
 
 		sink:   make(chan chan progress.Report),
 		done:   make(chan struct{}),
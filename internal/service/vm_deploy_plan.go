@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/ovf"
+	vmware_types "github.com/vmware/govmomi/vim25/types"
+
+	"github.com/vterdunov/janna-api/internal/domain"
+	"github.com/vterdunov/janna-api/internal/types"
+)
+
+// VMDeployPlan resolves the placement of the Virtual Machine and validates the deploy source
+// the same way VMDeploy does, but stops before anything is created in vSphere
+func (s *service) VMDeployPlan(ctx context.Context, params *types.VMDeployParams) (*domain.DeployPlan, error) {
+	if params.Checksum != "" {
+		if _, err := parseChecksum(params.Checksum); err != nil {
+			return nil, err
+		}
+	}
+
+	exist, err := isVMExist(ctx, s.Client, params)
+	if err != nil {
+		return nil, err
+	}
+
+	if exist {
+		return nil, fmt.Errorf("Virtual Machine '%s' already exist", params.Name) //nolint: stylecheck,golint
+	}
+
+	reqID, _ := ctx.Value(ContextKeyRequestXRequestID).(string)
+	l := log.With(s.logger, "request_id", reqID, "vm", params.Name, "dry_run", true)
+
+	ovaPath, releaseOVA, err := s.resolveOVA(params.OVAURL)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseOVA()
+
+	// the OVA is read once. Its descriptor is needed to choose a datastore
+	var ova *ovaPackage
+	if !params.IsClone() && !params.IsLibrary() {
+		if ova, err = readOVA(s.Client, ovaPath); err != nil {
+			return nil, errors.Wrap(err, "Could not read OVA")
+		}
+	}
+
+	d, err := newDeployment(ctx, s.Client, params, requiredSpace(params, ova), l)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not create deployment object")
+	}
+
+	plan := &domain.DeployPlan{
+		Name:         params.Name,
+		Datacenter:   objectPath(ctx, d.Datacenter.Common),
+		Folder:       objectPath(ctx, d.Folder.Common),
+		ResourcePool: objectPath(ctx, d.ResourcePool.Common),
+		Datastore:    objectPath(ctx, d.Datastore.Common),
+		StorageDRS:   params.Datastores.Type == "cluster",
+	}
+	if d.Host != nil {
+		plan.Host = objectPath(ctx, d.Host.Common)
+	}
+
+	switch {
+	case params.IsClone():
+		plan.Source = domain.DeploySourceClone
+		if _, err := d.findCloneSource(ctx, params.Clone.UUID, params.Clone.Path); err != nil {
+			return nil, err
+		}
+
+	case params.IsLibrary():
+		plan.Source = domain.DeploySourceLibrary
+		plan.DiskProvisioning = d.DiskProvisioning
+		if err := s.planLibraryItem(ctx, d, params, plan); err != nil {
+			return nil, err
+		}
+
+	default:
+		plan.Source = domain.DeploySourceOVA
+		plan.DiskProvisioning = d.DiskProvisioning
+		if err := s.planImport(ctx, d, ova, plan); err != nil {
+			return nil, err
+		}
+	}
+
+	return plan, nil
+}
+
+func (s *service) planLibraryItem(ctx context.Context, d *Deployment, params *types.VMDeployParams, plan *domain.DeployPlan) error {
+	c, logout, err := s.newLibraryClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer logout()
+
+	library, err := c.findLibrary(ctx, params.Library.Name)
+	if err != nil {
+		return err
+	}
+
+	item, err := c.findItem(ctx, library.ID, params.Library.Item)
+	if err != nil {
+		return err
+	}
+
+	if item.Type != "ovf" {
+		return fmt.Errorf("content library item '%s' is not an OVF template", item.Name)
+	}
+
+	networks, err := d.resolveLibraryNetworks(ctx, c, item.ID)
+	if err != nil {
+		return err
+	}
+	plan.Networks = networkMappings(networks)
+
+	return nil
+}
+
+func (s *service) planImport(ctx context.Context, d *Deployment, ova *ovaPackage, plan *domain.DeployPlan) error {
+	ovfData, e := ova.ovfData, ova.envelope
+
+	manifest, err := readManifest(ova.archive)
+	if err != nil {
+		return err
+	}
+
+	if err := manifest.verifyDescriptor(ovfData); err != nil {
+		return err
+	}
+
+	properties, err := ovfPropertyMapping(e, d.Properties)
+	if err != nil {
+		return err
+	}
+
+	networks, err := d.resolveNetworks(ctx, ovfNetworks(e))
+	if err != nil {
+		return err
+	}
+	plan.Networks = networkMappings(networks)
+
+	cisp := vmware_types.OvfCreateImportSpecParams{
+		DiskProvisioning: d.DiskProvisioning,
+		EntityName:       d.Name,
+		NetworkMapping:   ovfNetworkMapping(networks),
+		PropertyMapping:  properties,
+	}
+
+	spec, err := ovf.NewManager(s.Client).CreateImportSpec(ctx, string(ovfData), d.ResourcePool, d.Datastore, cisp)
+	if err != nil {
+		return errors.Wrap(err, "Could not create VM spec")
+	}
+
+	if len(spec.Error) != 0 {
+		msgs := make([]string, 0, len(spec.Error))
+		for _, e := range spec.Error {
+			msgs = append(msgs, e.LocalizedMessage)
+		}
+		return errors.New(strings.Join(msgs, "; "))
+	}
+
+	for _, w := range spec.Warning {
+		plan.Warnings = append(plan.Warnings, w.LocalizedMessage)
+	}
+
+	return nil
+}
+
+// objectPath returns the inventory path of the object, or its name if the path is unknown
+func objectPath(ctx context.Context, c object.Common) string {
+	if c.InventoryPath != "" {
+		return c.InventoryPath
+	}
+
+	name, err := c.ObjectName(ctx)
+	if err != nil {
+		return c.Reference().Value
+	}
+
+	return name
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/vmware/govmomi/simulator"
+	vmware_types "github.com/vmware/govmomi/vim25/types"
+
+	"github.com/vterdunov/janna-api/internal/domain"
+	"github.com/vterdunov/janna-api/internal/types"
+)
+
+const testPlanOVF = `<?xml version="1.0" encoding="UTF-8"?>
+<Envelope xmlns="http://schemas.dmtf.org/ovf/envelope/1" xmlns:ovf="http://schemas.dmtf.org/ovf/envelope/1"
+    xmlns:rasd="http://schemas.dmtf.org/wbem/wscim/1/cim-schema/2/CIM_ResourceAllocationSettingData"
+    xmlns:vssd="http://schemas.dmtf.org/wbem/wscim/1/cim-schema/2/CIM_VirtualSystemSettingData">
+  <References/>
+  <NetworkSection>
+    <Info>Networks</Info>
+    <Network ovf:name="VM Network"/>
+  </NetworkSection>
+  <VirtualSystem ovf:id="plan">
+    <Info>A virtual machine</Info>
+    <VirtualHardwareSection>
+      <Info>Virtual hardware</Info>
+      <System>
+        <vssd:ElementName>Virtual Hardware Family</vssd:ElementName>
+        <vssd:InstanceID>0</vssd:InstanceID>
+        <vssd:VirtualSystemType>vmx-13</vssd:VirtualSystemType>
+      </System>
+      <Item>
+        <rasd:ElementName>1 virtual CPU</rasd:ElementName>
+        <rasd:InstanceID>1</rasd:InstanceID>
+        <rasd:ResourceType>3</rasd:ResourceType>
+        <rasd:VirtualQuantity>1</rasd:VirtualQuantity>
+      </Item>
+      <Item>
+        <rasd:AllocationUnits>byte * 2^20</rasd:AllocationUnits>
+        <rasd:ElementName>512MB of memory</rasd:ElementName>
+        <rasd:InstanceID>2</rasd:InstanceID>
+        <rasd:ResourceType>4</rasd:ResourceType>
+        <rasd:VirtualQuantity>512</rasd:VirtualQuantity>
+      </Item>
+    </VirtualHardwareSection>
+  </VirtualSystem>
+</Envelope>`
+
+func TestService_VMDeployPlan(t *testing.T) {
+	ova := writeTestOVA(t, map[string]string{"plan.ovf": testPlanOVF})
+	defer os.RemoveAll(filepath.Dir(ova))
+
+	wrongSum := strings.Repeat("0", 64)
+	badManifest := writeTestOVA(t, map[string]string{
+		"plan.ovf": testPlanOVF,
+		"plan.mf":  "SHA256(plan.ovf)= " + wrongSum,
+	})
+	defer os.RemoveAll(filepath.Dir(badManifest))
+
+	// The simulator does not implement OvfManager, so OVA plans are checked up to the import spec
+	tests := []struct {
+		name      string
+		vmName    string
+		ova       string
+		networks  map[string]string
+		clonePath string
+		want      *domain.DeployPlan
+		wantErr   string
+	}{
+		{
+			name:      "clone",
+			vmName:    "plan",
+			clonePath: "/DC0/vm/DC0_H0_VM0",
+			want: &domain.DeployPlan{
+				Name:   "plan",
+				Source: domain.DeploySourceClone,
+				Host:   "/DC0/host/DC0_H0/DC0_H0",
+			},
+		},
+		{
+			name:      "missing clone source",
+			vmName:    "plan",
+			clonePath: "/DC0/vm/missing",
+			wantErr:   "could not find clone source by path",
+		},
+		{
+			name:     "OVA with undeclared network",
+			vmName:   "plan",
+			ova:      ova,
+			networks: map[string]string{"Other": "VM Network"},
+			wantErr:  "OVF does not declare network 'Other'. Declared networks: VM Network",
+		},
+		{
+			name:    "OVA with wrong manifest",
+			vmName:  "plan",
+			ova:     badManifest,
+			wantErr: "plan.ovf SHA256 mismatch: expected " + wrongSum,
+		},
+		{
+			name:    "existing Virtual Machine",
+			vmName:  "DC0_H0_VM0",
+			ova:     ova,
+			wantErr: "Virtual Machine 'DC0_H0_VM0' already exist",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, cleanup := newTestVCenter(t)
+			defer cleanup()
+
+			// the simulated datastores are not accessible
+			for _, ds := range simulator.Map.All("Datastore") {
+				simulator.Map.Update(ds, []vmware_types.PropertyChange{{Name: "summary.accessible", Val: true}})
+			}
+			vms := len(simulator.Map.All("VirtualMachine"))
+
+			params := &types.VMDeployParams{Name: tt.vmName, OVAURL: tt.ova, DiskProvisioning: "thick", Networks: tt.networks}
+			params.ComputerResources.Type = "host"
+			params.ComputerResources.Path = "/DC0/host/DC0_H0/DC0_H0"
+			params.Datastores.Type = "datastore"
+			params.Clone.Path = tt.clonePath
+
+			s := &service{Client: c, logger: log.NewNopLogger()}
+			got, err := s.VMDeployPlan(context.Background(), params)
+
+			if n := len(simulator.Map.All("VirtualMachine")); n != vms {
+				t.Errorf("service.VMDeployPlan() created %d Virtual Machines", n-vms)
+			}
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("service.VMDeployPlan() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("service.VMDeployPlan() error = %v", err)
+			}
+
+			if got.Name != tt.want.Name || got.Source != tt.want.Source || got.Host != tt.want.Host || got.DiskProvisioning != tt.want.DiskProvisioning {
+				t.Errorf("service.VMDeployPlan() = %+v, want %+v", got, tt.want)
+			}
+			if got.Datacenter == "" || got.Folder == "" || got.ResourcePool == "" || !strings.Contains(got.Datastore, "LocalDS_") {
+				t.Errorf("service.VMDeployPlan() placement = %+v, want datacenter, folder, resource pool and datastore", got)
+			}
+			if len(got.Networks) != len(tt.want.Networks) {
+				t.Fatalf("service.VMDeployPlan() networks = %+v, want %+v", got.Networks, tt.want.Networks)
+			}
+			for i, n := range got.Networks {
+				if n.Name != tt.want.Networks[i].Name || n.Network != tt.want.Networks[i].Network {
+					t.Errorf("service.VMDeployPlan() network %d = %+v, want %+v", i, n, tt.want.Networks[i])
+				}
+			}
+		})
+	}
+}
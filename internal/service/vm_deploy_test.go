@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/mo"
+	vmware_types "github.com/vmware/govmomi/vim25/types"
+
+	"github.com/vterdunov/janna-api/internal/types"
+)
+
+func TestCheckDatastoreProvisioning(t *testing.T) {
+	datastore := func(accessible, thin bool, seSparse *bool) mo.Datastore {
+		return mo.Datastore{
+			Summary: vmware_types.DatastoreSummary{Name: "ds", Accessible: accessible},
+			Capability: vmware_types.DatastoreCapability{
+				PerFileThinProvisioningSupported: thin,
+				SeSparseSupported:                seSparse,
+			},
+		}
+	}
+	all := datastore(true, true, vmware_types.NewBool(true))
+	none := datastore(true, false, nil)
+
+	tests := []struct {
+		name             string
+		ds               mo.Datastore
+		diskProvisioning string
+		wantErr          string
+	}{
+		{"thin", all, "thin", ""},
+		{"thin is not supported", none, "thin", "datastore 'ds' does not support thin disk provisioning"},
+		{"thick", none, "thick", ""},
+		{"eager zeroed thick", none, "eagerZeroedThick", ""},
+		{"seSparse", all, "seSparse", ""},
+		{"seSparse is not supported", datastore(true, true, vmware_types.NewBool(false)), "seSparse", "does not support seSparse"},
+		{"seSparse is unknown", none, "seSparse", "does not support seSparse"},
+		{"unknown value", all, "sparse2Gb", "unknown disk provisioning 'sparse2Gb'"},
+		{"not accessible", datastore(false, true, nil), "thick", "datastore 'ds' is not accessible"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkDatastoreProvisioning(tt.ds, tt.diskProvisioning)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("checkDatastoreProvisioning() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("checkDatastoreProvisioning() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewDeployment_DiskProvisioning(t *testing.T) {
+	c, cleanup := newTestVCenter(t)
+	defer cleanup()
+
+	// the simulated datastores are not accessible
+	for _, ds := range simulator.Map.All("Datastore") {
+		simulator.Map.Update(ds, []vmware_types.PropertyChange{{Name: "summary.accessible", Val: true}})
+	}
+
+	tests := []struct {
+		name             string
+		diskProvisioning string
+		clone            bool
+		wantErr          string
+	}{
+		{"supported", "thick", false, ""},
+		// the simulated datastores do not support thin disks
+		{"not supported", "thin", false, "does not support thin disk provisioning"},
+		{"clone is not checked", "thin", true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := &types.VMDeployParams{Name: "vm", DiskProvisioning: tt.diskProvisioning}
+			params.ComputerResources.Type = "host"
+			params.ComputerResources.Path = "/DC0/host/DC0_H0/DC0_H0"
+			params.Datastores.Type = "datastore"
+			if tt.clone {
+				params.Clone.Path = "/DC0/vm/DC0_H0_VM0"
+			}
+
+			d, err := newDeployment(context.Background(), c, params, 0, log.NewNopLogger())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("newDeployment() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d.Datastore == nil {
+				t.Error("newDeployment() did not choose a datastore")
+			}
+		})
+	}
+}
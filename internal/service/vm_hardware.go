@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+	vmware_types "github.com/vmware/govmomi/vim25/types"
+
+	"github.com/vterdunov/janna-api/internal/types"
+)
+
+// reconfigureVM applies the hardware overrides to the powered off Virtual Machine.
+// New disks are placed to the Virtual Machine datastore with the requested disk provisioning.
+func reconfigureVM(ctx context.Context, vm *object.VirtualMachine, hw *types.VMHardware, diskProvisioning string) error {
+	spec := vmware_types.VirtualMachineConfigSpec{
+		NumCPUs:           hw.NumCPU,
+		NumCoresPerSocket: hw.CoresPerSocket,
+		MemoryMB:          hw.MemoryMB,
+	}
+
+	if len(hw.Disks) != 0 {
+		changes, err := diskChanges(ctx, vm, hw.Disks, diskProvisioning)
+		if err != nil {
+			return err
+		}
+		spec.DeviceChange = changes
+	}
+
+	task, err := vm.Reconfigure(ctx, spec)
+	if err != nil {
+		return err
+	}
+
+	return task.Wait(ctx)
+}
+
+func diskChanges(ctx context.Context, vm *object.VirtualMachine, disks []types.VMHardwareDisk, diskProvisioning string) ([]vmware_types.BaseVirtualDeviceConfigSpec, error) {
+	devices, err := vm.Device(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get Virtual Machine devices")
+	}
+
+	existing := devices.SelectByType((*vmware_types.VirtualDisk)(nil))
+
+	var changes []vmware_types.BaseVirtualDeviceConfigSpec
+	for _, d := range disks {
+		capacityKB := d.SizeGB * 1024 * 1024
+
+		if !d.IsNew() {
+			if d.Index >= len(existing) {
+				return nil, fmt.Errorf("Virtual Machine has %d disks. Could not resize disk with index %d", len(existing), d.Index) //nolint: stylecheck,golint
+			}
+
+			disk := existing[d.Index].(*vmware_types.VirtualDisk)
+			if capacityKB < disk.CapacityInKB {
+				return nil, fmt.Errorf("could not shrink disk with index %d from %d GB to %d GB", d.Index, disk.CapacityInKB/1024/1024, d.SizeGB)
+			}
+
+			disk.CapacityInKB = capacityKB
+			disk.CapacityInBytes = 0
+			changes = append(changes, &vmware_types.VirtualDeviceConfigSpec{
+				Operation: vmware_types.VirtualDeviceConfigSpecOperationEdit,
+				Device:    disk,
+			})
+			continue
+		}
+
+		controller, err := devices.FindDiskController("")
+		if err != nil {
+			return nil, errors.Wrap(err, "could not find disk controller for a new disk")
+		}
+
+		var o mo.VirtualMachine
+		if err := vm.Properties(ctx, vm.Reference(), []string{"datastore"}, &o); err != nil {
+			return nil, errors.Wrap(err, "could not get Virtual Machine datastore for a new disk")
+		}
+		if len(o.Datastore) == 0 {
+			return nil, errors.New("Virtual Machine does not have a datastore for a new disk") //nolint: stylecheck,golint
+		}
+
+		disk := devices.CreateDisk(controller, o.Datastore[0], "")
+		if disk.UnitNumber == nil || *disk.UnitNumber < 0 {
+			return nil, errors.New("disk controller does not have free slots for a new disk")
+		}
+		disk.Key = devices.NewKey()
+		disk.CapacityInKB = capacityKB
+
+		backing := disk.Backing.(*vmware_types.VirtualDiskFlatVer2BackingInfo)
+		switch diskProvisioning {
+		case "thick":
+			backing.ThinProvisioned = vmware_types.NewBool(false)
+		case "eagerZeroedThick":
+			backing.ThinProvisioned = vmware_types.NewBool(false)
+			backing.EagerlyScrub = vmware_types.NewBool(true)
+		}
+
+		// the next disk must get another key and unit number
+		devices = append(devices, disk)
+
+		changes = append(changes, &vmware_types.VirtualDeviceConfigSpec{
+			Operation:     vmware_types.VirtualDeviceConfigSpecOperationAdd,
+			FileOperation: vmware_types.VirtualDeviceConfigSpecFileOperationCreate,
+			Device:        disk,
+		})
+	}
+
+	return changes, nil
+}
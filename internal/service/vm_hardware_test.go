@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+	vmware_types "github.com/vmware/govmomi/vim25/types"
+
+	"github.com/vterdunov/janna-api/internal/types"
+)
+
+// testVMDisks returns the disks of the Virtual Machine in the order of the devices
+func testVMDisks(t *testing.T, vm *object.VirtualMachine) []*vmware_types.VirtualDisk {
+	t.Helper()
+
+	devices, err := vm.Device(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var disks []*vmware_types.VirtualDisk
+	for _, d := range devices.SelectByType((*vmware_types.VirtualDisk)(nil)) {
+		disks = append(disks, d.(*vmware_types.VirtualDisk))
+	}
+	return disks
+}
+
+func TestReconfigureVM(t *testing.T) {
+	c, cleanup := newTestVCenter(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	vm := testVM(t, c)
+	disks := testVMDisks(t, vm)
+	if len(disks) == 0 {
+		t.Fatal("simulated Virtual Machine does not have disks")
+	}
+
+	sizeGB := disks[0].CapacityInKB/1024/1024 + 2
+	hw := &types.VMHardware{
+		NumCPU:         4,
+		CoresPerSocket: 2,
+		MemoryMB:       2048,
+		Disks: []types.VMHardwareDisk{
+			{Index: 0, SizeGB: sizeGB},
+			{Index: -1, SizeGB: 5},
+		},
+	}
+	if err := reconfigureVM(ctx, vm, hw, "thick"); err != nil {
+		t.Fatalf("reconfigureVM() error = %v", err)
+	}
+
+	var o mo.VirtualMachine
+	if err := vm.Properties(ctx, vm.Reference(), []string{"config.hardware"}, &o); err != nil {
+		t.Fatal(err)
+	}
+	got := o.Config.Hardware
+	if got.NumCPU != 4 || got.NumCoresPerSocket != 2 || got.MemoryMB != 2048 {
+		t.Errorf("reconfigureVM() hardware = %d CPU, %d cores per socket, %d MB, want 4, 2, 2048", got.NumCPU, got.NumCoresPerSocket, got.MemoryMB)
+	}
+
+	after := testVMDisks(t, vm)
+	if len(after) != len(disks)+1 {
+		t.Fatalf("reconfigureVM() Virtual Machine has %d disks, want %d", len(after), len(disks)+1)
+	}
+	if want := sizeGB * 1024 * 1024; after[0].CapacityInKB != want {
+		t.Errorf("reconfigureVM() resized disk capacity = %d KB, want %d KB", after[0].CapacityInKB, want)
+	}
+	added := after[len(after)-1]
+	if added.CapacityInKB != 5*1024*1024 {
+		t.Errorf("reconfigureVM() new disk capacity = %d KB, want %d KB", added.CapacityInKB, 5*1024*1024)
+	}
+}
+
+func TestDiskChanges(t *testing.T) {
+	tests := []struct {
+		name             string
+		disks            []types.VMHardwareDisk
+		diskProvisioning string
+		wantErr          string
+		wantOperations   []vmware_types.VirtualDeviceConfigSpecOperation
+	}{
+		{
+			name:           "resize",
+			disks:          []types.VMHardwareDisk{{Index: 0, SizeGB: 100}},
+			wantOperations: []vmware_types.VirtualDeviceConfigSpecOperation{vmware_types.VirtualDeviceConfigSpecOperationEdit},
+		},
+		{
+			name:    "shrink",
+			disks:   []types.VMHardwareDisk{{Index: 0, SizeGB: 1}},
+			wantErr: "could not shrink disk with index 0",
+		},
+		{
+			name:    "out of range",
+			disks:   []types.VMHardwareDisk{{Index: 5, SizeGB: 100}},
+			wantErr: "Could not resize disk with index 5",
+		},
+		{
+			name:             "new disks",
+			disks:            []types.VMHardwareDisk{{Index: -1, SizeGB: 5}, {Index: -1, SizeGB: 10}},
+			diskProvisioning: "eagerZeroedThick",
+			wantOperations: []vmware_types.VirtualDeviceConfigSpecOperation{
+				vmware_types.VirtualDeviceConfigSpecOperationAdd,
+				vmware_types.VirtualDeviceConfigSpecOperationAdd,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, cleanup := newTestVCenter(t)
+			defer cleanup()
+
+			vm := testVM(t, c)
+			// the simulated disk must be bigger than the shrunk one
+			if d := testVMDisks(t, vm)[0]; d.CapacityInKB < 2*1024*1024 {
+				grow := &types.VMHardware{Disks: []types.VMHardwareDisk{{Index: 0, SizeGB: 2}}}
+				if err := reconfigureVM(context.Background(), vm, grow, ""); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			changes, err := diskChanges(context.Background(), vm, tt.disks, tt.diskProvisioning)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("diskChanges() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(changes) != len(tt.wantOperations) {
+				t.Fatalf("diskChanges() returned %d changes, want %d", len(changes), len(tt.wantOperations))
+			}
+
+			keys := map[int32]bool{}
+			for i, change := range changes {
+				spec := change.GetVirtualDeviceConfigSpec()
+				if spec.Operation != tt.wantOperations[i] {
+					t.Errorf("diskChanges() change %d operation = %v, want %v", i, spec.Operation, tt.wantOperations[i])
+				}
+
+				disk := spec.Device.(*vmware_types.VirtualDisk)
+				if want := tt.disks[i].SizeGB * 1024 * 1024; disk.CapacityInKB != want {
+					t.Errorf("diskChanges() change %d capacity = %d KB, want %d KB", i, disk.CapacityInKB, want)
+				}
+
+				if spec.Operation != vmware_types.VirtualDeviceConfigSpecOperationAdd {
+					continue
+				}
+				if keys[disk.Key] {
+					t.Errorf("diskChanges() new disks share the key %d", disk.Key)
+				}
+				keys[disk.Key] = true
+
+				backing := disk.Backing.(*vmware_types.VirtualDiskFlatVer2BackingInfo)
+				if backing.Datastore == nil || *backing.ThinProvisioned || backing.EagerlyScrub == nil || !*backing.EagerlyScrub {
+					t.Errorf("diskChanges() new disk backing = %+v, want eager zeroed thick disk on the datastore", backing)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
+
+	"github.com/vterdunov/janna-api/internal/domain"
+	"github.com/vterdunov/janna-api/internal/types"
+)
+
+// Webhook request headers
+const (
+	// WebhookSignatureHeader keeps HMAC-SHA256 of the request body signed with the callback secret,
+	// in the 'sha256=<hex>' format. It is sent only when the secret is set
+	WebhookSignatureHeader = "X-Janna-Signature"
+	// WebhookTaskIDHeader keeps ID of the delivered task
+	WebhookTaskIDHeader = "X-Janna-Task-Id"
+)
+
+// webhookSender delivers finished tasks records to callback URLs
+type webhookSender struct {
+	client         *http.Client
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+
+	// inFlight tracks the deliveries running in the background, so shutdown can wait for them
+	inFlight sync.WaitGroup
+}
+
+func newWebhookSender() *webhookSender {
+	return &webhookSender{
+		client:         &http.Client{Timeout: 10 * time.Second},
+		maxAttempts:    5,
+		initialBackoff: 2 * time.Second,
+		maxBackoff:     time.Minute,
+	}
+}
+
+// send delivers the task record in the background
+func (w *webhookSender) send(t TaskStatuser, url, secret string, logger log.Logger) {
+	w.inFlight.Add(1)
+	go func() {
+		defer w.inFlight.Done()
+		w.deliver(context.Background(), t, url, secret, logger)
+	}()
+}
+
+// wait blocks until the background deliveries are finished or the context is done.
+// It reports whether all deliveries were finished.
+func (w *webhookSender) wait(ctx context.Context) bool {
+	done := make(chan struct{})
+	go func() {
+		w.inFlight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// deliver POSTs the finished task record to the URL, retrying with exponential backoff.
+// Every attempt is recorded in the task.
+func (w *webhookSender) deliver(ctx context.Context, t TaskStatuser, url, secret string, logger log.Logger) {
+	task := t.Get()
+	if !task.Stage.IsFinal() {
+		return
+	}
+
+	body, err := json.Marshal(types.NewTask(&task))
+	if err != nil {
+		logger.Log("err", errors.Wrap(err, "could not encode webhook payload"))
+		return
+	}
+
+	backoff := w.initialBackoff
+	for attempt := 1; attempt <= w.maxAttempts; attempt++ {
+		code, err := w.post(ctx, url, secret, task.ID, body)
+
+		a := domain.WebhookAttempt{
+			Time:       time.Now(),
+			StatusCode: code,
+		}
+		if err != nil {
+			a.Error = err.Error()
+		}
+
+		t.Update(func(task *domain.Task) {
+			if task.Webhook == nil {
+				task.Webhook = &domain.WebhookDelivery{URL: url}
+			}
+			task.Webhook.Attempts = append(task.Webhook.Attempts, a)
+			task.Webhook.Delivered = err == nil
+		})
+
+		if err == nil {
+			logger.Log("msg", "Webhook was delivered", "attempt", attempt)
+			return
+		}
+
+		logger.Log("msg", "Could not deliver webhook", "attempt", attempt, "err", err)
+		if !isRetryableStatus(code) {
+			return
+		}
+
+		if attempt == w.maxAttempts {
+			return
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+
+		backoff *= 2
+		if backoff > w.maxBackoff {
+			backoff = w.maxBackoff
+		}
+	}
+}
+
+// post returns the response status code or zero if the request has failed
+func (w *webhookSender) post(ctx context.Context, url, secret, taskID string, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req = req.WithContext(ctx)
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookTaskIDHeader, taskID)
+	if secret != "" {
+		req.Header.Set(WebhookSignatureHeader, "sha256="+signWebhook(secret, body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body) //nolint: errcheck
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status code %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// signWebhook returns hex encoded HMAC-SHA256 of the body
+func signWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body) //nolint: errcheck
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// isRetryableStatus reports whether a failed delivery is worth to retry.
+// Client errors are permanent except request timeout and rate limiting.
+func isRetryableStatus(code int) bool {
+	switch {
+	case code == 0:
+		return true
+	case code == http.StatusRequestTimeout, code == http.StatusTooManyRequests:
+		return true
+	case code >= 500:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,292 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/vterdunov/janna-api/internal/domain"
+)
+
+func TestSignWebhook(t *testing.T) {
+	// echo -n '{"id":"42"}' | openssl dgst -sha256 -hmac s3cr3t
+	want := "75b6878a82a2de384401892ed2c553cbce7c4c199956ca903ed8317e96bc7dbb"
+
+	if got := signWebhook("s3cr3t", []byte(`{"id":"42"}`)); got != want {
+		t.Errorf("signWebhook() = %v, want %v", got, want)
+	}
+}
+
+func TestIsRetryableStatus(t *testing.T) {
+	tests := []struct {
+		code int
+		want bool
+	}{
+		{0, true},
+		{http.StatusBadRequest, false},
+		{http.StatusUnauthorized, false},
+		{http.StatusNotFound, false},
+		{http.StatusRequestTimeout, true},
+		{http.StatusTooManyRequests, true},
+		{http.StatusInternalServerError, true},
+		{http.StatusBadGateway, true},
+		{http.StatusServiceUnavailable, true},
+	}
+	for _, tt := range tests {
+		if got := isRetryableStatus(tt.code); got != tt.want {
+			t.Errorf("isRetryableStatus(%d) = %v, want %v", tt.code, got, tt.want)
+		}
+	}
+}
+
+// webhookServer answers with the status codes in order and records the requests
+type webhookServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	codes    []int
+	times    []time.Time
+	bodies   [][]byte
+	requests []*http.Request
+}
+
+func newWebhookServer(codes ...int) *webhookServer {
+	s := &webhookServer{codes: codes}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		s.mu.Lock()
+		n := len(s.times)
+		s.times = append(s.times, time.Now())
+		s.bodies = append(s.bodies, body)
+		s.requests = append(s.requests, r)
+		s.mu.Unlock()
+
+		code := http.StatusNoContent
+		if n < len(s.codes) {
+			code = s.codes[n]
+		}
+		w.WriteHeader(code)
+	}))
+	return s
+}
+
+func newTestWebhookSender() *webhookSender {
+	return &webhookSender{
+		client:         &http.Client{Timeout: time.Second},
+		maxAttempts:    4,
+		initialBackoff: 10 * time.Millisecond,
+		maxBackoff:     20 * time.Millisecond,
+	}
+}
+
+func newFinishedTestTask() *testTask {
+	t := newTestTask("42")
+	t.Update(func(task *domain.Task) {
+		task.SetStage(domain.TaskStageComplete)
+	})
+	return t
+}
+
+func attemptCodes(task domain.Task) []int {
+	var codes []int
+	for _, a := range task.Webhook.Attempts {
+		codes = append(codes, a.StatusCode)
+	}
+	return codes
+}
+
+func TestWebhookSender_Deliver(t *testing.T) {
+	tests := []struct {
+		name          string
+		codes         []int
+		wantAttempts  []int
+		wantDelivered bool
+	}{
+		{"first attempt", nil, []int{204}, true},
+		{"retry on 5xx", []int{500, 503}, []int{500, 503, 204}, true},
+		{"retry on rate limit", []int{429}, []int{429, 204}, true},
+		{"no retry on 4xx", []int{400}, []int{400}, false},
+		{"give up after max attempts", []int{500, 500, 500, 500, 500}, []int{500, 500, 500, 500}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newWebhookServer(tt.codes...)
+			defer srv.Close()
+
+			task := newFinishedTestTask()
+			newTestWebhookSender().deliver(context.Background(), task, srv.URL, "s3cr3t", log.NewNopLogger())
+
+			got := task.Get()
+			if got.Webhook == nil {
+				t.Fatal("deliver() did not record the delivery")
+			}
+			if got.Webhook.URL != srv.URL || got.Webhook.Delivered != tt.wantDelivered {
+				t.Errorf("deliver() webhook = %+v, want delivered %v", got.Webhook, tt.wantDelivered)
+			}
+			if codes := attemptCodes(got); !equalInts(codes, tt.wantAttempts) {
+				t.Errorf("deliver() attempts status codes = %v, want %v", codes, tt.wantAttempts)
+			}
+			for i, a := range got.Webhook.Attempts {
+				failed := a.StatusCode < 200 || a.StatusCode > 299
+				if failed != (a.Error != "") {
+					t.Errorf("deliver() attempt %d = %+v, error must be set for failed attempts only", i, a)
+				}
+			}
+		})
+	}
+}
+
+func TestWebhookSender_DeliverRequest(t *testing.T) {
+	srv := newWebhookServer()
+	defer srv.Close()
+
+	task := newFinishedTestTask()
+	newTestWebhookSender().deliver(context.Background(), task, srv.URL, "s3cr3t", log.NewNopLogger())
+
+	if len(srv.requests) != 1 {
+		t.Fatalf("deliver() sent %d requests, want 1", len(srv.requests))
+	}
+
+	r, body := srv.requests[0], srv.bodies[0]
+	if got := r.Header.Get(WebhookTaskIDHeader); got != "42" {
+		t.Errorf("deliver() %s = %v, want 42", WebhookTaskIDHeader, got)
+	}
+	if got, want := r.Header.Get(WebhookSignatureHeader), "sha256="+signWebhook("s3cr3t", body); got != want {
+		t.Errorf("deliver() %s = %v, want %v", WebhookSignatureHeader, got, want)
+	}
+
+	var payload struct {
+		ID    string `json:"id"`
+		Stage string `json:"stage"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil || payload.ID != "42" || payload.Stage != "complete" {
+		t.Errorf("deliver() payload = %s, err %v", body, err)
+	}
+}
+
+func TestWebhookSender_DeliverWithoutSecret(t *testing.T) {
+	srv := newWebhookServer()
+	defer srv.Close()
+
+	newTestWebhookSender().deliver(context.Background(), newFinishedTestTask(), srv.URL, "", log.NewNopLogger())
+
+	if got := srv.requests[0].Header.Get(WebhookSignatureHeader); got != "" {
+		t.Errorf("deliver() sent %s = %v without a secret", WebhookSignatureHeader, got)
+	}
+}
+
+func TestWebhookSender_DeliverBackoff(t *testing.T) {
+	srv := newWebhookServer(500, 500, 500)
+	defer srv.Close()
+
+	w := newTestWebhookSender()
+	w.initialBackoff = 40 * time.Millisecond
+	w.maxBackoff = 50 * time.Millisecond
+	w.deliver(context.Background(), newFinishedTestTask(), srv.URL, "", log.NewNopLogger())
+
+	if len(srv.times) != 4 {
+		t.Fatalf("deliver() sent %d requests, want 4", len(srv.times))
+	}
+
+	// 40ms, then doubled but capped by 50ms. Without the cap the last backoff would be 160ms
+	want := []time.Duration{40 * time.Millisecond, 50 * time.Millisecond, 50 * time.Millisecond}
+	for i, min := range want {
+		gap := srv.times[i+1].Sub(srv.times[i])
+		if gap < min || gap >= min+100*time.Millisecond {
+			t.Errorf("deliver() backoff before attempt %d = %v, want about %v", i+2, gap, min)
+		}
+	}
+}
+
+func TestWebhookSender_DeliverConnectionError(t *testing.T) {
+	srv := newWebhookServer()
+	url := srv.URL
+	srv.Close()
+
+	task := newFinishedTestTask()
+	newTestWebhookSender().deliver(context.Background(), task, url, "", log.NewNopLogger())
+
+	got := task.Get().Webhook
+	if got == nil || got.Delivered || len(got.Attempts) != 4 {
+		t.Fatalf("deliver() webhook = %+v, want 4 failed attempts", got)
+	}
+	for i, a := range got.Attempts {
+		if a.StatusCode != 0 || a.Error == "" {
+			t.Errorf("deliver() attempt %d = %+v, want connection error", i, a)
+		}
+	}
+}
+
+func TestWebhookSender_DeliverCancelled(t *testing.T) {
+	srv := newWebhookServer(500, 500, 500)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	w := newTestWebhookSender()
+	w.initialBackoff = time.Hour
+	task := newFinishedTestTask()
+	w.deliver(ctx, task, srv.URL, "", log.NewNopLogger())
+
+	if got := task.Get().Webhook; got == nil || len(got.Attempts) != 1 {
+		t.Errorf("deliver() webhook = %+v, want a single attempt after the context is cancelled", got)
+	}
+}
+
+func TestWebhookSender_DeliverUnfinished(t *testing.T) {
+	srv := newWebhookServer()
+	defer srv.Close()
+
+	task := newTestTask("42")
+	newTestWebhookSender().deliver(context.Background(), task, srv.URL, "", log.NewNopLogger())
+
+	if len(srv.requests) != 0 || task.Get().Webhook != nil {
+		t.Error("deliver() sent the webhook of an unfinished task")
+	}
+}
+
+func TestWebhookSender_Wait(t *testing.T) {
+	srv := newWebhookServer(500, 500)
+	defer srv.Close()
+
+	w := newTestWebhookSender()
+	w.initialBackoff = 50 * time.Millisecond
+	w.maxBackoff = 50 * time.Millisecond
+	task := newFinishedTestTask()
+	w.send(task, srv.URL, "", log.NewNopLogger())
+
+	// the delivery is retried in the background
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if w.wait(ctx) {
+		t.Error("wait() = true before the delivery is finished")
+	}
+
+	if !w.wait(context.Background()) {
+		t.Fatal("wait() = false, want true")
+	}
+	if got := task.Get().Webhook; got == nil || !got.Delivered {
+		t.Errorf("wait() returned before the webhook was delivered: %+v", got)
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,54 @@
+package status
+
+import (
+	"sort"
+
+	"github.com/vterdunov/janna-api/internal/domain"
+	"github.com/vterdunov/janna-api/internal/types"
+)
+
+// match reports whether a task satisfies the list params
+func match(params *types.TasksListParams, t *domain.Task) bool {
+	if params.Stage != "" && string(t.Stage) != params.Stage {
+		return false
+	}
+
+	if params.VMName != "" && t.VMName != params.VMName {
+		return false
+	}
+
+	if params.RequestID != "" && t.RequestID != params.RequestID {
+		return false
+	}
+
+	if !params.CreatedAfter.IsZero() && t.CreatedAt.Before(params.CreatedAfter) {
+		return false
+	}
+
+	if !params.CreatedBefore.IsZero() && t.CreatedAt.After(params.CreatedBefore) {
+		return false
+	}
+
+	return true
+}
+
+// paginate sorts tasks from newest to oldest and cuts a page according to offset and limit
+func paginate(tasks []domain.Task, offset, limit int) []domain.Task {
+	sort.Slice(tasks, func(i, j int) bool {
+		if tasks[i].CreatedAt.Equal(tasks[j].CreatedAt) {
+			return tasks[i].ID < tasks[j].ID
+		}
+		return tasks[i].CreatedAt.After(tasks[j].CreatedAt)
+	})
+
+	if offset >= len(tasks) {
+		return []domain.Task{}
+	}
+	tasks = tasks[offset:]
+
+	if limit > 0 && limit < len(tasks) {
+		tasks = tasks[:limit]
+	}
+
+	return tasks
+}
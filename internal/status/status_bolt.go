@@ -0,0 +1,284 @@
+package status
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/vterdunov/janna-api/internal/domain"
+	"github.com/vterdunov/janna-api/internal/service"
+	"github.com/vterdunov/janna-api/internal/types"
+	"github.com/vterdunov/janna-api/pkg/uuid"
+)
+
+var (
+	metaBucket  = []byte("meta")
+	tasksBucket = []byte("tasks")
+
+	schemaVersionKey = []byte("schema_version")
+)
+
+// BoltStorage stores tasks statuses in a bolt database file
+type BoltStorage struct {
+	db     *bolt.DB
+	logger log.Logger
+	broker *broker
+
+	cleanInterval     time.Duration
+	defaultExpiration time.Duration
+
+	done chan struct{}
+}
+
+// BoltTaskStatus keep status messages of a task stored in bolt database
+type BoltTaskStatus struct {
+	id      string
+	storage *BoltStorage
+}
+
+// NewBoltStorage opens or creates bolt database file and migrates it to the current schema version
+func NewBoltStorage(path string, logger log.Logger) (*BoltStorage, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not open tasks database")
+	}
+
+	if err := db.Update(migrate); err != nil {
+		db.Close()
+		return nil, errors.Wrap(err, "could not migrate tasks database")
+	}
+
+	s := BoltStorage{
+		db:                db,
+		logger:            logger,
+		broker:            newBroker(),
+		cleanInterval:     time.Second * 10,
+		defaultExpiration: time.Hour * 24,
+		done:              make(chan struct{}),
+	}
+
+	go s.gc()
+
+	return &s, nil
+}
+
+func migrate(tx *bolt.Tx) error {
+	meta, err := tx.CreateBucketIfNotExists(metaBucket)
+	if err != nil {
+		return err
+	}
+
+	current := 0
+	if v := meta.Get(schemaVersionKey); v != nil {
+		if err := json.Unmarshal(v, &current); err != nil {
+			return errors.Wrap(err, "could not read schema version")
+		}
+	}
+
+	if current > schemaVersion {
+		return fmt.Errorf("database schema version %d is newer than supported %d", current, schemaVersion)
+	}
+
+	for v := current; v < schemaVersion; v++ {
+		if err := migrations[v](tx); err != nil {
+			return errors.Wrapf(err, "could not migrate schema from version %d", v)
+		}
+	}
+
+	return meta.Put(schemaVersionKey, []byte(fmt.Sprint(schemaVersion)))
+}
+
+// Close stops garbage collector and closes database file
+func (s *BoltStorage) Close() error {
+	close(s.done)
+	return s.db.Close()
+}
+
+// NewTask creates a new unique status for a task
+func (s *BoltStorage) NewTask(kind domain.TaskKind) service.TaskStatuser {
+	now := time.Now()
+	r := boltRecord{
+		Expiration: now.Add(s.defaultExpiration).UnixNano(),
+		Task: taskRecord{
+			ID:        uuid.NewUUID(),
+			Kind:      string(kind),
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+	}
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return putRecord(tx, &r)
+	})
+	if err != nil {
+		s.logger.Log("err", errors.Wrap(err, "could not save task"), "task_id", r.Task.ID)
+	}
+
+	return &BoltTaskStatus{id: r.Task.ID, storage: s}
+}
+
+// FindByID returns task by its ID or nil if the task does not exist
+func (s *BoltStorage) FindByID(id string) service.TaskStatuser {
+	err := s.db.View(func(tx *bolt.Tx) error {
+		_, err := getRecord(tx, id)
+		return err
+	})
+	if err != nil {
+		return nil
+	}
+
+	return &BoltTaskStatus{id: id, storage: s}
+}
+
+// FindAll returns a page of tasks that match the params and the total number of matched tasks
+func (s *BoltStorage) FindAll(params *types.TasksListParams) ([]domain.Task, int) {
+	found := []domain.Task{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(tasksBucket).ForEach(func(_, v []byte) error {
+			var r boltRecord
+			if err := json.Unmarshal(v, &r); err != nil {
+				return err
+			}
+
+			t := r.Task.domain()
+			if match(params, &t) {
+				found = append(found, t)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		s.logger.Log("err", errors.Wrap(err, "could not read tasks"))
+		return []domain.Task{}, 0
+	}
+
+	return paginate(found, params.Offset, params.Limit), len(found)
+}
+
+// Subscribe returns a channel that receives the task record after every update
+func (s *BoltStorage) Subscribe(id string) (<-chan domain.Task, func()) {
+	return s.broker.subscribe(id)
+}
+
+// ID returns task ID
+func (t *BoltTaskStatus) ID() string {
+	return t.id
+}
+
+// Update changes the task record and saves it to the database
+func (t *BoltTaskStatus) Update(fn func(task *domain.Task)) service.TaskStatuser {
+	err := t.storage.db.Update(func(tx *bolt.Tx) error {
+		r, err := getRecord(tx, t.id)
+		if err != nil {
+			return err
+		}
+
+		task := r.Task.domain()
+		fn(&task)
+		task.UpdatedAt = time.Now()
+		r.Task = newTaskRecord(&task)
+
+		if err := putRecord(tx, r); err != nil {
+			return err
+		}
+
+		// publish while holding the write transaction, so subscribers get updates in order
+		t.storage.broker.publish(task)
+		return nil
+	})
+	if err != nil {
+		t.storage.logger.Log("err", errors.Wrap(err, "could not update task"), "task_id", t.id)
+	}
+
+	return t
+}
+
+// Get returns the task record
+func (t *BoltTaskStatus) Get() domain.Task {
+	var r *boltRecord
+	err := t.storage.db.View(func(tx *bolt.Tx) error {
+		var err error
+		r, err = getRecord(tx, t.id)
+		return err
+	})
+	if err != nil {
+		t.storage.logger.Log("err", errors.Wrap(err, "could not read task"), "task_id", t.id)
+		return domain.Task{ID: t.id}
+	}
+
+	return r.Task.domain()
+}
+
+func getRecord(tx *bolt.Tx, id string) (*boltRecord, error) {
+	data := tx.Bucket(tasksBucket).Get([]byte(id))
+	if data == nil {
+		return nil, errors.New("task not found")
+	}
+
+	var r boltRecord
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+func putRecord(tx *bolt.Tx, r *boltRecord) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	return tx.Bucket(tasksBucket).Put([]byte(r.Task.ID), data)
+}
+
+// gc search and clean expired tasks from the database
+func (s *BoltStorage) gc() {
+	ticker := time.NewTicker(s.cleanInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			if err := s.deleteExpired(time.Now()); err != nil {
+				s.logger.Log("err", errors.Wrap(err, "could not clean expired tasks"))
+			}
+		}
+	}
+}
+
+func (s *BoltStorage) deleteExpired(now time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(tasksBucket)
+
+		var expired [][]byte
+		err := b.ForEach(func(k, v []byte) error {
+			var r boltRecord
+			if err := json.Unmarshal(v, &r); err != nil {
+				return err
+			}
+
+			if now.UnixNano() > r.Expiration {
+				expired = append(expired, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, k := range expired {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,337 @@
+package status
+
+import (
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/vterdunov/janna-api/internal/domain"
+)
+
+// schemaVersion is a version of the on-disk layout that this code writes.
+// Increase it and append a step to migrations when the layout changes.
+// A new optional field, one with 'omitempty' that has a meaningful zero value, is not a layout change:
+// old records are read with the zero value and old code ignores the field. Renamed, removed or retyped
+// fields and new fields that every record must have need a new version and a migration.
+const schemaVersion = 2
+
+// migrations upgrade the database from version i to version i+1
+var migrations = []func(tx *bolt.Tx) error{
+	// 0 -> 1. Initial layout
+	func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(tasksBucket)
+		return err
+	},
+	// 1 -> 2. Free-form status map was replaced by typed task record
+	migrateTypedRecord,
+}
+
+// boltRecord is a task representation on the disk
+type boltRecord struct {
+	Expiration int64      `json:"expiration"`
+	Task       taskRecord `json:"task"`
+}
+
+type taskRecord struct {
+	ID            string               `json:"id"`
+	Kind          string               `json:"kind"`
+	Stage         string               `json:"stage"`
+	Message       string               `json:"message,omitempty"`
+	VMName        string               `json:"vm_name,omitempty"`
+	RequestID     string               `json:"request_id,omitempty"`
+	CreatedAt     time.Time            `json:"created_at"`
+	UpdatedAt     time.Time            `json:"updated_at"`
+	StageTimes    map[string]time.Time `json:"stage_times,omitempty"`
+	Progress      int                  `json:"progress"`
+	QueuePosition int                  `json:"queue_position,omitempty"`
+	Interrupted   bool                 `json:"interrupted,omitempty"`
+	OVACache      string               `json:"ova_cache,omitempty"`
+	Upload        *uploadRecord        `json:"upload,omitempty"`
+	Error         *taskErrorRecord     `json:"error,omitempty"`
+	Result        taskResultRecord     `json:"result"`
+	Webhook       *webhookRecord       `json:"webhook,omitempty"`
+	Cleanup       *cleanupRecord       `json:"cleanup,omitempty"`
+}
+
+type cleanupRecord struct {
+	Policy string `json:"policy"`
+	Action string `json:"action,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type uploadRecord struct {
+	Files            []fileRecord  `json:"files"`
+	TotalBytes       int64         `json:"total_bytes"`
+	TransferredBytes int64         `json:"transferred_bytes"`
+	Percentage       float32       `json:"percentage"`
+	ETA              time.Duration `json:"eta"`
+}
+
+type fileRecord struct {
+	Path             string  `json:"path"`
+	TotalBytes       int64   `json:"total_bytes"`
+	TransferredBytes int64   `json:"transferred_bytes"`
+	Percentage       float32 `json:"percentage"`
+}
+
+type webhookRecord struct {
+	URL       string                 `json:"url"`
+	Delivered bool                   `json:"delivered"`
+	Attempts  []webhookAttemptRecord `json:"attempts,omitempty"`
+}
+
+type webhookAttemptRecord struct {
+	Time       time.Time `json:"time"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+type taskErrorRecord struct {
+	Stage   string `json:"stage"`
+	Kind    string `json:"kind,omitempty"`
+	Message string `json:"message"`
+}
+
+type taskResultRecord struct {
+	VMUUID           string          `json:"vm_uuid,omitempty"`
+	IPs              []string        `json:"ips,omitempty"`
+	DiskProvisioning string          `json:"disk_provisioning,omitempty"`
+	Networks         []networkRecord `json:"networks,omitempty"`
+}
+
+type networkRecord struct {
+	Name    string `json:"name"`
+	Network string `json:"network"`
+	Kind    string `json:"kind"`
+}
+
+func newTaskRecord(t *domain.Task) taskRecord {
+	r := taskRecord{
+		ID:            t.ID,
+		Kind:          string(t.Kind),
+		Stage:         string(t.Stage),
+		Message:       t.Message,
+		VMName:        t.VMName,
+		RequestID:     t.RequestID,
+		CreatedAt:     t.CreatedAt,
+		UpdatedAt:     t.UpdatedAt,
+		Progress:      t.Progress,
+		QueuePosition: t.QueuePosition,
+		Interrupted:   t.Interrupted,
+		OVACache:      string(t.OVACache),
+		Result: taskResultRecord{
+			VMUUID:           t.Result.VMUUID,
+			IPs:              t.Result.IPs,
+			DiskProvisioning: t.Result.DiskProvisioning,
+		},
+	}
+
+	for _, n := range t.Result.Networks {
+		r.Result.Networks = append(r.Result.Networks, networkRecord{
+			Name:    n.Name,
+			Network: n.Network,
+			Kind:    string(n.Kind),
+		})
+	}
+
+	if len(t.StageTimes) != 0 {
+		r.StageTimes = make(map[string]time.Time, len(t.StageTimes))
+		for k, v := range t.StageTimes {
+			r.StageTimes[string(k)] = v
+		}
+	}
+
+	if t.Upload != nil {
+		r.Upload = &uploadRecord{
+			TotalBytes:       t.Upload.TotalBytes,
+			TransferredBytes: t.Upload.TransferredBytes,
+			Percentage:       t.Upload.Percentage,
+			ETA:              t.Upload.ETA,
+		}
+		for _, f := range t.Upload.Files {
+			r.Upload.Files = append(r.Upload.Files, fileRecord(f))
+		}
+	}
+
+	if t.Error != nil {
+		r.Error = &taskErrorRecord{
+			Stage:   string(t.Error.Stage),
+			Kind:    string(t.Error.Kind),
+			Message: t.Error.Message,
+		}
+	}
+
+	if t.Cleanup != nil {
+		r.Cleanup = &cleanupRecord{
+			Policy: string(t.Cleanup.Policy),
+			Action: string(t.Cleanup.Action),
+			Error:  t.Cleanup.Error,
+		}
+	}
+
+	if t.Webhook != nil {
+		r.Webhook = &webhookRecord{
+			URL:       t.Webhook.URL,
+			Delivered: t.Webhook.Delivered,
+		}
+		for _, a := range t.Webhook.Attempts {
+			r.Webhook.Attempts = append(r.Webhook.Attempts, webhookAttemptRecord(a))
+		}
+	}
+
+	return r
+}
+
+func (r *taskRecord) domain() domain.Task {
+	t := domain.Task{
+		ID:            r.ID,
+		Kind:          domain.TaskKind(r.Kind),
+		Stage:         domain.TaskStage(r.Stage),
+		Message:       r.Message,
+		VMName:        r.VMName,
+		RequestID:     r.RequestID,
+		CreatedAt:     r.CreatedAt,
+		UpdatedAt:     r.UpdatedAt,
+		Progress:      r.Progress,
+		QueuePosition: r.QueuePosition,
+		Interrupted:   r.Interrupted,
+		OVACache:      domain.OVACacheStatus(r.OVACache),
+		Result: domain.TaskResult{
+			VMUUID:           r.Result.VMUUID,
+			IPs:              r.Result.IPs,
+			DiskProvisioning: r.Result.DiskProvisioning,
+		},
+	}
+
+	for _, n := range r.Result.Networks {
+		t.Result.Networks = append(t.Result.Networks, domain.NetworkMapping{
+			Name:    n.Name,
+			Network: n.Network,
+			Kind:    domain.NetworkKind(n.Kind),
+		})
+	}
+
+	if len(r.StageTimes) != 0 {
+		t.StageTimes = make(map[domain.TaskStage]time.Time, len(r.StageTimes))
+		for k, v := range r.StageTimes {
+			t.StageTimes[domain.TaskStage(k)] = v
+		}
+	}
+
+	if r.Upload != nil {
+		t.Upload = &domain.UploadProgress{
+			TotalBytes:       r.Upload.TotalBytes,
+			TransferredBytes: r.Upload.TransferredBytes,
+			Percentage:       r.Upload.Percentage,
+			ETA:              r.Upload.ETA,
+		}
+		for _, f := range r.Upload.Files {
+			t.Upload.Files = append(t.Upload.Files, domain.FileProgress(f))
+		}
+	}
+
+	if r.Error != nil {
+		t.Error = &domain.TaskError{
+			Stage:   domain.TaskStage(r.Error.Stage),
+			Kind:    domain.TaskErrorKind(r.Error.Kind),
+			Message: r.Error.Message,
+		}
+	}
+
+	if r.Cleanup != nil {
+		t.Cleanup = &domain.TaskCleanup{
+			Policy: domain.CleanupPolicy(r.Cleanup.Policy),
+			Action: domain.CleanupAction(r.Cleanup.Action),
+			Error:  r.Cleanup.Error,
+		}
+	}
+
+	if r.Webhook != nil {
+		t.Webhook = &domain.WebhookDelivery{
+			URL:       r.Webhook.URL,
+			Delivered: r.Webhook.Delivered,
+		}
+		for _, a := range r.Webhook.Attempts {
+			t.Webhook.Attempts = append(t.Webhook.Attempts, domain.WebhookAttempt(a))
+		}
+	}
+
+	return t
+}
+
+// boltRecordV1 is a task representation on the disk in schema version 1
+type boltRecordV1 struct {
+	ID         string                 `json:"id"`
+	Created    time.Time              `json:"created"`
+	Expiration int64                  `json:"expiration"`
+	Status     map[string]interface{} `json:"status"`
+}
+
+func migrateTypedRecord(tx *bolt.Tx) error {
+	b := tx.Bucket(tasksBucket)
+
+	converted := make(map[string][]byte)
+	err := b.ForEach(func(k, v []byte) error {
+		var old boltRecordV1
+		if err := json.Unmarshal(v, &old); err != nil {
+			return err
+		}
+
+		str := func(key string) string {
+			s, _ := old.Status[key].(string)
+			return s
+		}
+
+		r := boltRecord{
+			Expiration: old.Expiration,
+			Task: taskRecord{
+				ID:        old.ID,
+				Kind:      string(domain.TaskKindVMDeploy),
+				Stage:     str("stage"),
+				Message:   str("message"),
+				VMName:    str("vm"),
+				RequestID: str("request_id"),
+				CreatedAt: old.Created,
+				UpdatedAt: old.Created,
+			},
+		}
+
+		if msg := str("error"); msg != "" {
+			r.Task.Error = &taskErrorRecord{
+				Stage:   str("stage"),
+				Message: msg,
+			}
+		}
+
+		if ips, ok := old.Status["ip"].([]interface{}); ok {
+			for _, ip := range ips {
+				if s, ok := ip.(string); ok {
+					r.Task.Result.IPs = append(r.Task.Result.IPs, s)
+				}
+			}
+		}
+
+		if r.Task.Stage == string(domain.TaskStageComplete) {
+			r.Task.Progress = 100
+		}
+
+		data, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		converted[string(k)] = data
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for k, v := range converted {
+		if err := b.Put([]byte(k), v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
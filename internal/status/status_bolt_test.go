@@ -0,0 +1,250 @@
+package status
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/vterdunov/janna-api/internal/domain"
+)
+
+func newTestBoltStorage(t *testing.T) (*BoltStorage, func()) {
+	dir, err := ioutil.TempDir("", "janna-status")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewBoltStorage(filepath.Join(dir, "tasks.db"), log.NewNopLogger())
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	return s, func() {
+		s.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestBoltStorage_FindByID(t *testing.T) {
+	st, cleanup := newTestBoltStorage(t)
+	defer cleanup()
+
+	task := st.NewTask(domain.TaskKindVMDeploy)
+
+	tests := []struct {
+		name  string
+		id    string
+		found bool
+	}{
+		{"existing", task.ID(), true},
+		{"missing", "not-exist", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := st.FindByID(tt.id); (got != nil) != tt.found {
+				t.Errorf("BoltStorage.FindByID() = %v, found %v", got, tt.found)
+			}
+		})
+	}
+}
+
+func TestBoltTaskStatus_Get(t *testing.T) {
+	st, cleanup := newTestBoltStorage(t)
+	defer cleanup()
+
+	completeTask := st.NewTask(domain.TaskKindVMDeploy)
+	completeTask.Update(func(task *domain.Task) {
+		task.SetStage(domain.TaskStageComplete)
+		task.Result.IPs = []string{"10.0.0.1", "10.0.0.2"}
+	})
+
+	failedTask := st.NewTask(domain.TaskKindVMDeploy)
+	failedTask.Update(func(task *domain.Task) {
+		task.SetStage(domain.TaskStageImport)
+		task.Fail(errors.New("boom"))
+	})
+
+	integrityTask := st.NewTask(domain.TaskKindVMDeploy)
+	integrityTask.Update(func(task *domain.Task) {
+		task.SetStage(domain.TaskStageImport)
+		task.Fail(&domain.IntegrityError{Message: "boom"})
+	})
+
+	tests := []struct {
+		name      string
+		t         *BoltTaskStatus
+		wantStage domain.TaskStage
+		wantError *domain.TaskError
+		wantIPs   []string
+	}{
+		{"complete", completeTask.(*BoltTaskStatus), domain.TaskStageComplete, nil, []string{"10.0.0.1", "10.0.0.2"}},
+		{"failed", failedTask.(*BoltTaskStatus), domain.TaskStageError, &domain.TaskError{Stage: domain.TaskStageImport, Message: "boom"}, nil},
+		{"integrity", integrityTask.(*BoltTaskStatus), domain.TaskStageError, &domain.TaskError{Stage: domain.TaskStageImport, Kind: domain.TaskErrorKindIntegrity, Message: "integrity check failed: boom"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.t.Get()
+			if got.Kind != domain.TaskKindVMDeploy || got.Stage != tt.wantStage || !reflect.DeepEqual(got.Error, tt.wantError) || !reflect.DeepEqual(got.Result.IPs, tt.wantIPs) {
+				t.Errorf("BoltTaskStatus.Get() = %+v, want stage %v, error %v, ips %v", got, tt.wantStage, tt.wantError, tt.wantIPs)
+			}
+			if _, ok := got.StageTimes[tt.wantStage]; !ok {
+				t.Errorf("BoltTaskStatus.Get() has no time for stage %v", tt.wantStage)
+			}
+		})
+	}
+}
+
+func TestBoltStorage_Subscribe(t *testing.T) {
+	st, cleanup := newTestBoltStorage(t)
+	defer cleanup()
+
+	task := st.NewTask(domain.TaskKindVMDeploy)
+	updates, unsubscribe := st.Subscribe(task.ID())
+	defer unsubscribe()
+
+	task.Update(func(t *domain.Task) {
+		t.SetStage(domain.TaskStageComplete)
+		t.Result.IPs = []string{"10.0.0.1"}
+	})
+
+	select {
+	case got := <-updates:
+		if got.Stage != domain.TaskStageComplete || !reflect.DeepEqual(got.Result.IPs, []string{"10.0.0.1"}) {
+			t.Errorf("BoltStorage.Subscribe() got %+v", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("BoltStorage.Subscribe() did not receive the update")
+	}
+}
+
+func TestBoltStorage_Reopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "janna-status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "tasks.db")
+
+	st, err := NewBoltStorage(path, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := st.NewTask(domain.TaskKindVMDeploy).Update(func(task *domain.Task) {
+		task.SetStage(domain.TaskStageImport)
+	}).ID()
+	st.Close()
+
+	st, err = NewBoltStorage(path, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	task := st.FindByID(id)
+	if task == nil {
+		t.Fatal("task was lost after reopening the storage")
+	}
+
+	if got := task.Get().Stage; got != domain.TaskStageImport {
+		t.Errorf("BoltTaskStatus.Get().Stage = %v, want %v", got, domain.TaskStageImport)
+	}
+}
+
+func TestBoltStorage_migrateTypedRecord(t *testing.T) {
+	dir, err := ioutil.TempDir("", "janna-status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "tasks.db")
+
+	// prepare a database in schema version 1
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists(metaBucket)
+		if err != nil {
+			return err
+		}
+		if err = meta.Put(schemaVersionKey, []byte("1")); err != nil {
+			return err
+		}
+
+		tasks, err := tx.CreateBucketIfNotExists(tasksBucket)
+		if err != nil {
+			return err
+		}
+		old := `{"id":"task1","created":"2019-01-02T03:04:05Z","expiration":1,` +
+			`"status":{"stage":"complete","message":"ok","vm":"vm1","ip":["10.0.0.1"]}}`
+		return tasks.Put([]byte("task1"), []byte(old))
+	})
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := NewBoltStorage(path, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	task := st.FindByID("task1")
+	if task == nil {
+		t.Fatal("task was lost after migration")
+	}
+
+	got := task.Get()
+	want := domain.Task{
+		ID:        "task1",
+		Kind:      domain.TaskKindVMDeploy,
+		Stage:     domain.TaskStageComplete,
+		Message:   "ok",
+		VMName:    "vm1",
+		CreatedAt: time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC),
+		UpdatedAt: time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC),
+		Progress:  100,
+		Result:    domain.TaskResult{IPs: []string{"10.0.0.1"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("migrated task = %+v, want %+v", got, want)
+	}
+}
+
+func TestBoltStorage_deleteExpired(t *testing.T) {
+	st, cleanup := newTestBoltStorage(t)
+	defer cleanup()
+
+	id := st.NewTask(domain.TaskKindVMDeploy).ID()
+
+	tests := []struct {
+		name  string
+		now   time.Time
+		found bool
+	}{
+		{"not expired", time.Now(), true},
+		{"expired", time.Now().Add(st.defaultExpiration + time.Minute), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := st.deleteExpired(tt.now); err != nil {
+				t.Fatal(err)
+			}
+			if got := st.FindByID(id); (got != nil) != tt.found {
+				t.Errorf("BoltStorage.FindByID() = %v, found %v", got, tt.found)
+			}
+		})
+	}
+}
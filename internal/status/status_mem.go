@@ -3,11 +3,12 @@
 package status
 
 import (
-	"fmt"
 	"sync"
 	"time"
 
+	"github.com/vterdunov/janna-api/internal/domain"
 	"github.com/vterdunov/janna-api/internal/service"
+	"github.com/vterdunov/janna-api/internal/types"
 	"github.com/vterdunov/janna-api/pkg/uuid"
 )
 
@@ -17,16 +18,16 @@ type Storage struct {
 	cleanInterval     time.Duration
 	defaultExpiration time.Duration
 
-	tasks map[string]*TaskStatus
+	tasks  map[string]*TaskStatus
+	broker *broker
 }
 
-// TaskStatus keep status messages and other metadata of task
+// TaskStatus keep status and other metadata of task
 type TaskStatus struct {
 	sync.RWMutex
-	id         string
-	Status     map[string]interface{}
-	Created    time.Time
+	task       domain.Task
 	expiration int64
+	broker     *broker
 }
 
 // NewStorage creates a new in-memory storage
@@ -38,6 +39,7 @@ func NewStorage() *Storage {
 		cleanInterval:     cleanInterval,
 		defaultExpiration: expirationTime,
 		tasks:             tasks,
+		broker:            newBroker(),
 	}
 
 	go s.gc()
@@ -46,16 +48,19 @@ func NewStorage() *Storage {
 }
 
 // NewTask creates a new unique status for a task
-func (s *Storage) NewTask() service.TaskStatuser {
-
-	expiration := time.Now().Add(s.defaultExpiration).UnixNano()
+func (s *Storage) NewTask(kind domain.TaskKind) service.TaskStatuser {
+	now := time.Now()
+	expiration := now.Add(s.defaultExpiration).UnixNano()
 	uuid := uuid.NewUUID()
-	status := make(map[string]interface{})
 	r := TaskStatus{
-		id:         uuid,
-		Created:    time.Now(),
+		task: domain.Task{
+			ID:        uuid,
+			Kind:      kind,
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
 		expiration: expiration,
-		Status:     status,
+		broker:     s.broker,
 	}
 	s.Lock()
 	s.tasks[uuid] = &r
@@ -65,50 +70,59 @@ func (s *Storage) NewTask() service.TaskStatuser {
 }
 
 func (s *Storage) FindByID(id string) service.TaskStatuser {
-	for _, task := range s.tasks {
-		if task.id == id {
-			return task
-		}
+	s.RLock()
+	defer s.RUnlock()
+
+	if task, ok := s.tasks[id]; ok {
+		return task
 	}
 	return nil
 }
 
-// Id returns task Id
-func (t *TaskStatus) ID() string {
-	return t.id
-}
+// FindAll returns a page of tasks that match the params and the total number of matched tasks
+func (s *Storage) FindAll(params *types.TasksListParams) ([]domain.Task, int) {
+	s.RLock()
+	defer s.RUnlock()
 
-// Str a key-value pairs to a task status message
-func (t *TaskStatus) Str(keyvals ...string) service.TaskStatuser {
-	t.Lock()
-	defer t.Unlock()
-
-	for i := 0; i < len(keyvals); i += 2 {
-		if i+1 < len(keyvals) {
-			t.Status[fmt.Sprint(keyvals[i])] = keyvals[i+1]
-		} else {
-			t.Status[fmt.Sprint(keyvals[i])] = "(MISSING)"
+	found := []domain.Task{}
+	for _, task := range s.tasks {
+		t := task.Get()
+		if match(params, &t) {
+			found = append(found, t)
 		}
 	}
 
-	return t
+	return paginate(found, params.Offset, params.Limit), len(found)
+}
+
+// Subscribe returns a channel that receives the task record after every update
+func (s *Storage) Subscribe(id string) (<-chan domain.Task, func()) {
+	return s.broker.subscribe(id)
+}
+
+// Id returns task Id
+func (t *TaskStatus) ID() string {
+	return t.task.ID
 }
 
-// StrArr a key-value pairs to a task status message
-func (t *TaskStatus) StrArr(key string, arr []string) service.TaskStatuser {
+// Update changes the task record
+func (t *TaskStatus) Update(fn func(task *domain.Task)) service.TaskStatuser {
 	t.Lock()
 	defer t.Unlock()
 
-	t.Status[key] = arr
+	fn(&t.task)
+	t.task.UpdatedAt = time.Now()
+	t.broker.publish(t.task)
+
 	return t
 }
 
-// Get status messages from a task
-func (t *TaskStatus) Get() (statuses map[string]interface{}) {
-	t.Lock()
-	defer t.Unlock()
+// Get returns a copy of the task record
+func (t *TaskStatus) Get() domain.Task {
+	t.RLock()
+	defer t.RUnlock()
 
-	return t.Status
+	return t.task.Copy()
 }
 
 // gc search and clean expired tasks from in-memory storage
@@ -120,13 +134,13 @@ func (s *Storage) gc() {
 			return
 		}
 
-		s.RLock()
-		for _, task := range s.tasks {
+		s.Lock()
+		for id, task := range s.tasks {
 			isTaskExpired := time.Now().UnixNano() > task.expiration
 			if isTaskExpired {
-				delete(s.tasks, task.id)
+				delete(s.tasks, id)
 			}
 		}
-		s.RUnlock()
+		s.Unlock()
 	}
 }
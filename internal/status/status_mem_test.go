@@ -1,10 +1,14 @@
 package status
 
 import (
+	"errors"
 	"reflect"
 	"testing"
+	"time"
 
+	"github.com/vterdunov/janna-api/internal/domain"
 	"github.com/vterdunov/janna-api/internal/service"
+	"github.com/vterdunov/janna-api/internal/types"
 )
 
 func TestNewStorage(t *testing.T) {
@@ -27,7 +31,7 @@ func TestNewStorage(t *testing.T) {
 
 func TestStorage_NewTask(t *testing.T) {
 	st := NewStorage()
-	task := st.NewTask()
+	task := st.NewTask(domain.TaskKindVMDeploy)
 	_ = task
 
 	tests := []struct {
@@ -39,7 +43,7 @@ func TestStorage_NewTask(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := tt.s.NewTask(); !reflect.DeepEqual(got, tt.want) {
+			if got := tt.s.NewTask(domain.TaskKindVMDeploy); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("Storage.NewTask() = %v, want %v", got, tt.want)
 			}
 		})
@@ -52,7 +56,7 @@ func TestStorage_FindByID(t *testing.T) {
 	}
 
 	st := NewStorage()
-	task := st.NewTask()
+	task := st.NewTask(domain.TaskKindVMDeploy)
 	id := task.ID()
 	arg := args{id}
 
@@ -73,9 +77,50 @@ func TestStorage_FindByID(t *testing.T) {
 	}
 }
 
+func TestStorage_FindAll(t *testing.T) {
+	st := NewStorage()
+	newTask := func(stage domain.TaskStage, vm, reqID string) service.TaskStatuser {
+		return st.NewTask(domain.TaskKindVMDeploy).Update(func(task *domain.Task) {
+			task.SetStage(stage)
+			task.VMName = vm
+			task.RequestID = reqID
+		})
+	}
+	first := newTask(domain.TaskStageComplete, "vm1", "req1")
+	second := newTask(domain.TaskStageImport, "vm2", "req2")
+	third := newTask(domain.TaskStageImport, "vm3", "req3")
+	third.(*TaskStatus).task.CreatedAt = first.Get().CreatedAt.Add(time.Hour)
+
+	tests := []struct {
+		name      string
+		params    *types.TasksListParams
+		wantIDs   []string
+		wantTotal int
+	}{
+		{"stage", &types.TasksListParams{Stage: "complete"}, []string{first.ID()}, 1},
+		{"vm", &types.TasksListParams{VMName: "vm2"}, []string{second.ID()}, 1},
+		{"request id", &types.TasksListParams{RequestID: "req3"}, []string{third.ID()}, 1},
+		{"created after", &types.TasksListParams{CreatedAfter: first.Get().CreatedAt.Add(time.Minute)}, []string{third.ID()}, 1},
+		{"page", &types.TasksListParams{Stage: "import", Limit: 1}, []string{third.ID()}, 2},
+		{"offset out of range", &types.TasksListParams{Offset: 10}, []string{}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, total := st.FindAll(tt.params)
+			ids := []string{}
+			for _, task := range got {
+				ids = append(ids, task.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) || total != tt.wantTotal {
+				t.Errorf("Storage.FindAll() = %v, %v, want %v, %v", ids, total, tt.wantIDs, tt.wantTotal)
+			}
+		})
+	}
+}
+
 func TestTaskStatus_ID(t *testing.T) {
 	st := NewStorage()
-	task := st.NewTask()
+	task := st.NewTask(domain.TaskKindVMDeploy)
 	ts := task.(*TaskStatus)
 	id := ts.ID()
 
@@ -95,59 +140,111 @@ func TestTaskStatus_ID(t *testing.T) {
 	}
 }
 
-func TestTaskStatus_Add(t *testing.T) {
-	type args struct {
-		keyvals []string
-	}
-
+func TestTaskStatus_Update(t *testing.T) {
 	st := NewStorage()
-	task := st.NewTask()
+	task := st.NewTask(domain.TaskKindVMDeploy)
 	ts := task.(*TaskStatus)
 
-	arg := args{[]string{"key", "value"}}
-
 	tests := []struct {
 		name string
 		t    *TaskStatus
-		args args
+		fn   func(task *domain.Task)
 	}{
-		{"add", ts, arg},
+		{"update", ts, func(task *domain.Task) { task.Message = "value" }},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			tt.t.Str(tt.args.keyvals...)
+			tt.t.Update(tt.fn)
 		})
 	}
 }
 
 func TestTaskStatus_Get(t *testing.T) {
 	st := NewStorage()
-	task := st.NewTask()
+	task := st.NewTask(domain.TaskKindVMDeploy)
 	fullTask := task.(*TaskStatus)
-	fullTask.Str("key", "value")
+	fullTask.Update(func(task *domain.Task) {
+		task.SetStage(domain.TaskStageComplete)
+		task.Result.IPs = []string{"10.0.0.1"}
+	})
 
-	task2 := st.NewTask()
-	mvTask := task2.(*TaskStatus)
-	mvTask.Str("key")
-	mMap := map[string]interface{}{"key": "(MISSING)"}
+	task2 := st.NewTask(domain.TaskKindVMDeploy)
+	failedTask := task2.(*TaskStatus)
+	failedTask.Update(func(task *domain.Task) {
+		task.SetStage(domain.TaskStageImport)
+		task.Fail(errors.New("boom"))
+	})
 
 	tests := []struct {
-		name         string
-		t            *TaskStatus
-		wantStatuses map[string]interface{}
+		name      string
+		t         *TaskStatus
+		wantStage domain.TaskStage
+		wantError *domain.TaskError
+		wantIPs   []string
 	}{
-		{"fullKeyValue", fullTask, fullTask.Get()},
-		{"missingValue", mvTask, mMap},
+		{"complete", fullTask, domain.TaskStageComplete, nil, []string{"10.0.0.1"}},
+		{"failed", failedTask, domain.TaskStageError, &domain.TaskError{Stage: domain.TaskStageImport, Message: "boom"}, nil},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if gotStatuses := tt.t.Get(); !reflect.DeepEqual(gotStatuses, tt.wantStatuses) {
-				t.Errorf("TaskStatus.Get() = %v, want %v", gotStatuses, tt.wantStatuses)
+			got := tt.t.Get()
+			if got.Stage != tt.wantStage || !reflect.DeepEqual(got.Error, tt.wantError) || !reflect.DeepEqual(got.Result.IPs, tt.wantIPs) {
+				t.Errorf("TaskStatus.Get() = %+v, want stage %v, error %v, ips %v", got, tt.wantStage, tt.wantError, tt.wantIPs)
+			}
+			if _, ok := got.StageTimes[tt.wantStage]; !ok {
+				t.Errorf("TaskStatus.Get() has no time for stage %v", tt.wantStage)
 			}
 		})
 	}
 }
 
+func TestStorage_Subscribe(t *testing.T) {
+	st := NewStorage()
+	task := st.NewTask(domain.TaskKindVMDeploy)
+	other := st.NewTask(domain.TaskKindVMDeploy)
+
+	updates, unsubscribe := st.Subscribe(task.ID())
+
+	other.Update(func(t *domain.Task) { t.SetStage(domain.TaskStageImport) })
+	task.Update(func(t *domain.Task) { t.SetStage(domain.TaskStageImport) })
+	task.Update(func(t *domain.Task) { t.SetStage(domain.TaskStageComplete) })
+
+	for _, want := range []domain.TaskStage{domain.TaskStageImport, domain.TaskStageComplete} {
+		select {
+		case got := <-updates:
+			if got.ID != task.ID() || got.Stage != want {
+				t.Errorf("Storage.Subscribe() got task %v on stage %v, want %v on stage %v", got.ID, got.Stage, task.ID(), want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Storage.Subscribe() did not receive stage %v", want)
+		}
+	}
+
+	unsubscribe()
+	task.Update(func(t *domain.Task) { t.Message = "after unsubscribe" })
+	if _, ok := <-updates; ok {
+		t.Error("Storage.Subscribe() channel is not closed after unsubscribe")
+	}
+}
+
+func TestBroker_publishSlowSubscriber(t *testing.T) {
+	b := newBroker()
+	updates, unsubscribe := b.subscribe("id")
+	defer unsubscribe()
+
+	for i := 0; i <= subscriptionBuffer*2; i++ {
+		b.publish(domain.Task{ID: "id", Progress: i})
+	}
+
+	var last domain.Task
+	for i := 0; i < subscriptionBuffer; i++ {
+		last = <-updates
+	}
+	if last.Progress != subscriptionBuffer*2 {
+		t.Errorf("broker.publish() last update progress = %v, want %v", last.Progress, subscriptionBuffer*2)
+	}
+}
+
 func TestStorage_gc(t *testing.T) {
 	tests := []struct {
 		name string
@@ -0,0 +1,74 @@
+package status
+
+import (
+	"sync"
+
+	"github.com/vterdunov/janna-api/internal/domain"
+)
+
+// subscriptionBuffer is a number of task updates a slow subscriber can lag behind.
+// Every update is a full task record, so when the buffer is full
+// the oldest update is dropped and the subscriber still gets the latest state.
+const subscriptionBuffer = 16
+
+// broker delivers task updates to subscribers
+type broker struct {
+	sync.Mutex
+	subs map[string]map[chan domain.Task]struct{}
+}
+
+func newBroker() *broker {
+	return &broker{
+		subs: make(map[string]map[chan domain.Task]struct{}),
+	}
+}
+
+// subscribe returns a channel that receives the task record after every update
+// and a function that cancels the subscription and closes the channel
+func (b *broker) subscribe(id string) (<-chan domain.Task, func()) {
+	ch := make(chan domain.Task, subscriptionBuffer)
+
+	b.Lock()
+	if b.subs[id] == nil {
+		b.subs[id] = make(map[chan domain.Task]struct{})
+	}
+	b.subs[id][ch] = struct{}{}
+	b.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.Lock()
+			delete(b.subs[id], ch)
+			if len(b.subs[id]) == 0 {
+				delete(b.subs, id)
+			}
+			b.Unlock()
+
+			close(ch)
+		})
+	}
+
+	return ch, unsubscribe
+}
+
+// publish sends the task record to all subscribers of the task without blocking
+func (b *broker) publish(t domain.Task) {
+	b.Lock()
+	defer b.Unlock()
+
+	for ch := range b.subs[t.ID] {
+		for sent := false; !sent; {
+			select {
+			case ch <- t.Copy():
+				sent = true
+			default:
+				// drop the oldest update
+				select {
+				case <-ch:
+				default:
+				}
+			}
+		}
+	}
+}
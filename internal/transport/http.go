@@ -3,10 +3,13 @@ package transport
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	_ "net/http/pprof" // Register pprof
 	"strconv"
+	"time"
 
 	"github.com/go-kit/kit/log"
 	httptransport "github.com/go-kit/kit/transport/http"
@@ -20,6 +23,7 @@ import (
 
 func populateRequestContext(ctx context.Context, r *http.Request) context.Context {
 	ctx = context.WithValue(ctx, service.ContextKeyRequestXRequestID, r.Header.Get("X-Request-Id"))
+	ctx = context.WithValue(ctx, endpoint.ContextKeyIdempotencyKey, r.Header.Get("Idempotency-Key"))
 	return ctx
 }
 
@@ -28,6 +32,7 @@ func NewHTTPHandler(endpoints endpoint.Endpoints, logger log.Logger, debug bool)
 	options := []httptransport.ServerOption{
 		httptransport.ServerErrorLogger(logger),
 		httptransport.ServerBefore(populateRequestContext),
+		httptransport.ServerErrorEncoder(encodeError),
 	}
 
 	r := mux.NewRouter()
@@ -89,6 +94,43 @@ func NewHTTPHandler(endpoints endpoint.Endpoints, logger log.Logger, debug bool)
 		options...,
 	))
 
+	// OVA packages
+	r.Path("/ova/inspect").Methods("POST").Handler(httptransport.NewServer(
+		endpoints.OVAInspectEndpoint,
+		decodeOVAInspectRequest,
+		encodeResponse,
+		options...,
+	))
+
+	r.Path("/ova/uploads").Methods("POST").Handler(httptransport.NewServer(
+		endpoints.OVAUploadEndpoint,
+		decodeOVAUploadRequest,
+		encodeResponse,
+		options...,
+	))
+
+	r.Path("/ova/uploads/{uploadID}").Methods("DELETE").Handler(httptransport.NewServer(
+		endpoints.OVAUploadDeleteEndpoint,
+		decodeOVAUploadDeleteRequest,
+		encodeResponse,
+		options...,
+	))
+
+	// Content Libraries
+	r.Path("/libraries").Methods("GET").Handler(httptransport.NewServer(
+		endpoints.LibrariesListEndpoint,
+		decodeLibrariesListRequest,
+		encodeResponse,
+		options...,
+	))
+
+	r.Path("/libraries/{library}/items").Methods("GET").Handler(httptransport.NewServer(
+		endpoints.LibraryItemsListEndpoint,
+		decodeLibraryItemsListRequest,
+		encodeResponse,
+		options...,
+	))
+
 	// Snapshots
 	r.Path("/vms/{vm}/snapshots").Methods("GET").Handler(httptransport.NewServer(
 		endpoints.VMSnapshotsListEndpoint,
@@ -174,6 +216,13 @@ func NewHTTPHandler(endpoints endpoint.Endpoints, logger log.Logger, debug bool)
 	))
 
 	// Tasks statuses
+	r.Path("/tasks").Methods("GET").Handler(httptransport.NewServer(
+		endpoints.TasksListEndpoint,
+		decodeTasksListRequest,
+		encodeResponse,
+		options...,
+	))
+
 	r.Path("/tasks/{taskID}").Methods("GET").Handler(httptransport.NewServer(
 		endpoints.TaskInfoEndpoint,
 		decodeTaskInfoRequest,
@@ -181,6 +230,20 @@ func NewHTTPHandler(endpoints endpoint.Endpoints, logger log.Logger, debug bool)
 		options...,
 	))
 
+	r.Path("/tasks/{taskID}").Methods("DELETE").Handler(httptransport.NewServer(
+		endpoints.TaskCancelEndpoint,
+		decodeTaskCancelRequest,
+		encodeResponse,
+		options...,
+	))
+
+	r.Path("/tasks/{taskID}/events").Methods("GET").Handler(httptransport.NewServer(
+		endpoints.TaskEventsEndpoint,
+		decodeTaskEventsRequest,
+		encodeTaskEventsResponse,
+		options...,
+	))
+
 	r.Path("/openapi").Methods("GET").Handler(httptransport.NewServer(
 		endpoints.OpenAPIEndpoint,
 		decodeOpenAPIRequest,
@@ -254,6 +317,74 @@ func decodeVMDeployRequest(_ context.Context, r *http.Request) (interface{}, err
 	return req, nil
 }
 
+func decodeOVAInspectRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req endpoint.OVAInspectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, errors.Wrap(err, "Could not decode request: "+r.Method+" "+r.RequestURI)
+	}
+	return req, nil
+}
+
+// decodeOVAUploadRequest accepts the OVA as a raw request body or as 'file' field of a multipart form.
+// The body is streamed to the staging area, it is not buffered in memory.
+func decodeOVAUploadRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	req := endpoint.OVAUploadRequest{
+		Name: r.URL.Query().Get("name"),
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		req.Body = r.Body
+		return req, nil
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not decode request: "+r.Method+" "+r.RequestURI)
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			// no 'file' field
+			return req, nil
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "Could not decode request: "+r.Method+" "+r.RequestURI)
+		}
+
+		if part.FormName() == "file" {
+			if req.Name == "" {
+				req.Name = part.FileName()
+			}
+			req.Body = part
+			return req, nil
+		}
+	}
+}
+
+func decodeLibrariesListRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	return endpoint.LibrariesListRequest{}, nil
+}
+
+func decodeLibraryItemsListRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req endpoint.LibraryItemsListRequest
+
+	vars := mux.Vars(r)
+	req.Library = vars["library"]
+
+	return req, nil
+}
+
+func decodeOVAUploadDeleteRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req endpoint.OVAUploadDeleteRequest
+
+	vars := mux.Vars(r)
+	req.ID = vars["uploadID"]
+
+	return req, nil
+}
+
 func decodeVMSnapshotsListyRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	var req endpoint.VMSnapshotsListRequest
 
@@ -363,6 +494,68 @@ func decodeVMScreenshotRequest(_ context.Context, r *http.Request) (interface{},
 func decodeTaskInfoRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	var req endpoint.TaskInfoRequest
 
+	vars := mux.Vars(r)
+	req.TaskID = vars["taskID"]
+	req.View = r.URL.Query().Get("view")
+
+	return req, nil
+}
+
+func decodeTasksListRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req endpoint.TasksListRequest
+
+	q := r.URL.Query()
+	req.Stage = q.Get("stage")
+	req.VMName = q.Get("vm")
+	req.RequestID = q.Get("request_id")
+
+	var err error
+	if v := q.Get("created_after"); v != "" {
+		if req.CreatedAfter, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, errors.Wrap(err, "Could not decode 'created_after' parameter")
+		}
+	}
+
+	if v := q.Get("created_before"); v != "" {
+		if req.CreatedBefore, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, errors.Wrap(err, "Could not decode 'created_before' parameter")
+		}
+	}
+
+	if v := q.Get("offset"); v != "" {
+		if req.Offset, err = strconv.Atoi(v); err != nil {
+			return nil, errors.Wrap(err, "Could not decode 'offset' parameter")
+		}
+	}
+
+	if v := q.Get("limit"); v != "" {
+		if req.Limit, err = strconv.Atoi(v); err != nil {
+			return nil, errors.Wrap(err, "Could not decode 'limit' parameter")
+		}
+	}
+
+	return req, nil
+}
+
+func decodeTaskCancelRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req endpoint.TaskCancelRequest
+
+	vars := mux.Vars(r)
+	req.TaskID = vars["taskID"]
+	err := json.NewDecoder(r.Body).Decode(&req)
+	switch {
+	case err == io.EOF:
+		// Empty body. No operation.
+	case err != nil:
+		return nil, errors.Wrap(err, "Could not decode request: "+r.Method+" "+r.RequestURI)
+	}
+
+	return req, nil
+}
+
+func decodeTaskEventsRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req endpoint.TaskEventsRequest
+
 	vars := mux.Vars(r)
 	req.TaskID = vars["taskID"]
 
@@ -394,12 +587,19 @@ func encodeProbeResponse(_ context.Context, w http.ResponseWriter, response inte
 	return nil
 }
 
+// encodeError encodes transport errors. Errors may define their status code, e.g. rejected idempotent requests
 func encodeError(_ context.Context, err error, w http.ResponseWriter) {
 	if err == nil {
 		panic("encodeError with nil error")
 	}
+
+	code := http.StatusInternalServerError
+	if sc, ok := err.(httptransport.StatusCoder); ok {
+		code = sc.StatusCode()
+	}
+
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(http.StatusInternalServerError)
+	w.WriteHeader(code)
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"error": err.Error(),
 	})
@@ -497,9 +697,78 @@ func encodeTaskInfoResponse(ctx context.Context, w http.ResponseWriter, response
 
 	res, ok := response.(endpoint.TaskInfoResponse)
 	if !ok {
-		encodeError(ctx, errors.New("could not get OpenAPI data"), w)
+		encodeError(ctx, errors.New("could not get task info"), w)
+		return nil
+	}
+
+	if res.Legacy != nil {
+		return json.NewEncoder(w).Encode(res.Legacy)
+	}
+	return json.NewEncoder(w).Encode(res.Task)
+}
+
+// sseKeepAliveInterval is an interval of comments sent to an idle events stream,
+// so proxies do not close the connection
+const sseKeepAliveInterval = 15 * time.Second
+
+// encodeTaskEventsResponse writes task events as a Server-Sent Events stream
+// until the task is finished or the client goes away
+func encodeTaskEventsResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	// check business logic errors
+	if e, ok := response.(endpoint.Failer); ok && e.Failed() != nil {
+		encodeBusinesLogicError(ctx, e.Failed(), w)
+		return nil
+	}
+
+	res, ok := response.(endpoint.TaskEventsResponse)
+	if !ok {
+		encodeError(ctx, errors.New("could not get task events"), w)
+		return nil
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		encodeError(ctx, errors.New("streaming is not supported"), w)
+		return nil
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	id := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-keepAlive.C:
+			if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+				return err
+			}
+			flusher.Flush()
+		case event, ok := <-res.Events:
+			if !ok {
+				return nil
+			}
+
+			data, err := json.Marshal(event.Task)
+			if err != nil {
+				return err
+			}
+
+			id++
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", id, event.Name, data); err != nil {
+				return err
+			}
+			flusher.Flush()
+		}
 	}
-	return json.NewEncoder(w).Encode(res.Status)
 }
 
 func encodeVMListResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
@@ -0,0 +1,54 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type statusError struct {
+	code int
+}
+
+func (e statusError) Error() string {
+	return "rejected"
+}
+
+func (e statusError) StatusCode() int {
+	return e.code
+}
+
+func TestEncodeError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode int
+		wantBody string
+	}{
+		{"plain error", errors.New("boom"), http.StatusInternalServerError, "boom"},
+		{"status coder", statusError{http.StatusConflict}, http.StatusConflict, "rejected"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			encodeError(context.Background(), tt.err, w)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("encodeError() status = %d, want %d", w.Code, tt.wantCode)
+			}
+
+			var body struct {
+				Error string `json:"error"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+				t.Fatalf("encodeError() body is not JSON: %v", err)
+			}
+			if body.Error != tt.wantBody {
+				t.Errorf("encodeError() error = %q, want %q", body.Error, tt.wantBody)
+			}
+		})
+	}
+}
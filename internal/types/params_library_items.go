@@ -0,0 +1,7 @@
+package types
+
+// LibraryItemsListParams stores user request parameters
+type LibraryItemsListParams struct {
+	// Library is a Content Library name or ID
+	Library string
+}
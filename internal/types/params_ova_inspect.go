@@ -0,0 +1,6 @@
+package types
+
+// OVAInspectParams stores user request parameters
+type OVAInspectParams struct {
+	OVAURL string
+}
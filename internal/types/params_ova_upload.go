@@ -0,0 +1,15 @@
+package types
+
+import "io"
+
+// OVAUploadParams stores user request parameters
+type OVAUploadParams struct {
+	// Name is the original file name. Can be empty
+	Name string
+	Body io.Reader
+}
+
+// OVAUploadDeleteParams stores user request parameters
+type OVAUploadDeleteParams struct {
+	ID string
+}
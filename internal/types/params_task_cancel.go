@@ -0,0 +1,7 @@
+package types
+
+// TaskCancelParams stores user request parameters
+type TaskCancelParams struct {
+	TaskID    string
+	DestroyVM bool
+}
@@ -0,0 +1,14 @@
+package types
+
+import "time"
+
+// TasksListParams stores user request parameters
+type TasksListParams struct {
+	Stage         string
+	VMName        string
+	RequestID     string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	Offset        int
+	Limit         int
+}
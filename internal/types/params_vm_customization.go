@@ -0,0 +1,96 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+)
+
+// hostnameRe matches a single DNS label
+var hostnameRe = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`)
+
+// VMCustomization is a guest OS customization applied before the first power on.
+// Either SpecName or the inline settings are used.
+type VMCustomization struct {
+	// SpecName is a name of a customization spec stored in vCenter
+	SpecName string
+
+	Hostname    string
+	Domain      string
+	DNSServers  []string
+	DNSSuffixes []string
+	// NICs are settings of the network adapters in the order of the Virtual Machine devices.
+	// The adapters without settings use DHCP
+	NICs []VMCustomizationNIC
+}
+
+// VMCustomizationNIC keeps network settings of a single network adapter.
+// Empty IP means DHCP
+type VMCustomizationNIC struct {
+	IP         string
+	Netmask    string
+	Gateways   []string
+	DNSServers []string
+}
+
+// IsEmpty reports whether the customization was not requested
+func (c *VMCustomization) IsEmpty() bool {
+	return c.SpecName == "" && !c.hasInline()
+}
+
+func (c *VMCustomization) hasInline() bool {
+	return c.Hostname != "" || c.Domain != "" || len(c.DNSServers) != 0 || len(c.DNSSuffixes) != 0 || len(c.NICs) != 0
+}
+
+// Validate checks the customization settings
+func (c *VMCustomization) Validate() error {
+	if c.SpecName != "" {
+		if c.hasInline() {
+			return errors.New("pass either customization 'spec_name' or inline settings")
+		}
+		return nil
+	}
+
+	if c.Hostname != "" && !hostnameRe.MatchString(c.Hostname) {
+		return fmt.Errorf("invalid customization hostname '%s'", c.Hostname)
+	}
+
+	if err := validateIPs("customization DNS server", c.DNSServers); err != nil {
+		return err
+	}
+
+	for i, nic := range c.NICs {
+		if nic.IP == "" {
+			if nic.Netmask != "" || len(nic.Gateways) != 0 {
+				return fmt.Errorf("customization NIC %d uses DHCP. Do not pass netmask and gateways", i)
+			}
+		} else {
+			if net.ParseIP(nic.IP) == nil {
+				return fmt.Errorf("invalid customization NIC %d IP '%s'", i, nic.IP)
+			}
+			if net.ParseIP(nic.Netmask) == nil {
+				return fmt.Errorf("invalid customization NIC %d netmask '%s'", i, nic.Netmask)
+			}
+		}
+
+		if err := validateIPs(fmt.Sprintf("customization NIC %d gateway", i), nic.Gateways); err != nil {
+			return err
+		}
+
+		if err := validateIPs(fmt.Sprintf("customization NIC %d DNS server", i), nic.DNSServers); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validateIPs(what string, ips []string) error {
+	for _, ip := range ips {
+		if net.ParseIP(ip) == nil {
+			return fmt.Errorf("invalid %s '%s'", what, ip)
+		}
+	}
+	return nil
+}
@@ -2,16 +2,60 @@ package types
 
 import (
 	"github.com/vterdunov/janna-api/internal/config"
+	"github.com/vterdunov/janna-api/internal/domain"
 )
 
+// DefaultDiskProvisioning is used for OVA import when a request does not specify the format
+const DefaultDiskProvisioning = "thin"
+
+// DefaultDatastoreStrategy is used when a request does not specify the datastore placement strategy
+const DefaultDatastoreStrategy = domain.DatastoreStrategyMostFreeSpace
+
+// DefaultCleanupPolicy keeps the Virtual Machine of a failed deploy for investigation
+const DefaultCleanupPolicy = domain.CleanupPolicyKeep
+
+// DiskProvisioningTypes are the disk formats ESXi can import OVA disks to
+var DiskProvisioningTypes = []string{"thin", "thick", "eagerZeroedThick", "seSparse"}
+
+// LibraryDiskProvisioningTypes are the disk formats vCenter can deploy Content Library items to
+var LibraryDiskProvisioningTypes = []string{"thin", "thick", "eagerZeroedThick"}
+
+// IsValidDiskProvisioning reports whether the disk format can be used for OVA import
+func IsValidDiskProvisioning(format string) bool {
+	return contains(DiskProvisioningTypes, format)
+}
+
+// IsValidLibraryDiskProvisioning reports whether the disk format can be used for Content Library deploy
+func IsValidLibraryDiskProvisioning(format string) bool {
+	return contains(LibraryDiskProvisioningTypes, format)
+}
+
+func contains(slice []string, s string) bool {
+	for _, t := range slice {
+		if t == s {
+			return true
+		}
+	}
+	return false
+}
+
 // VMDeployParams stores user request params
 type VMDeployParams struct {
-	Name              string
-	OVAURL            string
-	Datacenter        string
-	Folder            string
-	Annotation        string
-	Networks          map[string]string
+	Name       string
+	OVAURL     string
+	Datacenter string
+	Folder     string
+	Annotation string
+	Networks   map[string]string
+	// DefaultNetwork is a network of the OVF networks that are not passed in Networks
+	// and do not have a vSphere network with the same name. Can be empty
+	DefaultNetwork string
+	// DiskProvisioning is a format of the imported disks. It is not applied to clones
+	DiskProvisioning string
+	// Checksum is a checksum of the whole OVA in '<algo>:<hex>' form. Can be empty
+	Checksum string
+	// Properties are OVF vApp properties values by their keys. They are not applied to clones
+	Properties        map[string]string
 	ComputerResources struct {
 		Path string
 		Type string
@@ -19,7 +63,41 @@ type VMDeployParams struct {
 	Datastores struct {
 		Type  string
 		Names []string
+		// Strategy chooses one of the named datastores. It is not applied to datastore clusters
+		Strategy domain.DatastoreStrategy
+	}
+	// Clone is a template or a Virtual Machine to clone instead of OVA import.
+	// It is found by UUID or by inventory path
+	Clone struct {
+		UUID string
+		Path string
 	}
+	// Library is a Content Library OVF template to deploy instead of OVA import
+	Library struct {
+		Name string
+		Item string
+	}
+	// CleanupPolicy defines what happens to the Virtual Machine when the deploy fails
+	CleanupPolicy domain.CleanupPolicy
+	// Customization is applied to the guest OS before the first power on. Can be empty
+	Customization VMCustomization
+	// Hardware overrides CPU, memory and disks before the first power on. Can be empty
+	Hardware VMHardware
+	// Callback receives the task record when the deploy is finished. URL can be empty
+	Callback struct {
+		URL    string
+		Secret string
+	}
+}
+
+// IsClone reports whether the Virtual Machine is cloned instead of imported from OVA
+func (p *VMDeployParams) IsClone() bool {
+	return p.Clone.UUID != "" || p.Clone.Path != ""
+}
+
+// IsLibrary reports whether the Virtual Machine is deployed from a Content Library item
+func (p *VMDeployParams) IsLibrary() bool {
+	return p.Library.Name != "" || p.Library.Item != ""
 }
 
 // FillEmptyFields stores default parameters to the struct if some fields was empty
@@ -32,4 +110,20 @@ func (p *VMDeployParams) FillEmptyFields(cfg *config.Config) {
 		p.Folder = cfg.VMWare.Folder
 	}
 
+	if p.DefaultNetwork == "" {
+		p.DefaultNetwork = cfg.VMWare.Network
+	}
+
+	if p.DiskProvisioning == "" && !p.IsClone() {
+		p.DiskProvisioning = DefaultDiskProvisioning
+	}
+
+	if p.Datastores.Strategy == "" {
+		p.Datastores.Strategy = DefaultDatastoreStrategy
+	}
+
+	if p.CleanupPolicy == "" {
+		p.CleanupPolicy = DefaultCleanupPolicy
+	}
+
 }
@@ -0,0 +1,71 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+)
+
+// VMHardware overrides the hardware of the created Virtual Machine before the first power on.
+// Zero values keep the hardware of the source
+type VMHardware struct {
+	NumCPU         int32
+	CoresPerSocket int32
+	MemoryMB       int64
+	Disks          []VMHardwareDisk
+}
+
+// VMHardwareDisk resizes an existing disk or adds a new one
+type VMHardwareDisk struct {
+	// Index is a 0-based number of an existing disk in the order of the Virtual Machine devices.
+	// Negative index adds a new disk
+	Index  int
+	SizeGB int64
+}
+
+// IsNew reports whether the disk is added instead of resized
+func (d *VMHardwareDisk) IsNew() bool {
+	return d.Index < 0
+}
+
+// IsEmpty reports whether the hardware overrides were not requested
+func (h *VMHardware) IsEmpty() bool {
+	return h.NumCPU == 0 && h.CoresPerSocket == 0 && h.MemoryMB == 0 && len(h.Disks) == 0
+}
+
+// Validate checks the hardware overrides
+func (h *VMHardware) Validate() error {
+	if h.NumCPU < 0 || h.CoresPerSocket < 0 || h.MemoryMB < 0 {
+		return errors.New("hardware 'num_cpu', 'cores_per_socket' and 'memory_mb' must not be negative")
+	}
+
+	if h.CoresPerSocket != 0 && h.NumCPU == 0 {
+		return errors.New("pass hardware 'num_cpu' with 'cores_per_socket'")
+	}
+
+	if h.CoresPerSocket != 0 && h.NumCPU%h.CoresPerSocket != 0 {
+		return fmt.Errorf("hardware 'num_cpu' %d is not a multiple of 'cores_per_socket' %d", h.NumCPU, h.CoresPerSocket)
+	}
+
+	// vSphere requires memory size to be a multiple of 4 MB
+	if h.MemoryMB%4 != 0 {
+		return fmt.Errorf("hardware 'memory_mb' %d is not a multiple of 4", h.MemoryMB)
+	}
+
+	resized := make(map[int]bool)
+	for i, d := range h.Disks {
+		if d.SizeGB <= 0 {
+			return fmt.Errorf("hardware disk %d 'size_gb' must be positive", i)
+		}
+
+		if d.IsNew() {
+			continue
+		}
+
+		if resized[d.Index] {
+			return fmt.Errorf("hardware disk with index %d is passed twice", d.Index)
+		}
+		resized[d.Index] = true
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+package types
+
+import (
+	"time"
+
+	"github.com/vterdunov/janna-api/internal/domain"
+)
+
+// Task is a background task representation used in API responses
+type Task struct {
+	ID            string               `json:"id"`
+	Kind          string               `json:"kind"`
+	Stage         string               `json:"stage"`
+	Message       string               `json:"message,omitempty"`
+	VMName        string               `json:"vm_name,omitempty"`
+	RequestID     string               `json:"request_id,omitempty"`
+	CreatedAt     time.Time            `json:"created_at"`
+	UpdatedAt     time.Time            `json:"updated_at"`
+	StageTimes    map[string]time.Time `json:"stage_times"`
+	Progress      int                  `json:"progress"`
+	QueuePosition int                  `json:"queue_position,omitempty"`
+	Interrupted   bool                 `json:"interrupted,omitempty"`
+	OVACache      string               `json:"ova_cache,omitempty"`
+	Upload        *UploadProgress      `json:"upload,omitempty"`
+	Error         *TaskError           `json:"error,omitempty"`
+	Result        TaskResult           `json:"result"`
+	Cleanup       *TaskCleanup         `json:"cleanup,omitempty"`
+	Webhook       *WebhookDelivery     `json:"webhook,omitempty"`
+}
+
+// UploadProgress keeps disks upload progress during the import stage
+type UploadProgress struct {
+	Files            []FileProgress `json:"files"`
+	TotalBytes       int64          `json:"total_bytes"`
+	TransferredBytes int64          `json:"transferred_bytes"`
+	Percentage       float32        `json:"percentage"`
+	ETASeconds       int64          `json:"eta_seconds"`
+}
+
+// FileProgress keeps upload progress of a single disk file
+type FileProgress struct {
+	Path             string  `json:"path"`
+	TotalBytes       int64   `json:"total_bytes"`
+	TransferredBytes int64   `json:"transferred_bytes"`
+	Percentage       float32 `json:"percentage"`
+}
+
+// TaskError describes why a task has failed
+type TaskError struct {
+	Stage   string `json:"stage"`
+	Kind    string `json:"kind,omitempty"`
+	Message string `json:"message"`
+}
+
+// TaskResult keeps the outcome of a task
+type TaskResult struct {
+	VMUUID           string   `json:"vm_uuid,omitempty"`
+	IPs              []string `json:"ips,omitempty"`
+	DiskProvisioning string   `json:"disk_provisioning,omitempty"`
+	// Networks is the final mapping of the OVF networks to vSphere networks
+	Networks []NetworkMapping `json:"networks,omitempty"`
+}
+
+// NetworkMapping maps a network of the OVF to a vSphere network
+type NetworkMapping struct {
+	Name    string `json:"name"`
+	Network string `json:"network"`
+	Kind    string `json:"kind"`
+}
+
+// TaskCleanup keeps the cleanup policy of a deploy and the cleanup performed on failure
+type TaskCleanup struct {
+	Policy string `json:"policy"`
+	Action string `json:"action,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// WebhookDelivery keeps attempts to deliver the task result to the callback URL
+type WebhookDelivery struct {
+	URL       string           `json:"url"`
+	Delivered bool             `json:"delivered"`
+	Attempts  []WebhookAttempt `json:"attempts"`
+}
+
+// WebhookAttempt is a single try to deliver the task result
+type WebhookAttempt struct {
+	Time       time.Time `json:"time"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// NewTask returns the task representation used in API responses
+func NewTask(t *domain.Task) Task {
+	res := Task{
+		ID:            t.ID,
+		Kind:          string(t.Kind),
+		Stage:         string(t.Stage),
+		Message:       t.Message,
+		VMName:        t.VMName,
+		RequestID:     t.RequestID,
+		CreatedAt:     t.CreatedAt,
+		UpdatedAt:     t.UpdatedAt,
+		StageTimes:    make(map[string]time.Time, len(t.StageTimes)),
+		Progress:      t.Progress,
+		QueuePosition: t.QueuePosition,
+		Interrupted:   t.Interrupted,
+		OVACache:      string(t.OVACache),
+		Result: TaskResult{
+			VMUUID:           t.Result.VMUUID,
+			IPs:              t.Result.IPs,
+			DiskProvisioning: t.Result.DiskProvisioning,
+		},
+	}
+
+	for _, n := range t.Result.Networks {
+		res.Result.Networks = append(res.Result.Networks, NetworkMapping{
+			Name:    n.Name,
+			Network: n.Network,
+			Kind:    string(n.Kind),
+		})
+	}
+
+	for stage, ts := range t.StageTimes {
+		res.StageTimes[string(stage)] = ts
+	}
+
+	if t.Upload != nil {
+		res.Upload = &UploadProgress{
+			Files:            make([]FileProgress, 0, len(t.Upload.Files)),
+			TotalBytes:       t.Upload.TotalBytes,
+			TransferredBytes: t.Upload.TransferredBytes,
+			Percentage:       t.Upload.Percentage,
+			ETASeconds:       int64(t.Upload.ETA.Seconds()),
+		}
+		for _, f := range t.Upload.Files {
+			res.Upload.Files = append(res.Upload.Files, FileProgress(f))
+		}
+	}
+
+	if t.Error != nil {
+		res.Error = &TaskError{
+			Stage:   string(t.Error.Stage),
+			Kind:    string(t.Error.Kind),
+			Message: t.Error.Message,
+		}
+	}
+
+	if t.Cleanup != nil {
+		res.Cleanup = &TaskCleanup{
+			Policy: string(t.Cleanup.Policy),
+			Action: string(t.Cleanup.Action),
+			Error:  t.Cleanup.Error,
+		}
+	}
+
+	if t.Webhook != nil {
+		res.Webhook = &WebhookDelivery{
+			URL:       t.Webhook.URL,
+			Delivered: t.Webhook.Delivered,
+			Attempts:  make([]WebhookAttempt, 0, len(t.Webhook.Attempts)),
+		}
+		for _, a := range t.Webhook.Attempts {
+			res.Webhook.Attempts = append(res.Webhook.Attempts, WebhookAttempt(a))
+		}
+	}
+
+	return res
+}